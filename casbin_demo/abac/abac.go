@@ -0,0 +1,119 @@
+// Package abac 基于属性的访问控制: 请求的主体和资源是 Go 结构体,
+// 策略中的表达式通过注册的函数读取它们的属性 (见 model_abac.pml).
+package abac
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/casbin/casbin/v2"
+)
+
+// User 请求的主体
+type User struct {
+	Name       string
+	Department string
+	Admin      bool
+}
+
+// Resource 可以被授权访问的资源
+type Resource interface {
+	ResourceOwner() string
+	ResourceDepartment() string
+}
+
+// Record 一条业务记录, 只有所有者可以编辑
+type Record struct {
+	ID         string
+	Owner      string
+	Department string
+	Content    string
+}
+
+func (r *Record) ResourceOwner() string      { return r.Owner }
+func (r *Record) ResourceDepartment() string { return r.Department }
+
+// Enforcer 注册了 ABAC 函数的 Enforcer
+type Enforcer struct {
+	*casbin.Enforcer
+	now func() time.Time
+}
+
+// Option 配置 Enforcer
+type Option func(e *Enforcer)
+
+// WithClock sets the clock used by withinHours, time.Now by default.
+func WithClock(now func() time.Time) Option {
+	return func(e *Enforcer) {
+		e.now = now
+	}
+}
+
+// NewEnforcer creates an enforcer for an ABAC model and registers isOwner,
+// sameDepartment and withinHours.
+func NewEnforcer(modelPath string, policy interface{}, opts ...Option) (*Enforcer, error) {
+	ce, err := casbin.NewEnforcer(modelPath, policy)
+	if err != nil {
+		return nil, err
+	}
+	e := &Enforcer{Enforcer: ce, now: time.Now}
+	for _, opt := range opts {
+		opt(e)
+	}
+	e.AddFunction("isOwner", e.isOwner)
+	e.AddFunction("sameDepartment", e.sameDepartment)
+	e.AddFunction("withinHours", e.withinHours)
+	return e, nil
+}
+
+// Can reports whether user may perform act on res.
+func (e *Enforcer) Can(user User, res Resource, act string) (bool, error) {
+	return e.Enforce(user, res, act)
+}
+
+// isOwner(r.sub, r.obj) 主体是资源的所有者
+func (e *Enforcer) isOwner(args ...interface{}) (interface{}, error) {
+	user, res, err := userAndResource("isOwner", args)
+	if err != nil {
+		return false, err
+	}
+	return user.Name != "" && user.Name == res.ResourceOwner(), nil
+}
+
+// sameDepartment(r.sub, r.obj) 主体与资源属于同一个部门
+func (e *Enforcer) sameDepartment(args ...interface{}) (interface{}, error) {
+	user, res, err := userAndResource("sameDepartment", args)
+	if err != nil {
+		return false, err
+	}
+	return user.Department != "" && user.Department == res.ResourceDepartment(), nil
+}
+
+// withinHours(start, end) 当前时间在 [start, end) 点之间
+func (e *Enforcer) withinHours(args ...interface{}) (interface{}, error) {
+	if len(args) != 2 {
+		return false, fmt.Errorf("withinHours: expected 2 arguments, got %d", len(args))
+	}
+	start, ok1 := args[0].(float64)
+	end, ok2 := args[1].(float64)
+	if !ok1 || !ok2 {
+		return false, fmt.Errorf("withinHours: arguments must be numbers")
+	}
+	hour := float64(e.now().Hour())
+	return hour >= start && hour < end, nil
+}
+
+func userAndResource(name string, args []interface{}) (User, Resource, error) {
+	if len(args) != 2 {
+		return User{}, nil, fmt.Errorf("%s: expected 2 arguments, got %d", name, len(args))
+	}
+	user, ok := args[0].(User)
+	if !ok {
+		return User{}, nil, fmt.Errorf("%s: subject is %T, not abac.User", name, args[0])
+	}
+	res, ok := args[1].(Resource)
+	if !ok {
+		return User{}, nil, fmt.Errorf("%s: object %T does not implement abac.Resource", name, args[1])
+	}
+	return user, res, nil
+}
@@ -0,0 +1,83 @@
+package abac
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func newTestEnforcer(t *testing.T, hour int) *Enforcer {
+	t.Helper()
+	e, err := NewEnforcer("../model_abac.pml", "../policy_abac.csv", WithClock(func() time.Time {
+		return time.Date(2024, 1, 1, hour, 0, 0, 0, time.Local)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func TestCan(t *testing.T) {
+	record := &Record{ID: "1", Owner: "zhangsan", Department: "dev"}
+	var (
+		owner     = User{Name: "zhangsan", Department: "dev"}
+		colleague = User{Name: "lisi", Department: "dev"}
+		stranger  = User{Name: "wangwu", Department: "ops"}
+		admin     = User{Name: "root", Admin: true}
+	)
+	cases := []struct {
+		name string
+		user User
+		act  string
+		hour int
+		want bool
+	}{
+		{"所有者可以编辑", owner, "edit", 10, true},
+		{"所有者可以查看", owner, "read", 10, true},
+		{"同部门可以查看", colleague, "read", 10, true},
+		{"同部门不能编辑", colleague, "edit", 10, false},
+		{"同部门工作时间可以评论", colleague, "comment", 10, true},
+		{"同部门下班后不能评论", colleague, "comment", 20, false},
+		{"其他部门不能查看", stranger, "read", 10, false},
+		{"管理员可以编辑", admin, "edit", 3, true},
+		{"空用户名不是所有者", User{}, "edit", 10, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := newTestEnforcer(t, tc.hour).Can(tc.user, record, tc.act)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("Can(%s, %s) = %v; 期望值 %v", tc.user.Name, tc.act, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRecordStore(t *testing.T) {
+	store := NewRecordStore(newTestEnforcer(t, 10))
+	store.Put(Record{ID: "1", Owner: "zhangsan", Department: "dev", Content: "v1"})
+
+	if err := store.Update(User{Name: "zhangsan"}, "1", "v2"); err != nil {
+		t.Fatalf("所有者编辑失败: %v", err)
+	}
+	if err := store.Update(User{Name: "lisi", Department: "dev"}, "1", "v3"); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Update = %v; 期望 ErrForbidden", err)
+	}
+	rec, err := store.Get(User{Name: "lisi", Department: "dev"}, "1")
+	if err != nil || rec.Content != "v2" {
+		t.Errorf("Get = %+v, %v; 期望内容 v2", rec, err)
+	}
+	if _, err := store.Get(User{Name: "zhangsan"}, "2"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get = %v; 期望 ErrNotFound", err)
+	}
+}
+
+func TestInvalidArguments(t *testing.T) {
+	e := newTestEnforcer(t, 10)
+	// 主体不是 User 时注册的函数返回错误
+	if _, err := e.Enforce("zhangsan", &Record{}, "edit"); err == nil {
+		t.Error("Enforce 应该返回错误")
+	}
+}
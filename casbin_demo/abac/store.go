@@ -0,0 +1,69 @@
+package abac
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var (
+	ErrNotFound  = errors.New("abac: record not found")
+	ErrForbidden = errors.New("abac: forbidden")
+)
+
+// RecordStore 内存中的记录, 每次访问都经过 Enforcer 检查
+type RecordStore struct {
+	mu       sync.RWMutex
+	enforcer *Enforcer
+	records  map[string]*Record
+}
+
+// NewRecordStore creates an empty store guarded by e.
+func NewRecordStore(e *Enforcer) *RecordStore {
+	return &RecordStore{enforcer: e, records: map[string]*Record{}}
+}
+
+// Put stores rec without an authorization check, used for seeding.
+func (s *RecordStore) Put(rec Record) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[rec.ID] = &rec
+}
+
+// Get returns a copy of the record if user may read it.
+func (s *RecordStore) Get(user User, id string) (Record, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	rec, err := s.authorize(user, id, "read")
+	if err != nil {
+		return Record{}, err
+	}
+	return *rec, nil
+}
+
+// Update replaces the content of the record if user may edit it.
+func (s *RecordStore) Update(user User, id, content string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, err := s.authorize(user, id, "edit")
+	if err != nil {
+		return err
+	}
+	rec.Content = content
+	return nil
+}
+
+func (s *RecordStore) authorize(user User, id, act string) (*Record, error) {
+	rec, ok := s.records[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	allowed, err := s.enforcer.Can(user, rec, act)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		return nil, fmt.Errorf("%w: %s cannot %s record %s", ErrForbidden, user.Name, act, id)
+	}
+	return rec, nil
+}
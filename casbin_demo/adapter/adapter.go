@@ -0,0 +1,333 @@
+package adapter
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/casbin/casbin/v2/model"
+	"github.com/casbin/casbin/v2/persist"
+)
+
+const (
+	defaultTableName = "casbin_rule"
+	maxRuleFields    = 6 // 表中 v0-v5 六列
+)
+
+// dialect 不同数据库的占位符和建表语句
+type dialect struct {
+	placeholder func(i int) string
+	createTable string
+}
+
+var dialects = map[string]dialect{
+	"sqlite": {
+		placeholder: func(int) string { return "?" },
+		createTable: `CREATE TABLE IF NOT EXISTS %s (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	ptype VARCHAR(100) NOT NULL DEFAULT '',
+	v0 VARCHAR(255) NOT NULL DEFAULT '', v1 VARCHAR(255) NOT NULL DEFAULT '', v2 VARCHAR(255) NOT NULL DEFAULT '',
+	v3 VARCHAR(255) NOT NULL DEFAULT '', v4 VARCHAR(255) NOT NULL DEFAULT '', v5 VARCHAR(255) NOT NULL DEFAULT '')`,
+	},
+	"mysql": {
+		placeholder: func(int) string { return "?" },
+		createTable: `CREATE TABLE IF NOT EXISTS %s (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	ptype VARCHAR(100) NOT NULL DEFAULT '',
+	v0 VARCHAR(255) NOT NULL DEFAULT '', v1 VARCHAR(255) NOT NULL DEFAULT '', v2 VARCHAR(255) NOT NULL DEFAULT '',
+	v3 VARCHAR(255) NOT NULL DEFAULT '', v4 VARCHAR(255) NOT NULL DEFAULT '', v5 VARCHAR(255) NOT NULL DEFAULT '')`,
+	},
+	"postgres": {
+		placeholder: func(i int) string { return fmt.Sprintf("$%d", i) },
+		createTable: `CREATE TABLE IF NOT EXISTS %s (
+	id BIGSERIAL PRIMARY KEY,
+	ptype VARCHAR(100) NOT NULL DEFAULT '',
+	v0 VARCHAR(255) NOT NULL DEFAULT '', v1 VARCHAR(255) NOT NULL DEFAULT '', v2 VARCHAR(255) NOT NULL DEFAULT '',
+	v3 VARCHAR(255) NOT NULL DEFAULT '', v4 VARCHAR(255) NOT NULL DEFAULT '', v5 VARCHAR(255) NOT NULL DEFAULT '')`,
+	},
+}
+
+// driverDialects database/sql 驱动名到方言的映射
+var driverDialects = map[string]string{
+	"sqlite":   "sqlite",
+	"sqlite3":  "sqlite",
+	"mysql":    "mysql",
+	"postgres": "postgres",
+	"pgx":      "postgres",
+}
+
+// Filter 加载部分策略的过滤条件, 每个字段为空表示不过滤, 否则取值必须在列表中
+type Filter struct {
+	Ptype []string
+	V0    []string
+	V1    []string
+	V2    []string
+	V3    []string
+	V4    []string
+	V5    []string
+}
+
+// Adapter 基于 database/sql 的 casbin 策略适配器, 支持增量保存和按条件加载
+type Adapter struct {
+	db       *sql.DB
+	table    string
+	dialect  dialect
+	filtered bool
+}
+
+var (
+	_ persist.BatchAdapter    = (*Adapter)(nil)
+	_ persist.FilteredAdapter = (*Adapter)(nil)
+)
+
+// NewAdapter creates the policy table if needed. driverName selects the SQL
+// dialect (sqlite, sqlite3, mysql, postgres, pgx), tableName defaults to casbin_rule.
+func NewAdapter(db *sql.DB, driverName, tableName string) (*Adapter, error) {
+	name, ok := driverDialects[driverName]
+	if !ok {
+		return nil, fmt.Errorf("adapter: unsupported driver %q", driverName)
+	}
+	if tableName == "" {
+		tableName = defaultTableName
+	}
+	a := &Adapter{db: db, table: tableName, dialect: dialects[name]}
+	if _, err := db.Exec(fmt.Sprintf(a.dialect.createTable, a.table)); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Open opens the database and creates an Adapter with the default table name.
+func Open(driverName, dataSourceName string) (*Adapter, error) {
+	db, err := sql.Open(driverName, dataSourceName)
+	if err != nil {
+		return nil, err
+	}
+	a, err := NewAdapter(db, driverName, "")
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return a, nil
+}
+
+// Close closes the underlying database.
+func (a *Adapter) Close() error {
+	return a.db.Close()
+}
+
+// Count returns the number of stored rules.
+func (a *Adapter) Count() (int, error) {
+	var n int
+	err := a.db.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s", a.table)).Scan(&n)
+	return n, err
+}
+
+// LoadPolicy loads all policy rules from the table.
+func (a *Adapter) LoadPolicy(m model.Model) error {
+	a.filtered = false
+	return a.load(m, "", nil)
+}
+
+// LoadFilteredPolicy loads only the rules matching filter, which must be a
+// Filter or *Filter. A nil filter loads every rule.
+func (a *Adapter) LoadFilteredPolicy(m model.Model, filter interface{}) error {
+	var f *Filter
+	switch v := filter.(type) {
+	case nil:
+		return a.LoadPolicy(m)
+	case Filter:
+		f = &v
+	case *Filter:
+		if v == nil {
+			return a.LoadPolicy(m)
+		}
+		f = v
+	default:
+		return fmt.Errorf("adapter: invalid filter type %T", filter)
+	}
+
+	var conditions []string
+	var args []interface{}
+	columns := [][]string{f.Ptype, f.V0, f.V1, f.V2, f.V3, f.V4, f.V5}
+	for i, values := range columns {
+		if len(values) == 0 {
+			continue
+		}
+		placeholders := make([]string, len(values))
+		for j, value := range values {
+			args = append(args, value)
+			placeholders[j] = a.dialect.placeholder(len(args))
+		}
+		conditions = append(conditions, fmt.Sprintf("%s IN (%s)", columnName(i), strings.Join(placeholders, ", ")))
+	}
+	a.filtered = true
+	return a.load(m, strings.Join(conditions, " AND "), args)
+}
+
+// IsFiltered returns true if the loaded policy has been filtered.
+func (a *Adapter) IsFiltered() bool {
+	return a.filtered
+}
+
+func (a *Adapter) load(m model.Model, where string, args []interface{}) error {
+	query := fmt.Sprintf("SELECT ptype, v0, v1, v2, v3, v4, v5 FROM %s", a.table)
+	if where != "" {
+		query += " WHERE " + where
+	}
+	rows, err := a.db.Query(query+" ORDER BY id", args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		line := make([]string, 1+maxRuleFields)
+		if err := rows.Scan(&line[0], &line[1], &line[2], &line[3], &line[4], &line[5], &line[6]); err != nil {
+			return err
+		}
+		// 去掉末尾未使用的列
+		for len(line) > 1 && line[len(line)-1] == "" {
+			line = line[:len(line)-1]
+		}
+		if err := persist.LoadPolicyArray(line, m); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// SavePolicy replaces every stored rule with the rules of the model.
+func (a *Adapter) SavePolicy(m model.Model) error {
+	tx, err := a.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(fmt.Sprintf("DELETE FROM %s", a.table)); err != nil {
+		return err
+	}
+	for _, sec := range []string{"p", "g"} {
+		for ptype, ast := range m[sec] {
+			if err := a.insert(tx, ptype, ast.Policy); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// AddPolicy adds a policy rule to the table.
+func (a *Adapter) AddPolicy(sec string, ptype string, rule []string) error {
+	return a.AddPolicies(sec, ptype, [][]string{rule})
+}
+
+// AddPolicies adds policy rules to the table in one transaction.
+func (a *Adapter) AddPolicies(sec string, ptype string, rules [][]string) error {
+	tx, err := a.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := a.insert(tx, ptype, rules); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RemovePolicy removes a policy rule from the table.
+func (a *Adapter) RemovePolicy(sec string, ptype string, rule []string) error {
+	return a.RemovePolicies(sec, ptype, [][]string{rule})
+}
+
+// RemovePolicies removes policy rules from the table in one transaction.
+func (a *Adapter) RemovePolicies(sec string, ptype string, rules [][]string) error {
+	tx, err := a.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, rule := range rules {
+		args, err := ruleArgs(ptype, rule)
+		if err != nil {
+			return err
+		}
+		conditions := make([]string, len(args))
+		for i := range args {
+			conditions[i] = fmt.Sprintf("%s = %s", columnName(i), a.dialect.placeholder(i+1))
+		}
+		query := fmt.Sprintf("DELETE FROM %s WHERE %s", a.table, strings.Join(conditions, " AND "))
+		if _, err := tx.Exec(query, args...); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// RemoveFilteredPolicy removes the rules whose fields starting at fieldIndex
+// equal fieldValues, empty values match any value.
+func (a *Adapter) RemoveFilteredPolicy(sec string, ptype string, fieldIndex int, fieldValues ...string) error {
+	if fieldIndex < 0 || fieldIndex+len(fieldValues) > maxRuleFields {
+		return errors.New("adapter: invalid field index")
+	}
+	conditions := []string{"ptype = " + a.dialect.placeholder(1)}
+	args := []interface{}{ptype}
+	for i, value := range fieldValues {
+		if value == "" {
+			continue
+		}
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf("%s = %s", columnName(fieldIndex+i+1), a.dialect.placeholder(len(args))))
+	}
+	query := fmt.Sprintf("DELETE FROM %s WHERE %s", a.table, strings.Join(conditions, " AND "))
+	_, err := a.db.Exec(query, args...)
+	return err
+}
+
+func (a *Adapter) insert(tx *sql.Tx, ptype string, rules [][]string) error {
+	placeholders := make([]string, 1+maxRuleFields)
+	for i := range placeholders {
+		placeholders[i] = a.dialect.placeholder(i + 1)
+	}
+	stmt, err := tx.Prepare(fmt.Sprintf("INSERT INTO %s (ptype, v0, v1, v2, v3, v4, v5) VALUES (%s)",
+		a.table, strings.Join(placeholders, ", ")))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, rule := range rules {
+		args, err := ruleArgs(ptype, rule)
+		if err != nil {
+			return err
+		}
+		if _, err := stmt.Exec(args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ruleArgs 把规则补齐为 ptype, v0-v5 七列
+func ruleArgs(ptype string, rule []string) ([]interface{}, error) {
+	if len(rule) > maxRuleFields {
+		return nil, fmt.Errorf("adapter: rule has %d fields, at most %d are supported", len(rule), maxRuleFields)
+	}
+	args := make([]interface{}, 1+maxRuleFields)
+	args[0] = ptype
+	for i := 0; i < maxRuleFields; i++ {
+		if i < len(rule) {
+			args[i+1] = rule[i]
+		} else {
+			args[i+1] = ""
+		}
+	}
+	return args, nil
+}
+
+// columnName 第 0 列为 ptype, 之后依次为 v0-v5
+func columnName(i int) string {
+	if i == 0 {
+		return "ptype"
+	}
+	return fmt.Sprintf("v%d", i-1)
+}
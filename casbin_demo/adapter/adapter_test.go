@@ -0,0 +1,111 @@
+package adapter
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/casbin/casbin/v2"
+	_ "modernc.org/sqlite"
+)
+
+func newTestAdapter(t *testing.T) *Adapter {
+	t.Helper()
+	a, err := Open("sqlite", filepath.Join(t.TempDir(), "casbin.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { a.Close() })
+	n, err := ImportCSV(a, "../policy.csv")
+	if err != nil || n != 8 {
+		t.Fatalf("ImportCSV = %d, %v; 期望导入 8 条", n, err)
+	}
+	return a
+}
+
+func count(t *testing.T, a *Adapter) int {
+	t.Helper()
+	n, err := a.Count()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestImportCSVTwice(t *testing.T) {
+	a := newTestAdapter(t)
+	n, err := ImportCSV(a, "../policy.csv")
+	if err != nil || n != 0 {
+		t.Errorf("重复导入 = %d, %v; 期望 0", n, err)
+	}
+	if got := count(t, a); got != 8 {
+		t.Errorf("规则数 = %d; 期望值 8", got)
+	}
+}
+
+func TestIncrementalSave(t *testing.T) {
+	a := newTestAdapter(t)
+	e, err := casbin.NewEnforcer("../model.pml", a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := e.Enforce("zhangsan", "/users", "POST"); !ok {
+		t.Error("zhangsan 应该可以 POST /users")
+	}
+
+	if _, err := e.AddPolicy("wangwu", "/users", "POST"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.AddRoleForUser("lisi", "yunwei"); err != nil {
+		t.Fatal(err)
+	}
+	if got := count(t, a); got != 10 {
+		t.Errorf("新增后规则数 = %d; 期望值 10", got)
+	}
+
+	// 新的 enforcer 从数据库加载, 能看到增量保存的规则
+	e2, err := casbin.NewEnforcer("../model.pml", a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := e2.Enforce("wangwu", "/users", "POST"); !ok {
+		t.Error("wangwu 应该可以 POST /users")
+	}
+	if ok, _ := e2.Enforce("lisi", "/home", "GET"); !ok {
+		t.Error("lisi 应该继承 yunwei 的权限")
+	}
+
+	if _, err := e.RemovePolicy("wangwu", "/users", "POST"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.RemoveFilteredPolicy(0, "admin", "/users"); err != nil {
+		t.Fatal(err)
+	}
+	if got := count(t, a); got != 7 {
+		t.Errorf("删除后规则数 = %d; 期望值 7", got)
+	}
+}
+
+func TestLoadFilteredPolicy(t *testing.T) {
+	a := newTestAdapter(t)
+	e, err := casbin.NewEnforcer("../model.pml", a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.LoadFilteredPolicy(Filter{Ptype: []string{"p"}, V0: []string{"yunwei"}}); err != nil {
+		t.Fatal(err)
+	}
+	policies, err := e.GetPolicy()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(policies); got != 2 {
+		t.Errorf("过滤后策略数 = %d; 期望值 2", got)
+	}
+	if !e.IsFiltered() {
+		t.Error("IsFiltered 应该为 true")
+	}
+	// 过滤加载后不能整体保存, 避免覆盖未加载的规则
+	if err := e.SavePolicy(); err == nil {
+		t.Error("过滤加载后 SavePolicy 应该返回错误")
+	}
+}
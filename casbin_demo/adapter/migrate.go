@@ -0,0 +1,72 @@
+package adapter
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// ImportCSV imports a casbin policy.csv into the table and returns the number
+// of imported rules. Rules that already exist are skipped, so running the
+// migration twice is safe.
+func ImportCSV(a *Adapter, path string) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	// 与 casbin 的文件适配器使用相同的解析规则
+	r := csv.NewReader(file)
+	r.Comment = '#'
+	r.TrimLeadingSpace = true
+	r.FieldsPerRecord = -1
+
+	tx, err := a.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	existsQuery := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE ptype = %s", a.table, a.dialect.placeholder(1))
+	for i := 0; i < maxRuleFields; i++ {
+		existsQuery += fmt.Sprintf(" AND %s = %s", columnName(i+1), a.dialect.placeholder(i+2))
+	}
+	count := 0
+	for {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return count, err
+		}
+		if len(record) < 2 || strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+		ptype := strings.TrimSpace(record[0])
+		rule := make([]string, len(record)-1)
+		for i, field := range record[1:] {
+			rule[i] = strings.TrimSpace(field)
+		}
+		args, err := ruleArgs(ptype, rule)
+		if err != nil {
+			return count, err
+		}
+		var exists int
+		if err := tx.QueryRow(existsQuery, args...).Scan(&exists); err != nil {
+			return count, err
+		}
+		if exists > 0 {
+			continue
+		}
+		if err := a.insert(tx, ptype, [][]string{rule}); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, tx.Commit()
+}
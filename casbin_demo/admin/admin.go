@@ -0,0 +1,401 @@
+// Package admin 基于 Enforcer 的策略管理 HTTP API.
+//
+//	GET    /policies?page=1&size=20&sub=admin  分页查询权限, 可以按字段过滤
+//	POST   /policies                          添加权限 {"sub": "admin", "obj": "/users", "act": "GET"}
+//	DELETE /policies                          删除权限, 请求体同上
+//	GET    /roles                             分页查询角色
+//	DELETE /roles/{role}                      删除角色及其权限和用户关系
+//	GET    /roles/{role}/users                查询角色下的用户
+//	GET    /users/{user}/roles                查询用户的角色
+//	POST   /users/{user}/roles                给用户添加角色 {"role": "kuaiji"}
+//	DELETE /users/{user}/roles/{role}         删除用户的角色
+//
+// 权限的 JSON 字段名取自模型的 policy_definition, 去掉 "p_" 前缀.
+// Handler 本身不做鉴权, 需要挂在 authz.HTTPMiddleware 之后, 并通过 Config.Lock 与 authz.WithLocker 共用锁.
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"casbin_demo/authz"
+
+	"github.com/casbin/casbin/v2"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+	maxValueLength  = 255 // 与 adapter 中 v0-v5 列的长度一致
+	maxBodySize     = 1 << 16
+)
+
+// Config 配置 Handler, 字段都可以为空
+type Config struct {
+	// Save 每次修改成功后调用, 用于不支持增量保存的适配器 (例如 policy.csv).
+	// 为空表示适配器已经在 AddPolicy/RemovePolicy 时保存
+	Save func() error
+	// Audit 记录每次修改
+	Audit AuditFunc
+	// Actor 返回操作人, 默认取 authz 中间件放入 context 的 subject
+	Actor func(r *http.Request) string
+	// Lock 保护对 Enforcer 的访问, 默认使用 Handler 自己的锁.
+	// 与 authz.WithLocker 共用同一把锁, 鉴权和修改策略才不会并发访问 Enforcer
+	Lock sync.Locker
+}
+
+// Handler 策略管理 API
+type Handler struct {
+	mu       sync.Locker // Enforcer 不是并发安全的, 所有访问都加锁
+	enforcer *casbin.Enforcer
+	cfg      Config
+	mux      *http.ServeMux
+}
+
+// NewHandler creates the admin API for the enforcer.
+func NewHandler(e *casbin.Enforcer, cfg Config) *Handler {
+	if cfg.Actor == nil {
+		cfg.Actor = func(r *http.Request) string {
+			sub, _ := authz.SubjectFromContext(r.Context())
+			return sub
+		}
+	}
+	if cfg.Lock == nil {
+		cfg.Lock = &sync.Mutex{}
+	}
+	h := &Handler{mu: cfg.Lock, enforcer: e, cfg: cfg, mux: http.NewServeMux()}
+	h.mux.HandleFunc("GET /policies", h.listPolicies)
+	h.mux.HandleFunc("POST /policies", h.addPolicy)
+	h.mux.HandleFunc("DELETE /policies", h.removePolicy)
+	h.mux.HandleFunc("GET /roles", h.listRoles)
+	h.mux.HandleFunc("DELETE /roles/{role}", h.deleteRole)
+	h.mux.HandleFunc("GET /roles/{role}/users", h.listRoleUsers)
+	h.mux.HandleFunc("GET /users/{user}/roles", h.listUserRoles)
+	h.mux.HandleFunc("POST /users/{user}/roles", h.addUserRole)
+	h.mux.HandleFunc("DELETE /users/{user}/roles/{role}", h.removeUserRole)
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// Policy 一条权限, 键为模型中的字段名, 例如 sub, obj, act
+type Policy map[string]string
+
+// Page 分页结果
+type Page[T any] struct {
+	Items []T `json:"items"`
+	Total int `json:"total"`
+	Page  int `json:"page"`
+	Size  int `json:"size"`
+}
+
+func (h *Handler) listPolicies(w http.ResponseWriter, r *http.Request) {
+	page, size, err := pagination(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	h.mu.Lock()
+	fields := h.policyFields()
+	// 按查询参数过滤, 空字符串匹配任意值
+	filter := make([]string, len(fields))
+	for i, field := range fields {
+		filter[i] = r.URL.Query().Get(field)
+	}
+	rules, err := h.enforcer.GetFilteredPolicy(0, filter...)
+	h.mu.Unlock()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	policies := make([]Policy, len(rules))
+	for i, rule := range rules {
+		policies[i] = make(Policy, len(fields))
+		for j, field := range fields {
+			if j < len(rule) {
+				policies[i][field] = rule[j]
+			}
+		}
+	}
+	writeJSON(w, http.StatusOK, paginate(policies, page, size))
+}
+
+func (h *Handler) addPolicy(w http.ResponseWriter, r *http.Request) {
+	rule, err := h.decodePolicy(w, r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	h.change(w, r, "add_policy", rule, http.StatusCreated, http.StatusConflict, func() (bool, error) {
+		return h.enforcer.AddPolicy(rule)
+	}, func() error {
+		_, err := h.enforcer.RemovePolicy(rule)
+		return err
+	})
+}
+
+func (h *Handler) removePolicy(w http.ResponseWriter, r *http.Request) {
+	rule, err := h.decodePolicy(w, r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	h.change(w, r, "remove_policy", rule, http.StatusNoContent, http.StatusNotFound, func() (bool, error) {
+		return h.enforcer.RemovePolicy(rule)
+	}, func() error {
+		_, err := h.enforcer.AddPolicy(rule)
+		return err
+	})
+}
+
+func (h *Handler) listRoles(w http.ResponseWriter, r *http.Request) {
+	page, size, err := pagination(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	h.mu.Lock()
+	roles, err := h.enforcer.GetAllRoles()
+	h.mu.Unlock()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	slices.Sort(roles)
+	writeJSON(w, http.StatusOK, paginate(roles, page, size))
+}
+
+func (h *Handler) deleteRole(w http.ResponseWriter, r *http.Request) {
+	role := r.PathValue("role")
+	if err := validate("role", role); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	// 删除前记下角色相关的规则, 保存失败时逐条加回
+	var groupings, policies [][]string
+	h.change(w, r, "delete_role", []string{role}, http.StatusNoContent, http.StatusNotFound, func() (bool, error) {
+		inherits, err := h.enforcer.GetFilteredGroupingPolicy(0, role)
+		if err != nil {
+			return false, err
+		}
+		users, err := h.enforcer.GetFilteredGroupingPolicy(1, role)
+		if err != nil {
+			return false, err
+		}
+		if policies, err = h.enforcer.GetFilteredPolicy(0, role); err != nil {
+			return false, err
+		}
+		groupings = append(inherits, users...)
+		return h.enforcer.DeleteRole(role)
+	}, func() error {
+		var errs []error
+		for _, rule := range groupings {
+			_, err := h.enforcer.AddGroupingPolicy(rule)
+			errs = append(errs, err)
+		}
+		for _, rule := range policies {
+			_, err := h.enforcer.AddPolicy(rule)
+			errs = append(errs, err)
+		}
+		return errors.Join(errs...)
+	})
+}
+
+func (h *Handler) listRoleUsers(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	users, err := h.enforcer.GetUsersForRole(r.PathValue("role"))
+	h.mu.Unlock()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	slices.Sort(users)
+	writeJSON(w, http.StatusOK, nonNil(users))
+}
+
+func (h *Handler) listUserRoles(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	roles, err := h.enforcer.GetRolesForUser(r.PathValue("user"))
+	h.mu.Unlock()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	slices.Sort(roles)
+	writeJSON(w, http.StatusOK, nonNil(roles))
+}
+
+func (h *Handler) addUserRole(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Role string `json:"role"`
+	}
+	user := r.PathValue("user")
+	err := decodeJSON(w, r, &body)
+	if err == nil {
+		err = errors.Join(validate("user", user), validate("role", body.Role))
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	h.change(w, r, "add_role_for_user", []string{user, body.Role}, http.StatusCreated, http.StatusConflict, func() (bool, error) {
+		return h.enforcer.AddRoleForUser(user, body.Role)
+	}, func() error {
+		_, err := h.enforcer.DeleteRoleForUser(user, body.Role)
+		return err
+	})
+}
+
+func (h *Handler) removeUserRole(w http.ResponseWriter, r *http.Request) {
+	user, role := r.PathValue("user"), r.PathValue("role")
+	if err := errors.Join(validate("user", user), validate("role", role)); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	h.change(w, r, "delete_role_for_user", []string{user, role}, http.StatusNoContent, http.StatusNotFound, func() (bool, error) {
+		return h.enforcer.DeleteRoleForUser(user, role)
+	}, func() error {
+		_, err := h.enforcer.AddRoleForUser(user, role)
+		return err
+	})
+}
+
+// change 在锁内执行修改, 成功后保存并记录审计日志. 保存失败时在释放锁之前调用 undo
+// 撤销内存中的修改. 修改生效时返回 okStatus, 没有变化 (已存在或不存在) 时返回 noopStatus
+func (h *Handler) change(w http.ResponseWriter, r *http.Request, action string, params []string, okStatus, noopStatus int, fn func() (bool, error), undo func() error) {
+	h.mu.Lock()
+	changed, err := fn()
+	if err == nil && changed && h.cfg.Save != nil {
+		if err = h.cfg.Save(); err != nil {
+			err = errors.Join(err, undo())
+		}
+	}
+	h.mu.Unlock()
+
+	if h.cfg.Audit != nil && (changed || err != nil) {
+		entry := AuditEntry{Time: time.Now(), Actor: h.cfg.Actor(r), Action: action, Params: params}
+		if err != nil {
+			entry.Error = err.Error()
+		}
+		h.cfg.Audit(entry)
+	}
+	switch {
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err)
+	case !changed:
+		writeError(w, noopStatus, errors.New(http.StatusText(noopStatus)))
+	case okStatus == http.StatusNoContent:
+		w.WriteHeader(okStatus)
+	default:
+		writeJSON(w, okStatus, params)
+	}
+}
+
+// policyFields 返回模型中 p 的字段名, 例如 [sub obj act]
+func (h *Handler) policyFields() []string {
+	tokens := h.enforcer.GetModel()["p"]["p"].Tokens
+	fields := make([]string, len(tokens))
+	for i, token := range tokens {
+		fields[i] = strings.TrimPrefix(token, "p_")
+	}
+	return fields
+}
+
+// decodePolicy 把请求体转换为按模型字段排列的规则
+func (h *Handler) decodePolicy(w http.ResponseWriter, r *http.Request) ([]string, error) {
+	var policy Policy
+	if err := decodeJSON(w, r, &policy); err != nil {
+		return nil, err
+	}
+	h.mu.Lock()
+	fields := h.policyFields()
+	h.mu.Unlock()
+
+	rule := make([]string, len(fields))
+	var errs []error
+	for i, field := range fields {
+		rule[i] = policy[field]
+		errs = append(errs, validate(field, rule[i]))
+		delete(policy, field)
+	}
+	for field := range policy {
+		errs = append(errs, fmt.Errorf("unknown field %q", field))
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+// validate 检查字段不为空, 不超过数据库列长度, 并且可以安全写入 policy.csv
+func validate(field, value string) error {
+	switch {
+	case value == "":
+		return fmt.Errorf("%s is required", field)
+	case len(value) > maxValueLength:
+		return fmt.Errorf("%s is longer than %d bytes", field, maxValueLength)
+	case strings.TrimSpace(value) != value:
+		return fmt.Errorf("%s has leading or trailing spaces", field)
+	case strings.ContainsAny(value, ",\""):
+		return fmt.Errorf("%s must not contain commas or quotes", field)
+	case strings.IndexFunc(value, unicode.IsControl) >= 0:
+		return fmt.Errorf("%s must not contain control characters", field)
+	}
+	return nil
+}
+
+func pagination(r *http.Request) (page, size int, err error) {
+	page, size = 1, defaultPageSize
+	if v := r.URL.Query().Get("page"); v != "" {
+		if page, err = strconv.Atoi(v); err != nil || page < 1 {
+			return 0, 0, fmt.Errorf("invalid page %q", v)
+		}
+	}
+	if v := r.URL.Query().Get("size"); v != "" {
+		if size, err = strconv.Atoi(v); err != nil || size < 1 || size > maxPageSize {
+			return 0, 0, fmt.Errorf("invalid size %q, must be between 1 and %d", v, maxPageSize)
+		}
+	}
+	return page, size, nil
+}
+
+func paginate[T any](items []T, page, size int) Page[T] {
+	start := min((page-1)*size, len(items))
+	end := min(start+size, len(items))
+	return Page[T]{Items: nonNil(items[start:end]), Total: len(items), Page: page, Size: size}
+}
+
+// nonNil 让空列表编码为 [] 而不是 null
+func nonNil[T any](items []T) []T {
+	if items == nil {
+		return []T{}
+	}
+	return items
+}
+
+func decodeJSON(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize)).Decode(v); err != nil {
+		return fmt.Errorf("invalid request body: %w", err)
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,257 @@
+package admin
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"casbin_demo/adapter"
+	"casbin_demo/authz"
+
+	"github.com/casbin/casbin/v2"
+	_ "modernc.org/sqlite"
+)
+
+type testServer struct {
+	handler *Handler
+	adapter *adapter.Adapter
+	audit   *bytes.Buffer
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	a, err := adapter.Open("sqlite", filepath.Join(t.TempDir(), "casbin.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { a.Close() })
+	if _, err := adapter.ImportCSV(a, "../policy.csv"); err != nil {
+		t.Fatal(err)
+	}
+	e, err := casbin.NewEnforcer("../model.pml", a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	audit := &bytes.Buffer{}
+	h := NewHandler(e, Config{
+		Audit: JSONAuditLog(audit),
+		Actor: func(r *http.Request) string { return r.Header.Get("X-User") },
+	})
+	return &testServer{handler: h, adapter: a, audit: audit}
+}
+
+func (s *testServer) do(method, path, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	r.Header.Set("X-User", "root")
+	w := httptest.NewRecorder()
+	s.handler.ServeHTTP(w, r)
+	return w
+}
+
+func TestPolicies(t *testing.T) {
+	s := newTestServer(t)
+	cases := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{"添加权限", http.MethodPost, "/policies", `{"sub":"kuaiji","obj":"/roleusers","act":"GET"}`, http.StatusCreated},
+		{"重复添加", http.MethodPost, "/policies", `{"sub":"kuaiji","obj":"/roleusers","act":"GET"}`, http.StatusConflict},
+		{"缺少字段", http.MethodPost, "/policies", `{"sub":"kuaiji","obj":"/roleusers"}`, http.StatusBadRequest},
+		{"未知字段", http.MethodPost, "/policies", `{"sub":"a","obj":"/b","act":"GET","dom":"x"}`, http.StatusBadRequest},
+		{"包含逗号", http.MethodPost, "/policies", `{"sub":"a,b","obj":"/b","act":"GET"}`, http.StatusBadRequest},
+		{"不是 JSON", http.MethodPost, "/policies", `sub=a`, http.StatusBadRequest},
+		{"删除权限", http.MethodDelete, "/policies", `{"sub":"admin","obj":"/users","act":"POST"}`, http.StatusNoContent},
+		{"删除不存在的权限", http.MethodDelete, "/policies", `{"sub":"admin","obj":"/users","act":"POST"}`, http.StatusNotFound},
+		{"分页参数错误", http.MethodGet, "/policies?size=1000", "", http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if w := s.do(tc.method, tc.path, tc.body); w.Code != tc.status {
+				t.Errorf("状态码 = %d; 期望值 %d, 响应 %s", w.Code, tc.status, w.Body)
+			}
+		})
+	}
+
+	// 修改已经通过适配器保存: 导入 6 条 p + 2 条 g, 加 1 删 1
+	if n, _ := s.adapter.Count(); n != 8 {
+		t.Errorf("数据库规则数 = %d; 期望值 8", n)
+	}
+	if lines := strings.Count(s.audit.String(), "\n"); lines != 2 {
+		t.Errorf("审计日志 %d 条; 期望值 2:\n%s", lines, s.audit)
+	}
+	var entry AuditEntry
+	json.Unmarshal([]byte(strings.SplitN(s.audit.String(), "\n", 2)[0]), &entry)
+	if entry.Actor != "root" || entry.Action != "add_policy" || strings.Join(entry.Params, ",") != "kuaiji,/roleusers,GET" {
+		t.Errorf("审计记录 = %+v", entry)
+	}
+}
+
+func TestListPolicies(t *testing.T) {
+	s := newTestServer(t)
+	cases := []struct {
+		name  string
+		query string
+		total int
+		items int
+	}{
+		{"全部", "", 6, 6},
+		{"分页", "?page=2&size=4", 6, 2},
+		{"超出范围", "?page=5&size=4", 6, 0},
+		{"按 sub 过滤", "?sub=yunwei", 2, 2},
+		{"按 sub 和 act 过滤", "?sub=admin&act=POST", 1, 1},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := s.do(http.MethodGet, "/policies"+tc.query, "")
+			var page Page[Policy]
+			if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+				t.Fatal(err)
+			}
+			if page.Total != tc.total || len(page.Items) != tc.items {
+				t.Errorf("total = %d, items = %d; 期望值 %d, %d", page.Total, len(page.Items), tc.total, tc.items)
+			}
+		})
+	}
+}
+
+func TestUserRoles(t *testing.T) {
+	s := newTestServer(t)
+	if w := s.do(http.MethodPost, "/users/lisi/roles", `{"role":"yunwei"}`); w.Code != http.StatusCreated {
+		t.Fatalf("添加角色状态码 = %d; 期望值 %d", w.Code, http.StatusCreated)
+	}
+	var users []string
+	json.Unmarshal(s.do(http.MethodGet, "/roles/yunwei/users", "").Body.Bytes(), &users)
+	if strings.Join(users, ",") != "lisi,wangwu" {
+		t.Errorf("yunwei 的用户 = %v", users)
+	}
+	if w := s.do(http.MethodDelete, "/users/lisi/roles/yunwei", ""); w.Code != http.StatusNoContent {
+		t.Errorf("删除角色状态码 = %d; 期望值 %d", w.Code, http.StatusNoContent)
+	}
+	var roles []string
+	json.Unmarshal(s.do(http.MethodGet, "/users/lisi/roles", "").Body.Bytes(), &roles)
+	if roles == nil || len(roles) != 0 {
+		t.Errorf("lisi 的角色 = %v; 期望空列表", roles)
+	}
+
+	// 删除角色同时删除其权限和用户关系
+	if w := s.do(http.MethodDelete, "/roles/yunwei", ""); w.Code != http.StatusNoContent {
+		t.Errorf("删除角色状态码 = %d; 期望值 %d", w.Code, http.StatusNoContent)
+	}
+	if n, _ := s.adapter.Count(); n != 5 {
+		t.Errorf("数据库规则数 = %d; 期望值 5", n)
+	}
+}
+
+func TestSaveFailure(t *testing.T) {
+	e, err := casbin.NewEnforcer("../model.pml", "../policy.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 只修改内存中的策略, 不写回 policy.csv
+	e.EnableAutoSave(false)
+	h := NewHandler(e, Config{Save: func() error { return errors.New("disk full") }})
+	policies, _ := e.GetPolicy()
+	groupings, _ := e.GetGroupingPolicy()
+
+	cases := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+	}{
+		{"添加权限", http.MethodPost, "/policies", `{"sub":"kuaiji","obj":"/roleusers","act":"GET"}`},
+		{"删除权限", http.MethodDelete, "/policies", `{"sub":"admin","obj":"/users","act":"POST"}`},
+		{"删除角色", http.MethodDelete, "/roles/yunwei", ""},
+		{"添加用户角色", http.MethodPost, "/users/lisi/roles", `{"role":"yunwei"}`},
+		{"删除用户角色", http.MethodDelete, "/users/zhangsan/roles/admin", ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body)))
+			if w.Code != http.StatusInternalServerError {
+				t.Errorf("状态码 = %d; 期望值 %d", w.Code, http.StatusInternalServerError)
+			}
+			// 保存失败后内存中的策略应该保持不变
+			if got, _ := e.GetPolicy(); !sameRules(got, policies) {
+				t.Errorf("p 规则 = %v; 期望值 %v", got, policies)
+			}
+			if got, _ := e.GetGroupingPolicy(); !sameRules(got, groupings) {
+				t.Errorf("g 规则 = %v; 期望值 %v", got, groupings)
+			}
+		})
+	}
+}
+
+// sameRules 忽略顺序比较两组规则
+func sameRules(a, b [][]string) bool {
+	key := func(rules [][]string) []string {
+		keys := make([]string, len(rules))
+		for i, rule := range rules {
+			keys[i] = strings.Join(rule, ",")
+		}
+		slices.Sort(keys)
+		return keys
+	}
+	return slices.Equal(key(a), key(b))
+}
+
+func TestBehindAuthz(t *testing.T) {
+	e, err := casbin.NewEnforcer("../model.pml", "../policy.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 只修改内存中的策略, 不写回 policy.csv
+	e.EnableAutoSave(false)
+	e.AddPolicy("admin", "/admin", http.MethodPost)
+
+	var mu sync.Mutex
+	audit := &bytes.Buffer{}
+	a := authz.New(e, authz.StaticTokens(map[string]string{"t-zhangsan": "zhangsan", "t-wangwu": "wangwu"}), authz.WithLocker(&mu))
+	handler := a.HTTPMiddleware(authz.HTTPConfig{
+		Object: func(r *http.Request) string { return "/admin" },
+	})(NewHandler(e, Config{Audit: JSONAuditLog(audit), Lock: &mu}))
+
+	cases := []struct {
+		name   string
+		auth   string
+		status int
+	}{
+		{"没有 token", "", http.StatusUnauthorized},
+		{"token 不是用户名", "Bearer zhangsan", http.StatusUnauthorized},
+		{"运维没有权限", "Bearer t-wangwu", http.StatusForbidden},
+		{"管理员", "Bearer t-zhangsan", http.StatusCreated},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/policies", strings.NewReader(`{"sub":"kuaiji","obj":"/roleusers","act":"GET"}`))
+			if tc.auth != "" {
+				r.Header.Set("Authorization", tc.auth)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != tc.status {
+				t.Errorf("状态码 = %d; 期望值 %d", w.Code, tc.status)
+			}
+		})
+	}
+
+	// 审计日志的操作人取自认证后的 subject
+	var entry AuditEntry
+	if err := json.Unmarshal(audit.Bytes(), &entry); err != nil {
+		t.Fatalf("审计日志 %q: %v", audit, err)
+	}
+	if entry.Actor != "zhangsan" {
+		t.Errorf("审计操作人 = %q; 期望值 zhangsan", entry.Actor)
+	}
+}
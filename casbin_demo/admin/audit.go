@@ -0,0 +1,34 @@
+package admin
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"sync"
+	"time"
+)
+
+// AuditEntry 一次策略修改的审计记录
+type AuditEntry struct {
+	Time   time.Time `json:"time"`
+	Actor  string    `json:"actor"`
+	Action string    `json:"action"` // add_policy, remove_policy, delete_role, add_role_for_user, delete_role_for_user
+	Params []string  `json:"params"`
+	Error  string    `json:"error,omitempty"`
+}
+
+// AuditFunc 接收审计记录, 由 Handler 在修改后同步调用
+type AuditFunc func(entry AuditEntry)
+
+// JSONAuditLog writes each entry as one JSON line to w.
+func JSONAuditLog(w io.Writer) AuditFunc {
+	var mu sync.Mutex
+	enc := json.NewEncoder(w)
+	return func(entry AuditEntry) {
+		mu.Lock()
+		defer mu.Unlock()
+		if err := enc.Encode(entry); err != nil {
+			log.Printf("admin: write audit log: %v", err)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+// Package authz 基于 casbin 的鉴权中间件, 支持 net/http、Fiber 和 gRPC.
+//
+// 每个请求被映射为 (subject, object, action) 三元组交给 Enforcer 判断:
+// subject 默认取自 Authorization: Bearer <token>, 由 New 传入的 TokenFunc 校验 token 并解析为用户,
+// object 为请求路径或 gRPC 完整方法名, action 为 HTTP 方法或 gRPC 调用类型.
+package authz
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/casbin/casbin/v2"
+)
+
+var (
+	// ErrUnauthenticated 请求中没有找到 subject
+	ErrUnauthenticated = errors.New("authz: unauthenticated")
+	// ErrForbidden Enforcer 拒绝了请求
+	ErrForbidden = errors.New("authz: forbidden")
+)
+
+// TokenFunc 校验请求中的 token 并解析为 subject, 返回错误表示 token 无效
+type TokenFunc func(token string) (string, error)
+
+// errNoVerifier 没有配置 TokenFunc, 拒绝所有 token
+var errNoVerifier = errors.New("no token verifier configured")
+
+// StaticTokens returns a TokenFunc accepting only the tokens in the map, each
+// mapped to its subject.
+func StaticTokens(tokens map[string]string) TokenFunc {
+	return func(token string) (string, error) {
+		if sub, ok := tokens[token]; ok && sub != "" {
+			return sub, nil
+		}
+		return "", errors.New("unknown token")
+	}
+}
+
+// Authorizer 包装 *casbin.Enforcer, 供各个中间件共用
+type Authorizer struct {
+	enforcer *casbin.Enforcer
+	token    TokenFunc
+	lock     sync.Locker // 为 nil 时不加锁
+}
+
+// Option 配置 Authorizer
+type Option func(a *Authorizer)
+
+// WithLocker makes Authorize hold l while calling the enforcer. Use the lock
+// that guards policy changes, e.g. admin.Config.Lock, when the policy is
+// modified while requests are being authorized.
+func WithLocker(l sync.Locker) Option {
+	return func(a *Authorizer) {
+		a.lock = l
+	}
+}
+
+// New creates an Authorizer for the enforcer. verify turns a bearer token into
+// a subject; if it is nil every bearer token is rejected, so requests are only
+// authenticated by a custom Subject function.
+func New(e *casbin.Enforcer, verify TokenFunc, opts ...Option) *Authorizer {
+	if verify == nil {
+		verify = func(token string) (string, error) { return "", errNoVerifier }
+	}
+	a := &Authorizer{enforcer: e, token: verify}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// Enforcer returns the wrapped enforcer.
+func (a *Authorizer) Enforcer() *casbin.Enforcer {
+	return a.enforcer
+}
+
+// Authorize returns nil if sub may perform act on obj. An empty subject yields
+// ErrUnauthenticated and a denied request an error wrapping ErrForbidden.
+func (a *Authorizer) Authorize(sub, obj, act string) error {
+	if sub == "" {
+		return ErrUnauthenticated
+	}
+	if a.lock != nil {
+		a.lock.Lock()
+		defer a.lock.Unlock()
+	}
+	ok, err := a.enforcer.Enforce(sub, obj, act)
+	if err != nil {
+		return fmt.Errorf("authz: enforce: %w", err)
+	}
+	if !ok {
+		return fmt.Errorf("%w: %s cannot %s %s", ErrForbidden, sub, act, obj)
+	}
+	return nil
+}
+
+type subjectKey struct{}
+
+// ContextWithSubject returns a copy of ctx carrying the authorized subject.
+func ContextWithSubject(ctx context.Context, sub string) context.Context {
+	return context.WithValue(ctx, subjectKey{}, sub)
+}
+
+// SubjectFromContext returns the subject stored by the HTTP middleware or the
+// gRPC interceptors.
+func SubjectFromContext(ctx context.Context) (string, bool) {
+	sub, ok := ctx.Value(subjectKey{}).(string)
+	return sub, ok
+}
+
+// subjectFromAuthorization 解析 "Bearer <token>", 没有 Authorization 时返回空字符串
+func (a *Authorizer) subjectFromAuthorization(header string) (string, error) {
+	if header == "" {
+		return "", nil
+	}
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+		return "", fmt.Errorf("%w: malformed authorization header", ErrUnauthenticated)
+	}
+	sub, err := a.token(strings.TrimSpace(token))
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrUnauthenticated, err)
+	}
+	return sub, nil
+}
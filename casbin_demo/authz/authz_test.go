@@ -0,0 +1,193 @@
+package authz
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/gofiber/fiber/v2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// tokens 测试用的 token 到用户的映射
+var tokens = map[string]string{"t-zhangsan": "zhangsan", "t-wangwu": "wangwu"}
+
+func newAuthorizer(t *testing.T) *Authorizer {
+	t.Helper()
+	e, err := casbin.NewEnforcer("../model.pml", "../policy.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 只修改内存中的策略, 不写回 policy.csv
+	e.EnableAutoSave(false)
+	e.AddPolicy("admin", "/helloworld.Greeter/SayHello", ActionUnary)
+	e.AddPolicy("admin", "/helloworld.Greeter/Chat", ActionBidiStream)
+	return New(e, StaticTokens(tokens))
+}
+
+var httpCases = []struct {
+	name   string
+	auth   string
+	method string
+	path   string
+	status int
+}{
+	{"管理员访问", "Bearer t-zhangsan", http.MethodPost, "/users", http.StatusOK},
+	{"运维没有权限", "Bearer t-wangwu", http.MethodPost, "/users", http.StatusForbidden},
+	{"运维访问首页", "Bearer t-wangwu", http.MethodGet, "/home", http.StatusOK},
+	{"没有 token", "", http.MethodGet, "/home", http.StatusUnauthorized},
+	{"无效 token", "Bearer nobody", http.MethodGet, "/home", http.StatusUnauthorized},
+	{"格式错误", "Basic abc", http.MethodGet, "/home", http.StatusUnauthorized},
+}
+
+func TestHTTPMiddleware(t *testing.T) {
+	a := newAuthorizer(t)
+	handler := a.HTTPMiddleware(HTTPConfig{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for _, tc := range httpCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(tc.method, tc.path, nil)
+			if tc.auth != "" {
+				r.Header.Set("Authorization", tc.auth)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != tc.status {
+				t.Errorf("状态码 = %d; 期望值 %d", w.Code, tc.status)
+			}
+		})
+	}
+}
+
+func TestHTTPMiddlewareCustomDeny(t *testing.T) {
+	a := newAuthorizer(t)
+	handler := a.HTTPMiddleware(HTTPConfig{
+		Subject: func(r *http.Request) (string, error) { return r.Header.Get("X-User"), nil },
+		Deny: func(w http.ResponseWriter, r *http.Request, err error) {
+			w.WriteHeader(http.StatusNotFound)
+		},
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	r := httptest.NewRequest(http.MethodPost, "/users", nil)
+	r.Header.Set("X-User", "wangwu")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("状态码 = %d; 期望值 %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestFiberMiddleware(t *testing.T) {
+	a := newAuthorizer(t)
+	app := fiber.New()
+	app.Use(a.FiberMiddleware(FiberConfig{}))
+	app.All("/*", func(c *fiber.Ctx) error { return c.SendStatus(http.StatusOK) })
+	for _, tc := range httpCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(tc.method, tc.path, nil)
+			if tc.auth != "" {
+				r.Header.Set("Authorization", tc.auth)
+			}
+			resp, err := app.Test(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tc.status {
+				t.Errorf("状态码 = %d; 期望值 %d", resp.StatusCode, tc.status)
+			}
+		})
+	}
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	a := newAuthorizer(t)
+	interceptor := a.UnaryServerInterceptor(GRPCConfig{})
+	info := &grpc.UnaryServerInfo{FullMethod: "/helloworld.Greeter/SayHello"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		sub, _ := SubjectFromContext(ctx)
+		return sub, nil
+	}
+
+	cases := []struct {
+		name string
+		md   metadata.MD
+		code codes.Code
+		msg  string
+	}{
+		{"管理员调用", metadata.Pairs("authorization", "Bearer t-zhangsan"), codes.OK, ""},
+		{"运维没有权限", metadata.Pairs("authorization", "Bearer t-wangwu"), codes.PermissionDenied, "permission denied"},
+		{"没有 metadata", nil, codes.Unauthenticated, "unauthenticated"},
+		{"token 无效", metadata.Pairs("authorization", "Bearer unknown"), codes.Unauthenticated, "unauthenticated"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tc.md != nil {
+				ctx = metadata.NewIncomingContext(ctx, tc.md)
+			}
+			sub, err := interceptor(ctx, nil, info, handler)
+			if code := status.Code(err); code != tc.code {
+				t.Errorf("状态码 = %v; 期望值 %v", code, tc.code)
+			}
+			// 拒绝时只返回固定的信息, 不暴露内部错误
+			if msg := status.Convert(err).Message(); msg != tc.msg {
+				t.Errorf("错误信息 = %q; 期望值 %q", msg, tc.msg)
+			}
+			if tc.code == codes.OK && sub != "zhangsan" {
+				t.Errorf("handler 中的 subject = %v; 期望值 zhangsan", sub)
+			}
+		})
+	}
+}
+
+type fakeStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s fakeStream) Context() context.Context { return s.ctx }
+
+func TestStreamServerInterceptor(t *testing.T) {
+	a := newAuthorizer(t)
+	interceptor := a.StreamServerInterceptor(GRPCConfig{})
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer t-zhangsan"))
+	var sub string
+	handler := func(srv interface{}, stream grpc.ServerStream) error {
+		sub, _ = SubjectFromContext(stream.Context())
+		return nil
+	}
+
+	bidi := &grpc.StreamServerInfo{FullMethod: "/helloworld.Greeter/Chat", IsClientStream: true, IsServerStream: true}
+	if err := interceptor(nil, fakeStream{ctx: ctx}, bidi, handler); err != nil {
+		t.Errorf("双向流 = %v; 期望允许", err)
+	}
+	if sub != "zhangsan" {
+		t.Errorf("handler 中的 subject = %q; 期望值 zhangsan", sub)
+	}
+	// 策略只允许双向流, 服务端流应该被拒绝
+	serverStream := &grpc.StreamServerInfo{FullMethod: "/helloworld.Greeter/Chat", IsServerStream: true}
+	if err := interceptor(nil, fakeStream{ctx: ctx}, serverStream, handler); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("服务端流 = %v; 期望 PermissionDenied", err)
+	}
+}
+
+func TestNoTokenVerifier(t *testing.T) {
+	e, err := casbin.NewEnforcer("../model.pml", "../policy.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 没有 TokenFunc 时 token 不能直接作为 subject 使用
+	handler := New(e, nil).HTTPMiddleware(HTTPConfig{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for _, auth := range []string{"Bearer admin", "Bearer zhangsan"} {
+		r := httptest.NewRequest(http.MethodGet, "/home", nil)
+		r.Header.Set("Authorization", auth)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("%s 状态码 = %d; 期望值 %d", auth, w.Code, http.StatusUnauthorized)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package authz
+
+import (
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// FiberConfig 配置 Fiber 中间件, 字段为空时使用默认值
+type FiberConfig struct {
+	// Subject 默认取 Authorization: Bearer <token>
+	Subject func(c *fiber.Ctx) (string, error)
+	// Object 默认为 c.Path()
+	Object func(c *fiber.Ctx) string
+	// Action 默认为 c.Method()
+	Action func(c *fiber.Ctx) string
+	// Deny 返回拒绝响应, 默认 401/403 纯文本
+	Deny func(c *fiber.Ctx, err error) error
+}
+
+// FiberMiddleware returns a Fiber handler that rejects requests the enforcer
+// does not allow.
+func (a *Authorizer) FiberMiddleware(cfg FiberConfig) fiber.Handler {
+	if cfg.Subject == nil {
+		cfg.Subject = func(c *fiber.Ctx) (string, error) {
+			return a.subjectFromAuthorization(c.Get(fiber.HeaderAuthorization))
+		}
+	}
+	if cfg.Object == nil {
+		cfg.Object = func(c *fiber.Ctx) string { return c.Path() }
+	}
+	if cfg.Action == nil {
+		cfg.Action = func(c *fiber.Ctx) string { return c.Method() }
+	}
+	if cfg.Deny == nil {
+		cfg.Deny = func(c *fiber.Ctx, err error) error {
+			status := HTTPStatus(err)
+			return c.Status(status).SendString(http.StatusText(status))
+		}
+	}
+	return func(c *fiber.Ctx) error {
+		sub, err := cfg.Subject(c)
+		if err == nil {
+			err = a.Authorize(sub, cfg.Object(c), cfg.Action(c))
+		}
+		if err != nil {
+			return cfg.Deny(c, err)
+		}
+		return c.Next()
+	}
+}
@@ -0,0 +1,136 @@
+package authz
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// gRPC 调用类型, 作为 action 使用
+const (
+	ActionUnary        = "unary"
+	ActionClientStream = "client_stream"
+	ActionServerStream = "server_stream"
+	ActionBidiStream   = "bidi_stream"
+)
+
+// GRPCConfig 配置 gRPC 拦截器, 字段为空时使用默认值
+type GRPCConfig struct {
+	// Subject 默认取 metadata 中的 authorization: Bearer <token>
+	Subject func(ctx context.Context) (string, error)
+	// Deny 把鉴权错误转换为返回给客户端的错误, 默认记录日志并返回不含细节的
+	// Unauthenticated/PermissionDenied 状态
+	Deny func(ctx context.Context, fullMethod string, err error) error
+}
+
+func (a *Authorizer) grpcDefaults(cfg GRPCConfig) GRPCConfig {
+	if cfg.Subject == nil {
+		cfg.Subject = func(ctx context.Context) (string, error) {
+			md, _ := metadata.FromIncomingContext(ctx)
+			if values := md.Get("authorization"); len(values) > 0 {
+				return a.subjectFromAuthorization(values[0])
+			}
+			return "", nil
+		}
+	}
+	if cfg.Deny == nil {
+		cfg.Deny = func(ctx context.Context, fullMethod string, err error) error {
+			log.Printf("authz: deny %s: %v", fullMethod, err)
+			code := GRPCCode(err)
+			return status.Error(code, grpcMessage(code))
+		}
+	}
+	return cfg
+}
+
+// authorizeGRPC 通过时返回携带 subject 的 ctx
+func (a *Authorizer) authorizeGRPC(ctx context.Context, cfg GRPCConfig, fullMethod, action string) (context.Context, error) {
+	sub, err := cfg.Subject(ctx)
+	if err == nil {
+		err = a.Authorize(sub, fullMethod, action)
+	}
+	if err != nil {
+		return nil, cfg.Deny(ctx, fullMethod, err)
+	}
+	return ContextWithSubject(ctx, sub), nil
+}
+
+// UnaryServerInterceptor authorizes unary calls with the full method name as
+// object and "unary" as action. Allowed calls carry the subject in their
+// context, see SubjectFromContext.
+func (a *Authorizer) UnaryServerInterceptor(cfg GRPCConfig) grpc.UnaryServerInterceptor {
+	cfg = a.grpcDefaults(cfg)
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := a.authorizeGRPC(ctx, cfg, info.FullMethod, ActionUnary)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor authorizes streaming calls with the full method name
+// as object and the stream type as action. Allowed streams carry the subject
+// in their context, see SubjectFromContext.
+func (a *Authorizer) StreamServerInterceptor(cfg GRPCConfig) grpc.StreamServerInterceptor {
+	cfg = a.grpcDefaults(cfg)
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := a.authorizeGRPC(ss.Context(), cfg, info.FullMethod, streamAction(info))
+		if err != nil {
+			return err
+		}
+		return handler(srv, &subjectStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// subjectStream 替换 Context, 让 handler 能取到 subject
+type subjectStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *subjectStream) Context() context.Context {
+	return s.ctx
+}
+
+func streamAction(info *grpc.StreamServerInfo) string {
+	switch {
+	case info.IsClientStream && info.IsServerStream:
+		return ActionBidiStream
+	case info.IsClientStream:
+		return ActionClientStream
+	default:
+		return ActionServerStream
+	}
+}
+
+// GRPCCode maps an Authorize error to a gRPC status code.
+func GRPCCode(err error) codes.Code {
+	switch {
+	case err == nil:
+		return codes.OK
+	case errors.Is(err, ErrUnauthenticated):
+		return codes.Unauthenticated
+	case errors.Is(err, ErrForbidden):
+		return codes.PermissionDenied
+	default:
+		return codes.Internal
+	}
+}
+
+// grpcMessage 返回给客户端的固定错误信息, 不暴露策略和内部错误
+func grpcMessage(code codes.Code) string {
+	switch code {
+	case codes.Unauthenticated:
+		return "unauthenticated"
+	case codes.PermissionDenied:
+		return "permission denied"
+	default:
+		return "authorization failed"
+	}
+}
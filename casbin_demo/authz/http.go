@@ -0,0 +1,68 @@
+package authz
+
+import (
+	"errors"
+	"net/http"
+)
+
+// HTTPConfig 配置 net/http 中间件, 字段为空时使用默认值
+type HTTPConfig struct {
+	// Subject 默认取 Authorization: Bearer <token>
+	Subject func(r *http.Request) (string, error)
+	// Object 默认为 r.URL.Path
+	Object func(r *http.Request) string
+	// Action 默认为 r.Method
+	Action func(r *http.Request) string
+	// Deny 写入拒绝响应, 默认 401/403 纯文本
+	Deny func(w http.ResponseWriter, r *http.Request, err error)
+}
+
+// HTTPMiddleware returns a net/http middleware that rejects requests the
+// enforcer does not allow. Allowed requests carry the subject in their
+// context, see SubjectFromContext.
+func (a *Authorizer) HTTPMiddleware(cfg HTTPConfig) func(http.Handler) http.Handler {
+	if cfg.Subject == nil {
+		cfg.Subject = func(r *http.Request) (string, error) {
+			return a.subjectFromAuthorization(r.Header.Get("Authorization"))
+		}
+	}
+	if cfg.Object == nil {
+		cfg.Object = func(r *http.Request) string { return r.URL.Path }
+	}
+	if cfg.Action == nil {
+		cfg.Action = func(r *http.Request) string { return r.Method }
+	}
+	if cfg.Deny == nil {
+		cfg.Deny = func(w http.ResponseWriter, r *http.Request, err error) {
+			status := HTTPStatus(err)
+			http.Error(w, http.StatusText(status), status)
+		}
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sub, err := cfg.Subject(r)
+			if err == nil {
+				err = a.Authorize(sub, cfg.Object(r), cfg.Action(r))
+			}
+			if err != nil {
+				cfg.Deny(w, r, err)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(ContextWithSubject(r.Context(), sub)))
+		})
+	}
+}
+
+// HTTPStatus maps an Authorize error to an HTTP status code.
+func HTTPStatus(err error) int {
+	switch {
+	case err == nil:
+		return http.StatusOK
+	case errors.Is(err, ErrUnauthenticated):
+		return http.StatusUnauthorized
+	case errors.Is(err, ErrForbidden):
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,74 @@
+package cache
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/casbin/casbin/v2"
+)
+
+// newBenchEnforcers 生成 100 个角色各 10 条权限, 1000 个用户各一个角色
+func newBenchEnforcers(b *testing.B) (*casbin.SyncedEnforcer, *Enforcer) {
+	b.Helper()
+	e, err := casbin.NewSyncedEnforcer("../model.pml")
+	if err != nil {
+		b.Fatal(err)
+	}
+	var policies, groupings [][]string
+	for r := 0; r < 100; r++ {
+		for o := 0; o < 10; o++ {
+			policies = append(policies, []string{fmt.Sprintf("role%d", r), fmt.Sprintf("/data%d", o), "GET"})
+		}
+	}
+	for u := 0; u < 1000; u++ {
+		groupings = append(groupings, []string{fmt.Sprintf("user%d", u), fmt.Sprintf("role%d", u%100)})
+	}
+	if _, err := e.AddPolicies(policies); err != nil {
+		b.Fatal(err)
+	}
+	if _, err := e.AddGroupingPolicies(groupings); err != nil {
+		b.Fatal(err)
+	}
+	c, err := New(e, Config{})
+	if err != nil {
+		b.Fatal(err)
+	}
+	return e, c
+}
+
+type enforcer interface {
+	Enforce(rvals ...interface{}) (bool, error)
+}
+
+// benchEnforce 热点请求: 50 个用户访问 10 个资源
+func benchEnforce(b *testing.B, e enforcer) {
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		e.Enforce(fmt.Sprintf("user%d", i%50), fmt.Sprintf("/data%d", i%10), "GET")
+	}
+}
+
+func benchEnforceParallel(b *testing.B, e enforcer) {
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			e.Enforce(fmt.Sprintf("user%d", i%50), fmt.Sprintf("/data%d", i%10), "GET")
+			i++
+		}
+	})
+}
+
+func BenchmarkEnforce(b *testing.B) {
+	plain, cached := newBenchEnforcers(b)
+	b.Run("plain", func(b *testing.B) { benchEnforce(b, plain) })
+	b.Run("cached", func(b *testing.B) { benchEnforce(b, cached) })
+}
+
+func BenchmarkEnforceParallel(b *testing.B) {
+	plain, cached := newBenchEnforcers(b)
+	b.Run("plain", func(b *testing.B) { benchEnforceParallel(b, plain) })
+	b.Run("cached", func(b *testing.B) { benchEnforceParallel(b, cached) })
+}
@@ -0,0 +1,229 @@
+// Package cache 缓存 Enforce 的判定结果.
+//
+// 缓存以请求的字符串参数为键, 按 LRU 淘汰并可以设置过期时间. 策略的任何修改都会清空缓存:
+// 本地的 AddPolicy、RemovePolicy、AddRoleForUser 等通过 watcher 通知机制感知,
+// 其它实例的修改通过 Sync 接入 casbin_demo/watcher 感知.
+package cache
+
+import (
+	"strings"
+	"time"
+
+	"casbin_demo/watcher"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/casbin/casbin/v2/model"
+	"github.com/casbin/casbin/v2/persist"
+)
+
+const defaultSize = 10000
+
+// Config 缓存配置
+type Config struct {
+	Size int           // 最多缓存的判定数, 默认 10000
+	TTL  time.Duration // 过期时间, 0 表示只在策略修改时失效
+}
+
+// Stats 缓存统计
+type Stats struct {
+	Hits          uint64
+	Misses        uint64
+	Evictions     uint64
+	Invalidations uint64
+	Entries       int
+}
+
+// Enforcer 带判定缓存的 SyncedEnforcer. 关闭 EnableAutoNotifyWatcher 会导致本地修改不再清空缓存
+type Enforcer struct {
+	*casbin.SyncedEnforcer
+	cache *lru
+}
+
+// New wraps e with a decision cache. It installs a watcher on e to observe
+// policy changes, use SetWatcher or Sync instead of e.SetWatcher afterwards.
+func New(e *casbin.SyncedEnforcer, cfg Config) (*Enforcer, error) {
+	if cfg.Size <= 0 {
+		cfg.Size = defaultSize
+	}
+	c := &Enforcer{SyncedEnforcer: e, cache: newLRU(cfg.Size, cfg.TTL)}
+	if err := c.setWatcher(nil); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Enforce returns the cached decision for requests made only of strings and
+// falls back to the enforcer for other requests, e.g. ABAC structs.
+func (c *Enforcer) Enforce(rvals ...interface{}) (bool, error) {
+	key, ok := cacheKey(rvals)
+	if !ok {
+		return c.SyncedEnforcer.Enforce(rvals...)
+	}
+	allowed, hit, gen := c.cache.get(key)
+	if hit {
+		return allowed, nil
+	}
+	allowed, err := c.SyncedEnforcer.Enforce(rvals...)
+	if err != nil {
+		return false, err
+	}
+	c.cache.put(key, allowed, gen)
+	return allowed, nil
+}
+
+// cacheKey 用 \x00 连接字符串参数, 有非字符串参数时不缓存
+func cacheKey(rvals []interface{}) (string, bool) {
+	parts := make([]string, len(rvals))
+	for i, v := range rvals {
+		s, ok := v.(string)
+		if !ok {
+			return "", false
+		}
+		parts[i] = s
+	}
+	return strings.Join(parts, "\x00"), true
+}
+
+// Invalidate clears the cache.
+func (c *Enforcer) Invalidate() {
+	c.cache.clear()
+}
+
+// Stats returns the cache counters.
+func (c *Enforcer) Stats() Stats {
+	return c.cache.snapshot()
+}
+
+// SetWatcher sets w (may be nil) as the watcher of the enforcer, wrapped so
+// that local policy changes also clear the cache. Updates received from w
+// reload the whole policy, use Sync to apply them incrementally.
+func (c *Enforcer) SetWatcher(w persist.Watcher) error {
+	if err := c.setWatcher(w); err != nil {
+		return err
+	}
+	if w == nil {
+		return nil
+	}
+	return w.SetUpdateCallback(func(string) { _ = c.LoadPolicy() })
+}
+
+// setWatcher 只安装包装后的 watcher, 不修改 w 的回调
+func (c *Enforcer) setWatcher(w persist.Watcher) error {
+	return c.SyncedEnforcer.SetWatcher(&invalidatingWatcher{inner: w, invalidate: c.Invalidate})
+}
+
+// Sync connects the enforcer to a distributed watcher: changes of other
+// instances are applied incrementally and clear the cache.
+func (c *Enforcer) Sync(w *watcher.Watcher) error {
+	if err := watcher.Sync(c.SyncedEnforcer, w, func(watcher.Message) { c.Invalidate() }); err != nil {
+		return err
+	}
+	return c.setWatcher(w)
+}
+
+// LoadPolicy reloads the policy and clears the cache.
+func (c *Enforcer) LoadPolicy() error {
+	defer c.Invalidate()
+	return c.SyncedEnforcer.LoadPolicy()
+}
+
+// ClearPolicy clears the policy and the cache.
+func (c *Enforcer) ClearPolicy() {
+	defer c.Invalidate()
+	c.SyncedEnforcer.ClearPolicy()
+}
+
+// invalidatingWatcher 在 casbin 通知 watcher 时清空缓存, 再转发给内层的 watcher
+type invalidatingWatcher struct {
+	inner      persist.Watcher
+	invalidate func()
+}
+
+var (
+	_ persist.WatcherEx        = (*invalidatingWatcher)(nil)
+	_ persist.UpdatableWatcher = (*invalidatingWatcher)(nil)
+)
+
+func (w *invalidatingWatcher) SetUpdateCallback(callback func(string)) error {
+	if w.inner == nil {
+		return nil
+	}
+	return w.inner.SetUpdateCallback(func(s string) {
+		callback(s)
+		w.invalidate()
+	})
+}
+
+func (w *invalidatingWatcher) Update() error {
+	w.invalidate()
+	if w.inner == nil {
+		return nil
+	}
+	return w.inner.Update()
+}
+
+func (w *invalidatingWatcher) Close() {
+	if w.inner != nil {
+		w.inner.Close()
+	}
+}
+
+// ex 内层 watcher 不支持增量通知时退化为 Update
+func (w *invalidatingWatcher) ex(fn func(ex persist.WatcherEx) error) error {
+	w.invalidate()
+	if w.inner == nil {
+		return nil
+	}
+	if ex, ok := w.inner.(persist.WatcherEx); ok {
+		return fn(ex)
+	}
+	return w.inner.Update()
+}
+
+func (w *invalidatingWatcher) UpdateForAddPolicy(sec, ptype string, params ...string) error {
+	return w.ex(func(ex persist.WatcherEx) error { return ex.UpdateForAddPolicy(sec, ptype, params...) })
+}
+
+func (w *invalidatingWatcher) UpdateForRemovePolicy(sec, ptype string, params ...string) error {
+	return w.ex(func(ex persist.WatcherEx) error { return ex.UpdateForRemovePolicy(sec, ptype, params...) })
+}
+
+func (w *invalidatingWatcher) UpdateForRemoveFilteredPolicy(sec, ptype string, fieldIndex int, fieldValues ...string) error {
+	return w.ex(func(ex persist.WatcherEx) error {
+		return ex.UpdateForRemoveFilteredPolicy(sec, ptype, fieldIndex, fieldValues...)
+	})
+}
+
+func (w *invalidatingWatcher) UpdateForSavePolicy(m model.Model) error {
+	return w.ex(func(ex persist.WatcherEx) error { return ex.UpdateForSavePolicy(m) })
+}
+
+func (w *invalidatingWatcher) UpdateForAddPolicies(sec string, ptype string, rules ...[]string) error {
+	return w.ex(func(ex persist.WatcherEx) error { return ex.UpdateForAddPolicies(sec, ptype, rules...) })
+}
+
+func (w *invalidatingWatcher) UpdateForRemovePolicies(sec string, ptype string, rules ...[]string) error {
+	return w.ex(func(ex persist.WatcherEx) error { return ex.UpdateForRemovePolicies(sec, ptype, rules...) })
+}
+
+func (w *invalidatingWatcher) UpdateForUpdatePolicy(sec string, ptype string, oldRule, newRule []string) error {
+	w.invalidate()
+	if u, ok := w.inner.(persist.UpdatableWatcher); ok {
+		return u.UpdateForUpdatePolicy(sec, ptype, oldRule, newRule)
+	}
+	if w.inner == nil {
+		return nil
+	}
+	return w.inner.Update()
+}
+
+func (w *invalidatingWatcher) UpdateForUpdatePolicies(sec string, ptype string, oldRules, newRules [][]string) error {
+	w.invalidate()
+	if u, ok := w.inner.(persist.UpdatableWatcher); ok {
+		return u.UpdateForUpdatePolicies(sec, ptype, oldRules, newRules)
+	}
+	if w.inner == nil {
+		return nil
+	}
+	return w.inner.Update()
+}
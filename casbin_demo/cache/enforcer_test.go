@@ -0,0 +1,202 @@
+package cache
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"casbin_demo/adapter"
+	"casbin_demo/watcher"
+
+	"github.com/casbin/casbin/v2"
+	_ "modernc.org/sqlite"
+)
+
+func newTestEnforcer(t testing.TB, cfg Config, params ...interface{}) *Enforcer {
+	t.Helper()
+	if len(params) == 0 {
+		params = []interface{}{"../model.pml", "../policy.csv"}
+	}
+	e, err := casbin.NewSyncedEnforcer(params...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 只修改内存中的策略, 不写回 policy.csv
+	e.EnableAutoSave(false)
+	c, err := New(e, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func check(t *testing.T, c *Enforcer, sub, obj, act string, want bool) {
+	t.Helper()
+	got, err := c.Enforce(sub, obj, act)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("Enforce(%s, %s, %s) = %v; 期望值 %v", sub, obj, act, got, want)
+	}
+}
+
+func TestInvalidation(t *testing.T) {
+	c := newTestEnforcer(t, Config{})
+	check(t, c, "wangwu", "/users", "POST", false)
+	check(t, c, "wangwu", "/users", "POST", false)
+	if s := c.Stats(); s.Hits != 1 || s.Misses != 1 {
+		t.Errorf("Stats = %+v; 期望 1 次命中 1 次未命中", s)
+	}
+
+	cases := []struct {
+		name   string
+		change func() (bool, error)
+		sub    string
+		obj    string
+		act    string
+		want   bool
+	}{
+		{"AddPolicy", func() (bool, error) { return c.AddPolicy("yunwei", "/users", "POST") }, "wangwu", "/users", "POST", true},
+		{"RemovePolicy", func() (bool, error) { return c.RemovePolicy("yunwei", "/users", "POST") }, "wangwu", "/users", "POST", false},
+		{"AddRoleForUser", func() (bool, error) { return c.AddRoleForUser("wangwu", "admin") }, "wangwu", "/users", "POST", true},
+		{"DeleteRoleForUser", func() (bool, error) { return c.DeleteRoleForUser("wangwu", "admin") }, "wangwu", "/users", "POST", false},
+		{"RemoveFilteredPolicy", func() (bool, error) { return c.RemoveFilteredPolicy(1, "/home") }, "zhangsan", "/home", "GET", false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			// 先缓存修改之前的结果
+			check(t, c, tc.sub, tc.obj, tc.act, !tc.want)
+			if _, err := tc.change(); err != nil {
+				t.Fatal(err)
+			}
+			check(t, c, tc.sub, tc.obj, tc.act, tc.want)
+		})
+	}
+}
+
+func TestLRU(t *testing.T) {
+	c := newTestEnforcer(t, Config{Size: 2})
+	check(t, c, "zhangsan", "/index", "GET", true)
+	check(t, c, "zhangsan", "/home", "GET", true)
+	check(t, c, "zhangsan", "/index", "GET", true) // /index 变为最近使用
+	check(t, c, "zhangsan", "/users", "GET", true) // 淘汰 /home
+	check(t, c, "zhangsan", "/index", "GET", true)
+	s := c.Stats()
+	if s.Evictions != 1 || s.Entries != 2 || s.Hits != 2 {
+		t.Errorf("Stats = %+v; 期望 1 次淘汰 2 条缓存 2 次命中", s)
+	}
+}
+
+func TestTTL(t *testing.T) {
+	c := newTestEnforcer(t, Config{TTL: time.Minute})
+	now := time.Now()
+	c.cache.now = func() time.Time { return now }
+	check(t, c, "zhangsan", "/index", "GET", true)
+	now = now.Add(30 * time.Second)
+	check(t, c, "zhangsan", "/index", "GET", true)
+	now = now.Add(time.Minute)
+	check(t, c, "zhangsan", "/index", "GET", true)
+	if s := c.Stats(); s.Hits != 1 || s.Misses != 2 {
+		t.Errorf("Stats = %+v; 期望 1 次命中 2 次未命中", s)
+	}
+}
+
+func TestNonStringRequest(t *testing.T) {
+	c := newTestEnforcer(t, Config{}, "../model_abac.pml", "../policy_abac.csv")
+	admin := struct{ Admin bool }{Admin: true}
+	if ok, err := c.Enforce(admin, "record", "read"); !ok || err != nil {
+		t.Fatalf("Enforce = %v, %v; 期望允许", ok, err)
+	}
+	if s := c.Stats(); s.Hits+s.Misses != 0 {
+		t.Errorf("Stats = %+v; 非字符串请求不应该经过缓存", s)
+	}
+}
+
+func TestSync(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "casbin.db")
+	a, err := adapter.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	if _, err := adapter.ImportCSV(a, "../policy.csv"); err != nil {
+		t.Fatal(err)
+	}
+
+	var instances []*Enforcer
+	for i := 0; i < 2; i++ {
+		c := newTestEnforcer(t, Config{}, "../model.pml", a)
+		c.EnableAutoSave(true)
+		w, err := watcher.New(watcher.NewFileTransport(filepath.Join(dir, "events"), 10*time.Millisecond))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(w.Close)
+		if err := c.Sync(w); err != nil {
+			t.Fatal(err)
+		}
+		instances = append(instances, c)
+	}
+
+	check(t, instances[1], "wangwu", "/users", "POST", false)
+	if _, err := instances[0].AddRoleForUser("wangwu", "admin"); err != nil {
+		t.Fatal(err)
+	}
+	// 另一个实例收到通知后清空缓存
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		if ok, _ := instances[1].Enforce("wangwu", "/users", "POST"); ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("等待同步超时")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	// 本地修改同样清空本实例的缓存
+	check(t, instances[0], "wangwu", "/users", "POST", true)
+}
+
+// callbackWatcher 记录 SetUpdateCallback 设置的回调, 用于模拟其它实例的通知
+type callbackWatcher struct {
+	callback func(string)
+}
+
+func (w *callbackWatcher) SetUpdateCallback(callback func(string)) error {
+	w.callback = callback
+	return nil
+}
+
+func (w *callbackWatcher) Update() error { return nil }
+
+func (w *callbackWatcher) Close() {}
+
+func TestSetWatcher(t *testing.T) {
+	a, err := adapter.Open("sqlite", filepath.Join(t.TempDir(), "casbin.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	if _, err := adapter.ImportCSV(a, "../policy.csv"); err != nil {
+		t.Fatal(err)
+	}
+
+	c := newTestEnforcer(t, Config{}, "../model.pml", a)
+	w := &callbackWatcher{}
+	if err := c.SetWatcher(w); err != nil {
+		t.Fatal(err)
+	}
+	if w.callback == nil {
+		t.Fatal("SetWatcher 没有设置回调")
+	}
+
+	check(t, c, "wangwu", "/users", "POST", false)
+	// 其它实例直接修改数据库后发出通知
+	if err := a.AddPolicy("g", "g", []string{"wangwu", "admin"}); err != nil {
+		t.Fatal(err)
+	}
+	w.callback("")
+	check(t, c, "wangwu", "/users", "POST", true)
+}
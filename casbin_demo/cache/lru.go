@@ -0,0 +1,94 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// lru 带过期时间的 LRU 缓存, 值为判定结果
+type lru struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	now     func() time.Time
+	entries map[string]*list.Element
+	order   *list.List // 最近使用的在前面
+	gen     uint64     // 每次清空加一, 用于丢弃清空之前开始的判定结果
+	stats   Stats
+}
+
+type entry struct {
+	key     string
+	allowed bool
+	expires time.Time
+}
+
+func newLRU(size int, ttl time.Duration) *lru {
+	return &lru{
+		size:    size,
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[string]*list.Element, size),
+		order:   list.New(),
+	}
+}
+
+// get 返回缓存的结果和当前的代数
+func (c *lru) get(key string) (allowed, ok bool, gen uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, found := c.entries[key]; found {
+		e := elem.Value.(*entry)
+		if c.ttl <= 0 || c.now().Before(e.expires) {
+			c.order.MoveToFront(elem)
+			c.stats.Hits++
+			return e.allowed, true, c.gen
+		}
+		c.remove(elem)
+	}
+	c.stats.Misses++
+	return false, false, c.gen
+}
+
+// put 只在 gen 与当前代数相同时保存, 避免把策略修改之前的结果写入缓存
+func (c *lru) put(key string, allowed bool, gen uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if gen != c.gen {
+		return
+	}
+	e := &entry{key: key, allowed: allowed, expires: c.now().Add(c.ttl)}
+	if elem, found := c.entries[key]; found {
+		elem.Value = e
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.entries[key] = c.order.PushFront(e)
+	if c.order.Len() > c.size {
+		c.remove(c.order.Back())
+		c.stats.Evictions++
+	}
+}
+
+func (c *lru) remove(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.entries, elem.Value.(*entry).key)
+}
+
+func (c *lru) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	c.entries = make(map[string]*list.Element, c.size)
+	c.order.Init()
+	c.stats.Invalidations++
+}
+
+func (c *lru) snapshot() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := c.stats
+	s.Entries = c.order.Len()
+	return s
+}
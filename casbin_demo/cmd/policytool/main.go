@@ -0,0 +1,80 @@
+// policytool 检查策略文件并解释请求的判定过程.
+//
+//	go run ./cmd/policytool lint -model model.pml -policy policy.csv
+//	go run ./cmd/policytool explain -model model.pml -policy policy.csv zhangsan /users POST
+package main
+
+import (
+	"casbin_demo/policycheck"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/casbin/casbin/v2/model"
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: policytool lint|explain -model FILE -policy FILE [sub obj act]")
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	fs := flag.NewFlagSet(os.Args[1], flag.ExitOnError)
+	modelPath := fs.String("model", "./model.pml", "模型文件")
+	policyPath := fs.String("policy", "./policy.csv", "策略文件")
+	fs.Parse(os.Args[2:])
+
+	switch os.Args[1] {
+	case "lint":
+		os.Exit(lint(*modelPath, *policyPath))
+	case "explain":
+		if fs.NArg() == 0 {
+			usage()
+		}
+		os.Exit(explain(*modelPath, *policyPath, fs.Args()))
+	default:
+		usage()
+	}
+}
+
+// lint 有问题时返回 1
+func lint(modelPath, policyPath string) int {
+	m, err := model.NewModelFromFile(modelPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "加载模型失败: %v\n", err)
+		return 2
+	}
+	rules, err := policycheck.ReadCSV(policyPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "读取策略失败: %v\n", err)
+		return 2
+	}
+	issues := policycheck.Lint(m, rules)
+	for _, issue := range issues {
+		fmt.Printf("%s:%s\n", policyPath, issue)
+	}
+	if len(issues) > 0 {
+		return 1
+	}
+	fmt.Printf("%s: %d rules OK\n", policyPath, len(rules))
+	return 0
+}
+
+func explain(modelPath, policyPath string, request []string) int {
+	e, err := casbin.NewEnforcer(modelPath, policyPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "加载策略失败: %v\n", err)
+		return 2
+	}
+	ex, err := policycheck.Explain(e, request...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "判定失败: %v\n", err)
+		return 2
+	}
+	fmt.Print(ex)
+	return 0
+}
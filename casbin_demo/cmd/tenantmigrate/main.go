@@ -0,0 +1,78 @@
+// tenantmigrate 把单租户的 policy.csv 迁移到多租户模型的默认租户中.
+//
+//	go run ./cmd/tenantmigrate -out policy_domain.csv
+//	go run ./cmd/tenantmigrate -db casbin.db -domain acme
+//
+// 写入数据库时默认使用 casbin_rule_domain 表, 与主程序 -db 使用的单租户 casbin_rule 表分开.
+package main
+
+import (
+	"casbin_demo/adapter"
+	"casbin_demo/tenant"
+	"database/sql"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/casbin/casbin/v2"
+	fileadapter "github.com/casbin/casbin/v2/persist/file-adapter"
+	_ "modernc.org/sqlite"
+)
+
+func main() {
+	modelPath := flag.String("model", "./model.pml", "单租户模型")
+	policyPath := flag.String("policy", "./policy.csv", "单租户策略")
+	domainModel := flag.String("domain-model", "./model_domain.pml", "多租户模型")
+	domain := flag.String("domain", tenant.DefaultDomain, "迁移到的租户")
+	out := flag.String("out", "", "输出的策略文件, 已存在时合并")
+	dbPath := flag.String("db", "", "输出的 SQLite 数据库, 与 -out 二选一")
+	table := flag.String("table", "casbin_rule_domain", "-db 写入的表, 默认与单租户策略的 casbin_rule 表分开")
+	flag.Parse()
+	if (*out == "") == (*dbPath == "") {
+		log.Fatal("需要指定 -out 或 -db 其中之一")
+	}
+
+	src, err := casbin.NewEnforcer(*modelPath, *policyPath)
+	if err != nil {
+		log.Fatalf("加载单租户策略失败: %v", err)
+	}
+
+	var dst *tenant.Enforcer
+	if *dbPath != "" {
+		db, err := sql.Open("sqlite", *dbPath)
+		if err != nil {
+			log.Fatalf("打开数据库失败: %v", err)
+		}
+		defer db.Close()
+		a, err := adapter.NewAdapter(db, "sqlite", *table)
+		if err != nil {
+			log.Fatalf("创建表 %s 失败: %v", *table, err)
+		}
+		// SQL 适配器在 AddPolicy 时增量保存
+		dst, err = tenant.NewEnforcer(*domainModel, a)
+		if err != nil {
+			log.Fatalf("加载多租户模型失败: %v", err)
+		}
+	} else {
+		if _, err := os.Stat(*out); os.IsNotExist(err) {
+			if err := os.WriteFile(*out, nil, 0o644); err != nil {
+				log.Fatal(err)
+			}
+		}
+		dst, err = tenant.NewEnforcer(*domainModel, fileadapter.NewAdapter(*out))
+		if err != nil {
+			log.Fatalf("加载多租户模型失败: %v", err)
+		}
+	}
+
+	n, err := tenant.MigrateFlat(src, dst, *domain)
+	if err != nil {
+		log.Fatalf("迁移失败: %v", err)
+	}
+	if *out != "" {
+		if err := dst.SavePolicy(); err != nil {
+			log.Fatalf("保存 %s 失败: %v", *out, err)
+		}
+	}
+	log.Printf("迁移 %d 条规则到租户 %s", n, *domain)
+}
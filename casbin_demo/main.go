@@ -1,10 +1,21 @@
 package main
 
 import (
+	"bufio"
+	"casbin_demo/abac"
+	"casbin_demo/adapter"
+	"casbin_demo/admin"
+	"casbin_demo/authz"
+	"flag"
 	"fmt"
 	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
 
 	"github.com/casbin/casbin/v2"
+	_ "modernc.org/sqlite"
 )
 
 func check(e *casbin.Enforcer, sub, obj, act string) {
@@ -16,37 +27,157 @@ func check(e *casbin.Enforcer, sub, obj, act string) {
 	}
 }
 
+// newEnforcer 没有指定数据库时使用 policy.csv, 否则使用 SQLite 并在第一次运行时导入 policy.csv
+func newEnforcer(dbPath string) (*casbin.Enforcer, error) {
+	if dbPath == "" {
+		return casbin.NewEnforcer("./model.pml", "./policy.csv")
+	}
+	a, err := adapter.Open("sqlite", dbPath)
+	if err != nil {
+		return nil, err
+	}
+	if n, err := a.Count(); err != nil {
+		return nil, err
+	} else if n == 0 {
+		imported, err := adapter.ImportCSV(a, "./policy.csv")
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("从 policy.csv 导入 %d 条策略", imported)
+	}
+	return casbin.NewEnforcer("./model.pml", a)
+}
+
+// adminObject 策略管理 API 的所有请求都按 (用户, /admin, HTTP 方法) 鉴权,
+// 需要在策略中授权, 例如 p, admin, /admin, GET
+const adminObject = "/admin"
+
+// loadTokens 读取 token 文件, 每行 "<token> <用户>", 空行和 # 开头的行被忽略
+func loadTokens(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	tokens := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: 格式应为 \"<token> <用户>\"", path, line)
+		}
+		tokens[fields[0]] = fields[1]
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("%s: 没有 token", path)
+	}
+	return tokens, nil
+}
+
+// serveAdmin 启动策略管理 API, 请求先经过 authz 中间件认证和鉴权
+func serveAdmin(e *casbin.Enforcer, addr string, tokens map[string]string, save func() error) error {
+	// 鉴权和修改策略共用一把锁
+	var mu sync.Mutex
+	a := authz.New(e, authz.StaticTokens(tokens), authz.WithLocker(&mu))
+	h := admin.NewHandler(e, admin.Config{Save: save, Audit: admin.JSONAuditLog(os.Stderr), Lock: &mu})
+	middleware := a.HTTPMiddleware(authz.HTTPConfig{
+		Object: func(r *http.Request) string { return adminObject },
+	})
+	log.Printf("策略管理 API 监听 %s", addr)
+	return http.ListenAndServe(addr, middleware(h))
+}
+
+// abacDemo 演示基于属性的访问控制: 只有记录的所有者可以编辑
+func abacDemo() {
+	e, err := abac.NewEnforcer("./model_abac.pml", "./policy_abac.csv")
+	if err != nil {
+		log.Fatalf("NewEnforecer failed:%v\n", err)
+	}
+	record := &abac.Record{ID: "1", Owner: "zhangsan", Department: "dev"}
+	for _, user := range []abac.User{{Name: "zhangsan", Department: "dev"}, {Name: "lisi", Department: "dev"}} {
+		for _, act := range []string{"read", "edit"} {
+			ok, _ := e.Can(user, record, act)
+			if ok {
+				fmt.Printf("%s CAN %s record %s\n", user.Name, act, record.ID)
+			} else {
+				fmt.Printf("%s CANNOT %s record %s\n", user.Name, act, record.ID)
+			}
+		}
+	}
+}
+
 func main() {
-	e, err := casbin.NewEnforcer("./model.pml", "./policy.csv")
+	dbPath := flag.String("db", "", "SQLite 数据库文件, 为空时使用 policy.csv")
+	adminAddr := flag.String("admin", "", "策略管理 API 的监听地址, 例如 127.0.0.1:8080, 为空时不启动")
+	adminTokens := flag.String("admin-tokens", "", "策略管理 API 的 token 文件, 每行 \"<token> <用户>\", 使用 -admin 时必填")
+	abacMode := flag.Bool("abac", false, "运行 ABAC 示例")
+	flag.Parse()
+	if *abacMode {
+		abacDemo()
+		return
+	}
+	var tokens map[string]string
+	if *adminAddr != "" {
+		if *adminTokens == "" {
+			log.Fatal("-admin 需要 -admin-tokens, 策略管理 API 不能在没有认证的情况下启动")
+		}
+		var err error
+		if tokens, err = loadTokens(*adminTokens); err != nil {
+			log.Fatalf("读取 token 文件失败: %v", err)
+		}
+	}
+
+	e, err := newEnforcer(*dbPath)
 	if err != nil {
 		log.Fatalf("NewEnforecer failed:%v\n", err)
 	}
+	// SQL 适配器在 AddPolicy/RemovePolicy 时已经增量保存, 只有 CSV 需要整体重写
+	var save func() error
+	if *dbPath == "" {
+		save = e.SavePolicy
+	}
+	savePolicy := func() {
+		if save != nil {
+			save()
+		}
+	}
 	check(e, "zhangsan", "/index", "GET")
 	check(e, "zhangsan", "/home", "GET")
 	check(e, "zhangsan", "/users", "POST")
 	check(e, "wangwu", "/users", "POST")
 
 	e.AddPolicy("wangwu", "/users", "POST")
-	e.SavePolicy()
+	savePolicy()
 	check(e, "wangwu", "/users", "POST")
 	e.RemovePolicy("wangwu", "/users", "POST")
-	e.SavePolicy()
+	savePolicy()
 	check(e, "wangwu", "/users", "POST")
 
 	// 添加角色-权限对应关系
 	e.AddPolicy("kuaiji", "/roleusers", "GET")
-	e.SavePolicy()
+	savePolicy()
 
 	// 添加用户-角色对应关系
 	e.AddRoleForUser("zhangsan", "kuaiji")
-	e.SavePolicy()
+	savePolicy()
 	check(e, "zhangsan", "/roleusers", "GET")
 
 	// 删除用户-角色对应关系
 	e.RemoveGroupingPolicy("zhangsan", "kuaiji")
-	e.SavePolicy()
+	savePolicy()
 	// 角色-权限对应关系
 	e.RemovePolicy("kuaiji", "/roleusers", "GET")
-	e.SavePolicy()
+	savePolicy()
 	check(e, "zhangsan", "/roleusers", "GET")
+
+	if *adminAddr != "" {
+		log.Fatal(serveAdmin(e, *adminAddr, tokens, save))
+	}
 }
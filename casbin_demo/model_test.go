@@ -0,0 +1,97 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/casbin/casbin/v2"
+)
+
+func newTestEnforcer(t *testing.T, modelPath, policyPath string) *casbin.Enforcer {
+	t.Helper()
+	e, err := casbin.NewEnforcer(modelPath, policyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+// TestPatternModelCompatible policy.csv 中的规则补上 eft 列 allow 后, 在两个模型下结果相同
+func TestPatternModelCompatible(t *testing.T) {
+	exact := newTestEnforcer(t, "./model.pml", "./policy.csv")
+	pattern, err := casbin.NewEnforcer("./model_pattern.pml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	policies, err := exact.GetPolicy()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rule := range policies {
+		if _, err := pattern.AddPolicy(append(rule, "allow")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	groupings, err := exact.GetGroupingPolicy()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pattern.AddGroupingPolicies(groupings); err != nil {
+		t.Fatal(err)
+	}
+
+	subjects := []string{"zhangsan", "wangwu", "lisi", "admin", "yunwei"}
+	objects := []string{"/index", "/home", "/users", "/users/", "/usersX", "/roleusers"}
+	actions := []string{"GET", "POST", "PUT", "DELETE"}
+	for _, sub := range subjects {
+		for _, obj := range objects {
+			for _, act := range actions {
+				want, err := exact.Enforce(sub, obj, act)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, err := pattern.Enforce(sub, obj, act)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got != want {
+					t.Errorf("Enforce(%s, %s, %s) = %v; 期望值 %v", sub, obj, act, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestPatternModel(t *testing.T) {
+	e := newTestEnforcer(t, "./model_pattern.pml", "./policy_pattern.csv")
+	cases := []struct {
+		name string
+		sub  string
+		obj  string
+		act  string
+		want bool
+	}{
+		{"keyMatch2 参数", "zhangsan", "/users/123", "GET", true},
+		{"方法通配符", "zhangsan", "/users/123", "DELETE", true},
+		{"参数不匹配多级路径", "zhangsan", "/users/123/profile", "GET", false},
+		{"正则匹配", "zhangsan", "/orders/42", "GET", true},
+		{"正则不匹配", "zhangsan", "/orders/abc", "GET", false},
+		{"正则锚定开头", "zhangsan", "/api/orders/42", "GET", false},
+		{"正则限制方法", "zhangsan", "/orders/42", "DELETE", false},
+		{"运维只读", "wangwu", "/users/123", "GET", true},
+		{"运维不能修改", "wangwu", "/users/123", "PUT", false},
+		{"星号匹配子路径", "wangwu", "/users/123/profile", "PUT", true},
+		{"deny 优先于 allow", "wangwu", "/users/123/password", "PUT", false},
+		{"deny 只影响本人", "lisi", "/users/123/password", "PUT", true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := e.Enforce(tc.sub, tc.obj, tc.act)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("Enforce(%s, %s, %s) = %v; 期望值 %v", tc.sub, tc.obj, tc.act, got, tc.want)
+			}
+		})
+	}
+}
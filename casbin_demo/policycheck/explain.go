@@ -0,0 +1,113 @@
+package policycheck
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/casbin/casbin/v2"
+)
+
+// Match 一条与请求匹配的规则, 以及请求主体到规则主体的角色链
+type Match struct {
+	Rule  []string
+	Chain []string // 例如 [zhangsan admin], 主体通过模式匹配时为空
+}
+
+// Explanation 请求的判定结果和依据
+type Explanation struct {
+	Request  []string
+	Allowed  bool
+	Decisive []string // casbin 给出的决定性规则, 默认拒绝时为空
+	Matches  []Match
+}
+
+// Explain enforces the request and collects every p rule whose matcher is
+// satisfied, together with the role chain linking the request subject to the
+// rule subject.
+func Explain(e *casbin.Enforcer, rvals ...string) (*Explanation, error) {
+	args := make([]interface{}, len(rvals))
+	for i, v := range rvals {
+		args[i] = v
+	}
+	allowed, decisive, err := e.EnforceEx(args...)
+	if err != nil {
+		return nil, err
+	}
+	ex := &Explanation{Request: rvals, Allowed: allowed, Decisive: decisive}
+
+	// 用只包含一条 p 规则的副本逐条求值, 找出所有匹配的规则
+	policies, err := e.GetPolicy()
+	if err != nil {
+		return nil, err
+	}
+	m := e.GetModel().Copy()
+	m.ClearPolicy()
+	for ptype, ast := range e.GetModel()["g"] {
+		for _, rule := range ast.Policy {
+			if err := m.AddPolicy("g", ptype, slices.Clone(rule)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	single, err := casbin.NewEnforcer(m)
+	if err != nil {
+		return nil, err
+	}
+	if err := single.BuildRoleLinks(); err != nil {
+		return nil, err
+	}
+	groupings, err := e.GetGroupingPolicy()
+	if err != nil {
+		return nil, err
+	}
+	rules := make([]Rule, len(groupings))
+	for i, values := range groupings {
+		rules[i] = Rule{Ptype: "g", Values: values}
+	}
+	graph := newRoleGraph(rules)
+	for _, rule := range policies {
+		if _, err := single.AddPolicy(rule); err != nil {
+			return nil, err
+		}
+		_, explain, err := single.EnforceEx(args...)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := single.RemovePolicy(rule); err != nil {
+			return nil, err
+		}
+		if len(explain) == 0 {
+			continue
+		}
+		match := Match{Rule: rule}
+		sub := graph.policySubject(rule)
+		from := node{rvals[0], sub.domain}
+		if path := graph.path(from, sub); path != nil {
+			match.Chain = graph.names(path)
+		}
+		ex.Matches = append(ex.Matches, match)
+	}
+	return ex, nil
+}
+
+func (ex *Explanation) String() string {
+	var b strings.Builder
+	decision := "DENY"
+	if ex.Allowed {
+		decision = "ALLOW"
+	}
+	fmt.Fprintf(&b, "%s: %s\n", strings.Join(ex.Request, " "), decision)
+	if len(ex.Decisive) > 0 {
+		fmt.Fprintf(&b, "  decided by: p, %s\n", strings.Join(ex.Decisive, ", "))
+	} else if !ex.Allowed {
+		b.WriteString("  no rule allows the request\n")
+	}
+	for _, match := range ex.Matches {
+		fmt.Fprintf(&b, "  matched: p, %s\n", strings.Join(match.Rule, ", "))
+		if len(match.Chain) > 1 {
+			fmt.Fprintf(&b, "    role chain: %s\n", strings.Join(match.Chain, " -> "))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,162 @@
+package policycheck
+
+import "fmt"
+
+// node 角色图中的节点, 多租户模型中同名的角色在不同租户是不同的节点
+type node struct {
+	name   string
+	domain string
+}
+
+func (n node) String() string {
+	if n.domain == "" {
+		return n.name
+	}
+	return fmt.Sprintf("%s (domain %s)", n.name, n.domain)
+}
+
+type edge struct{ from, to node }
+
+// roleGraph 由 g 规则构成的继承关系, 边从成员指向角色
+type roleGraph struct {
+	domains bool
+	parents map[node][]node
+	edges   []edge // 按规则顺序, 保证输出稳定
+	line    map[edge]int
+	nodes   map[node]bool   // 出现在 g 规则中的节点
+	known   map[string]bool // 出现在 g 规则中的名字, 不区分租户
+}
+
+func newRoleGraph(rules []Rule) *roleGraph {
+	g := &roleGraph{
+		parents: map[node][]node{},
+		line:    map[edge]int{},
+		nodes:   map[node]bool{},
+		known:   map[string]bool{},
+	}
+	for _, rule := range rules {
+		if rule.Ptype == "g" && len(rule.Values) == 3 {
+			g.domains = true
+		}
+	}
+	for _, rule := range rules {
+		if rule.Ptype != "g" {
+			continue
+		}
+		member, role := g.member(rule.Values), g.role(rule.Values)
+		g.parents[member] = append(g.parents[member], role)
+		g.edges = append(g.edges, edge{member, role})
+		g.line[edge{member, role}] = rule.Line
+		g.nodes[member], g.nodes[role] = true, true
+		g.known[member.name], g.known[role.name] = true, true
+	}
+	return g
+}
+
+func (g *roleGraph) domainOf(values []string, i int) string {
+	if g.domains && len(values) > i {
+		return values[i]
+	}
+	return ""
+}
+
+func (g *roleGraph) member(values []string) node { return node{values[0], g.domainOf(values, 2)} }
+func (g *roleGraph) role(values []string) node   { return node{values[1], g.domainOf(values, 2)} }
+
+// policySubject p 规则的主体, 多租户模型中 p = sub, dom, ...
+func (g *roleGraph) policySubject(values []string) node {
+	return node{values[0], g.domainOf(values, 1)}
+}
+
+// anyAncestor 判断 n 或者它继承的任意角色是否满足 fn
+func (g *roleGraph) anyAncestor(n node, fn func(node) bool) bool {
+	visited := map[node]bool{}
+	var visit func(n node) bool
+	visit = func(n node) bool {
+		if visited[n] {
+			return false
+		}
+		visited[n] = true
+		if fn(n) {
+			return true
+		}
+		for _, parent := range g.parents[n] {
+			if visit(parent) {
+				return true
+			}
+		}
+		return false
+	}
+	return visit(n)
+}
+
+// path 返回从 from 沿继承关系到 to 的最短路径, 不可达时返回 nil
+func (g *roleGraph) path(from, to node) []node {
+	prev := map[node]node{from: from}
+	queue := []node{from}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		if n == to {
+			var path []node
+			for ; n != from; n = prev[n] {
+				path = append([]node{n}, path...)
+			}
+			return append([]node{from}, path...)
+		}
+		for _, parent := range g.parents[n] {
+			if _, ok := prev[parent]; !ok {
+				prev[parent] = n
+				queue = append(queue, parent)
+			}
+		}
+	}
+	return nil
+}
+
+// cycles 返回所有的环, 每个环首尾是同一个节点
+func (g *roleGraph) cycles() [][]node {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := map[node]int{}
+	var stack []node
+	var cycles [][]node
+	var visit func(n node)
+	visit = func(n node) {
+		state[n] = visiting
+		stack = append(stack, n)
+		for _, parent := range g.parents[n] {
+			switch state[parent] {
+			case unvisited:
+				visit(parent)
+			case visiting:
+				for i := len(stack) - 1; i >= 0; i-- {
+					if stack[i] == parent {
+						cycle := append([]node{}, stack[i:]...)
+						cycles = append(cycles, append(cycle, parent))
+						break
+					}
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[n] = done
+	}
+	for _, e := range g.edges {
+		if state[e.from] == unvisited {
+			visit(e.from)
+		}
+	}
+	return cycles
+}
+
+func (g *roleGraph) names(nodes []node) []string {
+	names := make([]string, len(nodes))
+	for i, n := range nodes {
+		names[i] = n.name
+	}
+	return names
+}
@@ -0,0 +1,195 @@
+// Package policycheck 检查 casbin 模型和策略文件中的常见错误, 并解释单个请求的判定过程.
+package policycheck
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/casbin/casbin/v2/model"
+)
+
+// Kind 问题类型
+type Kind string
+
+const (
+	KindInvalid     Kind = "invalid"      // 未知的 ptype 或字段数与模型不一致
+	KindDuplicate   Kind = "duplicate"    // 重复的规则
+	KindUnknownRole Kind = "unknown_role" // 角色及其继承的角色都没有任何权限
+	KindCycle       Kind = "cycle"        // 角色循环继承
+	KindUnreachable Kind = "unreachable"  // 规则不会影响任何判定结果
+)
+
+// Rule 策略文件中的一行
+type Rule struct {
+	Line   int
+	Ptype  string
+	Values []string
+}
+
+func (r Rule) String() string {
+	return strings.Join(append([]string{r.Ptype}, r.Values...), ", ")
+}
+
+// Issue 一个检查出的问题
+type Issue struct {
+	Kind    Kind
+	Line    int // 0 表示与具体的行无关
+	Message string
+}
+
+func (i Issue) String() string {
+	if i.Line == 0 {
+		return fmt.Sprintf("%s: %s", i.Kind, i.Message)
+	}
+	return fmt.Sprintf("line %d: %s: %s", i.Line, i.Kind, i.Message)
+}
+
+// ReadCSV reads the rules of a casbin policy file, keeping line numbers.
+func ReadCSV(path string) ([]Rule, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	// 与 casbin 的文件适配器使用相同的解析规则
+	r := csv.NewReader(f)
+	r.Comment = '#'
+	r.TrimLeadingSpace = true
+	r.FieldsPerRecord = -1
+	var rules []Rule
+	for {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return rules, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := r.FieldPos(0)
+		if len(record) == 0 || strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+		values := make([]string, len(record)-1)
+		for i, field := range record[1:] {
+			values[i] = strings.TrimSpace(field)
+		}
+		rules = append(rules, Rule{Line: line, Ptype: strings.TrimSpace(record[0]), Values: values})
+	}
+}
+
+// Lint checks rules against the model and returns the issues sorted by line.
+func Lint(m model.Model, rules []Rule) []Issue {
+	l := &linter{model: m}
+	rules = l.checkShape(rules)
+	rules = l.checkDuplicates(rules)
+	l.graph = newRoleGraph(rules)
+	l.checkCycles()
+	l.checkRoles(rules)
+	l.checkUnreachable(rules)
+	slices.SortStableFunc(l.issues, func(a, b Issue) int { return a.Line - b.Line })
+	return l.issues
+}
+
+type linter struct {
+	model  model.Model
+	graph  *roleGraph
+	issues []Issue
+}
+
+func (l *linter) add(kind Kind, line int, format string, args ...interface{}) {
+	l.issues = append(l.issues, Issue{Kind: kind, Line: line, Message: fmt.Sprintf(format, args...)})
+}
+
+// checkShape 检查 ptype 是否在模型中定义, 字段数是否一致, 返回合法的规则
+func (l *linter) checkShape(rules []Rule) []Rule {
+	valid := rules[:0:0]
+	for _, rule := range rules {
+		sec := rule.Ptype[:1]
+		ast, ok := l.model[sec][rule.Ptype]
+		if !ok || (sec != "p" && sec != "g") {
+			l.add(KindInvalid, rule.Line, "ptype %q is not defined in the model", rule.Ptype)
+			continue
+		}
+		want := len(ast.Tokens)
+		if sec == "g" {
+			want = strings.Count(ast.Value, "_")
+		}
+		if len(rule.Values) != want {
+			l.add(KindInvalid, rule.Line, "%s has %d fields, the model expects %d", rule, len(rule.Values), want)
+			continue
+		}
+		valid = append(valid, rule)
+	}
+	return valid
+}
+
+func (l *linter) checkDuplicates(rules []Rule) []Rule {
+	seen := map[string]int{}
+	unique := rules[:0:0]
+	for _, rule := range rules {
+		key := rule.String()
+		if first, ok := seen[key]; ok {
+			l.add(KindDuplicate, rule.Line, "%s duplicates line %d", rule, first)
+			continue
+		}
+		seen[key] = rule.Line
+		unique = append(unique, rule)
+	}
+	return unique
+}
+
+func (l *linter) checkCycles() {
+	for _, cycle := range l.graph.cycles() {
+		l.add(KindCycle, l.graph.line[edge{cycle[0], cycle[1]}], "cyclic role inheritance %s", strings.Join(l.graph.names(cycle), " -> "))
+	}
+}
+
+// checkRoles 角色以及它继承的角色都没有 p 规则时, 分配这个角色没有任何作用
+func (l *linter) checkRoles(rules []Rule) {
+	granted := map[node]bool{}
+	for _, rule := range rules {
+		if rule.Ptype == "p" {
+			granted[l.graph.policySubject(rule.Values)] = true
+		}
+	}
+	reported := map[node]bool{}
+	for _, rule := range rules {
+		if rule.Ptype != "g" {
+			continue
+		}
+		role := l.graph.role(rule.Values)
+		if reported[role] || l.graph.anyAncestor(role, func(n node) bool { return granted[n] }) {
+			continue
+		}
+		reported[role] = true
+		l.add(KindUnknownRole, rule.Line, "role %s has no permissions", role)
+	}
+}
+
+// checkUnreachable 查找不会影响判定的规则:
+// 策略效果只考虑 allow 时的 deny 规则, 以及多租户模型中主体在该租户没有任何角色关系的规则
+func (l *linter) checkUnreachable(rules []Rule) {
+	effect := l.model["e"]["e"].Value
+	eft := slices.Index(l.model["p"]["p"].Tokens, "p_eft")
+	for _, rule := range rules {
+		if rule.Ptype != "p" {
+			continue
+		}
+		if eft >= 0 && rule.Values[eft] == "deny" && !strings.Contains(effect, "deny") {
+			l.add(KindUnreachable, rule.Line, "%s is a deny rule but the policy effect only considers allow", rule)
+			continue
+		}
+		if l.graph.domains {
+			sub := l.graph.policySubject(rule.Values)
+			if l.graph.known[sub.name] && !l.graph.nodes[sub] {
+				l.add(KindUnreachable, rule.Line, "%s has no role assignments in domain %s", sub.name, sub.domain)
+			}
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package policycheck
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/casbin/casbin/v2/model"
+)
+
+func lintFiles(t *testing.T, modelPath, policyPath string) []Issue {
+	t.Helper()
+	m, err := model.NewModelFromFile(modelPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules, err := ReadCSV(policyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Lint(m, rules)
+}
+
+func TestLint(t *testing.T) {
+	cases := []struct {
+		name   string
+		model  string
+		policy string
+		want   []string
+	}{
+		{"示例策略没有问题", "../model.pml", "../policy.csv", nil},
+		{"路径模式策略没有问题", "../model_pattern.pml", "../policy_pattern.csv", nil},
+		{"多租户策略没有问题", "../model_domain.pml", "../policy_domain.csv", nil},
+		{"错误策略", "../model.pml", "testdata/bad_policy.csv", []string{
+			"line 3: duplicate: p, admin, /users, GET duplicates line 2",
+			"line 4: invalid: p, admin, /users has 2 fields, the model expects 3",
+			`line 5: invalid: ptype "q" is not defined in the model`,
+			"line 7: unknown_role: role kuaiji has no permissions",
+			"line 8: cycle: cyclic role inheritance a -> b -> c -> a",
+			"line 8: unknown_role: role b has no permissions",
+			"line 9: unknown_role: role c has no permissions",
+			"line 10: unknown_role: role a has no permissions",
+		}},
+		{"只考虑 allow 的 deny 规则", "testdata/allow_only.pml", "testdata/deny_policy.csv", []string{
+			"line 2: unreachable: p, zhangsan, /users, GET, deny is a deny rule but the policy effect only considers allow",
+		}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got []string
+			for _, issue := range lintFiles(t, tc.model, tc.policy) {
+				got = append(got, issue.String())
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Lint =\n%s\n期望值\n%s", strings.Join(got, "\n"), strings.Join(tc.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestLintDomain(t *testing.T) {
+	m, err := model.NewModelFromFile("../model_domain.pml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	issues := Lint(m, []Rule{
+		{Line: 1, Ptype: "p", Values: []string{"admin", "default", "/users", "GET"}},
+		{Line: 2, Ptype: "p", Values: []string{"admin", "acme", "/users", "GET"}},
+		{Line: 3, Ptype: "g", Values: []string{"zhangsan", "admin", "default"}},
+	})
+	if len(issues) != 1 || issues[0].Kind != KindUnreachable || issues[0].Line != 2 {
+		t.Errorf("Lint = %v; 期望第 2 行 unreachable", issues)
+	}
+}
+
+func TestExplain(t *testing.T) {
+	e, err := casbin.NewEnforcer("../model_pattern.pml", "../policy_pattern.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ex, err := Explain(e, "wangwu", "/users/1/password", "PUT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ex.Allowed {
+		t.Error("deny 规则应该优先")
+	}
+	if strings.Join(ex.Decisive, ",") != "wangwu,/users/:id/password,*,deny" {
+		t.Errorf("Decisive = %v", ex.Decisive)
+	}
+	want := []Match{
+		{Rule: []string{"yunwei", "/users/:id/*", "*", "allow"}, Chain: []string{"wangwu", "yunwei"}},
+		{Rule: []string{"wangwu", "/users/:id/password", "*", "deny"}, Chain: []string{"wangwu"}},
+	}
+	if !reflect.DeepEqual(ex.Matches, want) {
+		t.Errorf("Matches = %v; 期望值 %v", ex.Matches, want)
+	}
+}
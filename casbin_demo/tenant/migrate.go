@@ -0,0 +1,45 @@
+package tenant
+
+import (
+	"slices"
+
+	"github.com/casbin/casbin/v2"
+)
+
+// MigrateFlat copies the policies and role assignments of a single-tenant
+// enforcer into dom of dst and returns the number of rules added. Rules that
+// already exist in dst are skipped, so the migration can be run repeatedly.
+//
+// p 规则的租户插入在 sub 之后: p, admin, /users, GET -> p, admin, dom, /users, GET;
+// g 规则的租户追加在最后: g, zhangsan, admin -> g, zhangsan, admin, dom
+func MigrateFlat(src *casbin.Enforcer, dst *Enforcer, dom string) (int, error) {
+	policies, err := src.GetPolicy()
+	if err != nil {
+		return 0, err
+	}
+	groupings, err := src.GetGroupingPolicy()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, rule := range policies {
+		ok, err := dst.AddPolicy(slices.Insert(slices.Clone(rule), 1, dom))
+		if err != nil {
+			return count, err
+		}
+		if ok {
+			count++
+		}
+	}
+	for _, rule := range groupings {
+		ok, err := dst.AddGroupingPolicy(append(slices.Clone(rule), dom))
+		if err != nil {
+			return count, err
+		}
+		if ok {
+			count++
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,87 @@
+// Package tenant 多租户 RBAC, 封装使用 g = _, _, _ 角色定义的 Enforcer (见 model_domain.pml).
+package tenant
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/casbin/casbin/v2"
+)
+
+// DefaultDomain 迁移单租户策略时使用的默认租户
+const DefaultDomain = "default"
+
+// Enforcer 支持租户的 Enforcer, 所有方法都显式传入租户
+type Enforcer struct {
+	*casbin.Enforcer
+}
+
+// NewEnforcer creates an enforcer from a domain model and an optional adapter
+// (a policy file path or a persist.Adapter).
+func NewEnforcer(modelPath string, adapter ...interface{}) (*Enforcer, error) {
+	e, err := casbin.NewEnforcer(append([]interface{}{modelPath}, adapter...)...)
+	if err != nil {
+		return nil, err
+	}
+	if tokens := e.GetModel()["g"]["g"]; tokens == nil || len(tokens.Tokens) != 3 {
+		return nil, fmt.Errorf("tenant: model %s must define g = _, _, _", modelPath)
+	}
+	return &Enforcer{Enforcer: e}, nil
+}
+
+// Allow reports whether sub may perform act on obj within dom.
+func (e *Enforcer) Allow(sub, dom, obj, act string) (bool, error) {
+	return e.Enforce(sub, dom, obj, act)
+}
+
+// AddPermission grants act on obj to role within dom.
+func (e *Enforcer) AddPermission(role, dom, obj, act string) (bool, error) {
+	return e.AddPolicy(role, dom, obj, act)
+}
+
+// RemovePermission revokes act on obj from role within dom.
+func (e *Enforcer) RemovePermission(role, dom, obj, act string) (bool, error) {
+	return e.RemovePolicy(role, dom, obj, act)
+}
+
+// AddRole assigns role to user within dom.
+func (e *Enforcer) AddRole(user, role, dom string) (bool, error) {
+	return e.AddRoleForUserInDomain(user, role, dom)
+}
+
+// RemoveRole removes role from user within dom.
+func (e *Enforcer) RemoveRole(user, role, dom string) (bool, error) {
+	return e.DeleteRoleForUserInDomain(user, role, dom)
+}
+
+// Roles returns the roles user holds directly within dom.
+func (e *Enforcer) Roles(user, dom string) []string {
+	roles := e.GetRolesForUserInDomain(user, dom)
+	slices.Sort(roles)
+	return roles
+}
+
+// RolesByDomain returns the roles of user in every domain the user belongs to.
+func (e *Enforcer) RolesByDomain(user string) (map[string][]string, error) {
+	domains, err := e.GetDomainsForUser(user)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string][]string, len(domains))
+	for _, dom := range domains {
+		if roles := e.Roles(user, dom); len(roles) > 0 {
+			result[dom] = roles
+		}
+	}
+	return result, nil
+}
+
+// Domains returns every domain that has a policy or a role assignment.
+func (e *Enforcer) Domains() ([]string, error) {
+	domains, err := e.GetAllDomains()
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(domains)
+	return slices.Compact(domains), nil
+}
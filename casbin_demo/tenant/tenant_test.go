@@ -0,0 +1,120 @@
+package tenant
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/casbin/casbin/v2"
+	fileadapter "github.com/casbin/casbin/v2/persist/file-adapter"
+)
+
+func newTestEnforcer(t *testing.T) *Enforcer {
+	t.Helper()
+	e, err := NewEnforcer("../model_domain.pml", "../policy_domain.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.EnableAutoSave(false)
+	return e
+}
+
+func TestAllow(t *testing.T) {
+	e := newTestEnforcer(t)
+	cases := []struct {
+		name string
+		sub  string
+		dom  string
+		obj  string
+		act  string
+		want bool
+	}{
+		{"默认租户的管理员", "zhangsan", "default", "/users", "POST", true},
+		{"acme 中只是运维", "zhangsan", "acme", "/users", "POST", false},
+		{"acme 中的运维权限", "zhangsan", "acme", "/index", "GET", true},
+		{"默认租户的运维", "wangwu", "default", "/users", "POST", false},
+		{"acme 中的管理员", "wangwu", "acme", "/users", "POST", true},
+		{"不存在的租户", "zhangsan", "other", "/index", "GET", false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := e.Allow(tc.sub, tc.dom, tc.obj, tc.act)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("Allow(%s, %s, %s, %s) = %v; 期望值 %v", tc.sub, tc.dom, tc.obj, tc.act, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRolesByDomain(t *testing.T) {
+	e := newTestEnforcer(t)
+	if _, err := e.AddRole("zhangsan", "auditor", "acme"); err != nil {
+		t.Fatal(err)
+	}
+	got, err := e.RolesByDomain("zhangsan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{"default": {"admin"}, "acme": {"auditor", "yunwei"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("RolesByDomain = %v; 期望值 %v", got, want)
+	}
+
+	if _, err := e.RemoveRole("zhangsan", "admin", "default"); err != nil {
+		t.Fatal(err)
+	}
+	if roles := e.Roles("zhangsan", "default"); len(roles) != 0 {
+		t.Errorf("Roles = %v; 期望为空", roles)
+	}
+	domains, err := e.Domains()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(domains, []string{"acme", "default"}) {
+		t.Errorf("Domains = %v", domains)
+	}
+}
+
+func TestNewEnforcerRejectsFlatModel(t *testing.T) {
+	if _, err := NewEnforcer("../model.pml", "../policy.csv"); err == nil {
+		t.Error("单租户模型应该返回错误")
+	}
+}
+
+func TestMigrateFlat(t *testing.T) {
+	src, err := casbin.NewEnforcer("../model.pml", "../policy.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(t.TempDir(), "policy.csv")
+	if err := os.WriteFile(out, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	dst, err := NewEnforcer("../model_domain.pml", fileadapter.NewAdapter(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []int{8, 0} {
+		n, err := MigrateFlat(src, dst, DefaultDomain)
+		if err != nil || n != want {
+			t.Errorf("第 %d 次迁移 = %d, %v; 期望值 %d", i+1, n, err, want)
+		}
+	}
+
+	// 迁移后默认租户中的结果与原策略一致
+	for _, sub := range []string{"zhangsan", "wangwu"} {
+		for _, obj := range []string{"/index", "/home", "/users"} {
+			for _, act := range []string{"GET", "POST"} {
+				want, _ := src.Enforce(sub, obj, act)
+				got, _ := dst.Allow(sub, DefaultDomain, obj, act)
+				if got != want {
+					t.Errorf("Allow(%s, %s, %s) = %v; 期望值 %v", sub, obj, act, got, want)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package watcher
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+const defaultPollInterval = 500 * time.Millisecond
+
+// FileTransport 同一台机器上的多个进程通过追加写同一个文件广播消息,
+// 每个进程轮询文件大小读取新增的行. 以 O_APPEND 写入的单行在本地文件系统上是原子的
+type FileTransport struct {
+	path     string
+	interval time.Duration
+	mu       sync.Mutex
+	done     chan struct{}
+	once     sync.Once
+}
+
+// NewFileTransport creates a transport on path, polling every interval
+// (500ms if zero). The file is created on the first publish.
+func NewFileTransport(path string, interval time.Duration) *FileTransport {
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+	return &FileTransport{path: path, interval: interval, done: make(chan struct{})}
+}
+
+func (t *FileTransport) Publish(data []byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	f, err := os.OpenFile(t.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Subscribe 从文件当前的末尾开始读取, 之前的消息不会重放
+func (t *FileTransport) Subscribe(handler func(data []byte)) error {
+	var offset int64
+	if info, err := os.Stat(t.path); err == nil {
+		offset = info.Size()
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	go t.poll(offset, handler)
+	return nil
+}
+
+func (t *FileTransport) poll(offset int64, handler func(data []byte)) {
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-t.done:
+			return
+		}
+		info, err := os.Stat(t.path)
+		if err != nil {
+			continue
+		}
+		if info.Size() < offset {
+			// 文件被截断, 从头开始读
+			offset = 0
+		}
+		if info.Size() == offset {
+			continue
+		}
+		n, err := t.read(offset, info.Size(), handler)
+		if err != nil {
+			log.Printf("watcher: read %s: %v", t.path, err)
+		}
+		offset += n
+	}
+}
+
+// read 读取 [offset, size) 中完整的行, 返回已处理的字节数, 不完整的最后一行留到下次读取
+func (t *FileTransport) read(offset, size int64, handler func(data []byte)) (int64, error) {
+	f, err := os.Open(t.path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	buf := make([]byte, size-offset)
+	if _, err := f.ReadAt(buf, offset); err != nil && err != io.EOF {
+		return 0, err
+	}
+	var consumed int64
+	for {
+		i := bytes.IndexByte(buf, '\n')
+		if i < 0 {
+			return consumed, nil
+		}
+		if i > 0 {
+			handler(buf[:i])
+		}
+		buf = buf[i+1:]
+		consumed += int64(i + 1)
+	}
+}
+
+func (t *FileTransport) Close() error {
+	t.once.Do(func() { close(t.done) })
+	return nil
+}
@@ -0,0 +1,114 @@
+package watcher
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/casbin/casbin/v2/model"
+)
+
+// Op 策略修改的类型
+type Op string
+
+const (
+	OpReload         Op = "reload" // 重新加载全部策略
+	OpAdd            Op = "add"
+	OpRemove         Op = "remove"
+	OpRemoveFiltered Op = "remove_filtered"
+	OpUpdate         Op = "update"
+)
+
+// Message 实例之间广播的一次修改
+type Message struct {
+	Source      string     `json:"source"` // 发起修改的 Watcher, 用于忽略自己的消息
+	Op          Op         `json:"op"`
+	Sec         string     `json:"sec,omitempty"`
+	Ptype       string     `json:"ptype,omitempty"`
+	Rules       [][]string `json:"rules,omitempty"`
+	NewRules    [][]string `json:"new_rules,omitempty"`
+	FieldIndex  int        `json:"field_index,omitempty"`
+	FieldValues []string   `json:"field_values,omitempty"`
+}
+
+// ParseMessage decodes the string passed to the update callback.
+func ParseMessage(s string) (Message, error) {
+	var msg Message
+	err := json.Unmarshal([]byte(s), &msg)
+	return msg, err
+}
+
+// Apply applies msg to the in-memory policy of e without writing to its
+// adapter. The caller must hold the enforcer lock if e is shared.
+func Apply(e *casbin.Enforcer, msg Message) error {
+	m := e.GetModel()
+	switch msg.Op {
+	case OpReload:
+		return e.LoadPolicy()
+	case OpAdd:
+		affected, err := m.AddPoliciesWithAffected(msg.Sec, msg.Ptype, msg.Rules)
+		if err != nil {
+			return err
+		}
+		return buildRoleLinks(e, msg, model.PolicyAdd, affected)
+	case OpRemove:
+		affected, err := m.RemovePoliciesWithAffected(msg.Sec, msg.Ptype, msg.Rules)
+		if err != nil {
+			return err
+		}
+		return buildRoleLinks(e, msg, model.PolicyRemove, affected)
+	case OpRemoveFiltered:
+		_, affected, err := m.RemoveFilteredPolicy(msg.Sec, msg.Ptype, msg.FieldIndex, msg.FieldValues...)
+		if err != nil {
+			return err
+		}
+		return buildRoleLinks(e, msg, model.PolicyRemove, affected)
+	case OpUpdate:
+		if _, err := m.UpdatePolicies(msg.Sec, msg.Ptype, msg.Rules, msg.NewRules); err != nil {
+			return err
+		}
+		if err := buildRoleLinks(e, msg, model.PolicyRemove, msg.Rules); err != nil {
+			return err
+		}
+		return buildRoleLinks(e, msg, model.PolicyAdd, msg.NewRules)
+	default:
+		return fmt.Errorf("watcher: unknown op %q", msg.Op)
+	}
+}
+
+// buildRoleLinks g 规则变化时同步更新角色管理器
+func buildRoleLinks(e *casbin.Enforcer, msg Message, op model.PolicyOp, rules [][]string) error {
+	if msg.Sec != "g" || len(rules) == 0 {
+		return nil
+	}
+	return e.BuildIncrementalRoleLinks(op, msg.Ptype, rules)
+}
+
+// Sync sets w as the watcher of e and applies the changes of other instances
+// incrementally. Each hook is called after a message has been applied.
+func Sync(e *casbin.SyncedEnforcer, w *Watcher, hooks ...func(Message)) error {
+	if err := e.SetWatcher(w); err != nil {
+		return err
+	}
+	return w.SetUpdateCallback(func(s string) {
+		msg, err := ParseMessage(s)
+		if err != nil {
+			return
+		}
+		if msg.Op == OpReload {
+			// SyncedEnforcer.LoadPolicy 自己加锁
+			err = e.LoadPolicy()
+		} else {
+			e.GetLock().Lock()
+			err = Apply(e.Enforcer, msg)
+			e.GetLock().Unlock()
+		}
+		if err != nil {
+			log.Printf("watcher: apply %s: %v", msg.Op, err)
+		}
+		for _, hook := range hooks {
+			hook(msg)
+		}
+	})
+}
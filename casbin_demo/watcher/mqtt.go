@@ -0,0 +1,45 @@
+package watcher
+
+import (
+	"errors"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+const mqttTimeout = 5 * time.Second
+
+// MQTTTransport 通过一个 MQTT 主题广播消息. 客户端断线重连后需要重新订阅,
+// 可以设置 CleanSession(false) 或在 OnConnect 中重新调用 Subscribe
+type MQTTTransport struct {
+	client mqtt.Client
+	topic  string
+	qos    byte
+}
+
+// NewMQTTTransport creates a transport on topic using a connected client.
+func NewMQTTTransport(client mqtt.Client, topic string, qos byte) *MQTTTransport {
+	return &MQTTTransport{client: client, topic: topic, qos: qos}
+}
+
+func (t *MQTTTransport) Publish(data []byte) error {
+	return wait(t.client.Publish(t.topic, t.qos, false, data))
+}
+
+func (t *MQTTTransport) Subscribe(handler func(data []byte)) error {
+	return wait(t.client.Subscribe(t.topic, t.qos, func(client mqtt.Client, message mqtt.Message) {
+		handler(message.Payload())
+	}))
+}
+
+// Close 取消订阅, 不断开客户端
+func (t *MQTTTransport) Close() error {
+	return wait(t.client.Unsubscribe(t.topic))
+}
+
+func wait(token mqtt.Token) error {
+	if !token.WaitTimeout(mqttTimeout) {
+		return errors.New("watcher: mqtt timeout")
+	}
+	return token.Error()
+}
@@ -0,0 +1,53 @@
+package watcher
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const redisTimeout = 5 * time.Second
+
+// RedisTransport 通过 Redis 发布订阅广播消息, 断线后 go-redis 会自动重新订阅
+type RedisTransport struct {
+	client  redis.UniversalClient
+	channel string
+	pubsub  *redis.PubSub
+}
+
+// NewRedisTransport creates a transport on a Redis pub/sub channel.
+func NewRedisTransport(client redis.UniversalClient, channel string) *RedisTransport {
+	return &RedisTransport{client: client, channel: channel}
+}
+
+func (t *RedisTransport) Publish(data []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	return t.client.Publish(ctx, t.channel, data).Err()
+}
+
+func (t *RedisTransport) Subscribe(handler func(data []byte)) error {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	pubsub := t.client.Subscribe(ctx, t.channel)
+	// 等待订阅确认, 之后发布的消息不会丢失
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return err
+	}
+	t.pubsub = pubsub
+	go func() {
+		for message := range pubsub.Channel() {
+			handler([]byte(message.Payload))
+		}
+	}()
+	return nil
+}
+
+func (t *RedisTransport) Close() error {
+	if t.pubsub == nil {
+		return nil
+	}
+	return t.pubsub.Close()
+}
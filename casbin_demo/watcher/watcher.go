@@ -0,0 +1,167 @@
+// Package watcher 在多个 Enforcer 实例之间同步策略修改.
+//
+// 每个实例的 Watcher 把本地的修改编码为 Message 通过 Transport 广播, 其它实例收到后
+// 增量应用到内存中 (不会再次写入适配器, 因为修改已经由发起方保存). 传输方式有
+// MQTT 主题、Redis 发布订阅和本地文件三种.
+package watcher
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"sync"
+
+	"github.com/casbin/casbin/v2/model"
+	"github.com/casbin/casbin/v2/persist"
+)
+
+// Transport 广播和接收编码后的消息
+type Transport interface {
+	Publish(data []byte) error
+	// Subscribe 注册接收回调, 只会调用一次
+	Subscribe(handler func(data []byte)) error
+	Close() error
+}
+
+// Watcher 实现 persist.WatcherEx 和 persist.UpdatableWatcher
+type Watcher struct {
+	id        string
+	transport Transport
+
+	mu       sync.Mutex
+	callback func(string)
+	queue    []string
+	signal   chan struct{}
+	done     chan struct{}
+	once     sync.Once
+}
+
+var (
+	_ persist.WatcherEx        = (*Watcher)(nil)
+	_ persist.UpdatableWatcher = (*Watcher)(nil)
+)
+
+// New creates a watcher on the transport. The update callback receives the
+// JSON encoded Message of other instances, see ParseMessage.
+func New(t Transport) (*Watcher, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	w := &Watcher{
+		id:        hex.EncodeToString(id),
+		transport: t,
+		signal:    make(chan struct{}, 1),
+		done:      make(chan struct{}),
+	}
+	if err := t.Subscribe(w.receive); err != nil {
+		return nil, err
+	}
+	go w.run()
+	return w, nil
+}
+
+// receive 由 Transport 的协程调用, 只把消息放入队列. 回调中会获取 Enforcer 的锁,
+// 而本实例可能正持有该锁等待发布完成, 直接在这里调用回调可能死锁
+func (w *Watcher) receive(data []byte) {
+	var msg Message
+	if err := json.Unmarshal(data, &msg); err != nil {
+		log.Printf("watcher: invalid message: %v", err)
+		return
+	}
+	if msg.Source == w.id {
+		return
+	}
+	w.mu.Lock()
+	w.queue = append(w.queue, string(data))
+	w.mu.Unlock()
+	select {
+	case w.signal <- struct{}{}:
+	default:
+	}
+}
+
+func (w *Watcher) run() {
+	for {
+		select {
+		case <-w.signal:
+		case <-w.done:
+			return
+		}
+		w.mu.Lock()
+		queue, callback := w.queue, w.callback
+		w.queue = nil
+		w.mu.Unlock()
+		if callback == nil {
+			continue
+		}
+		for _, msg := range queue {
+			callback(msg)
+		}
+	}
+}
+
+// SetUpdateCallback sets the function called with each message of other instances.
+func (w *Watcher) SetUpdateCallback(callback func(string)) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.callback = callback
+	return nil
+}
+
+// Close stops the watcher and closes the transport.
+func (w *Watcher) Close() {
+	w.once.Do(func() {
+		close(w.done)
+		if err := w.transport.Close(); err != nil {
+			log.Printf("watcher: close transport: %v", err)
+		}
+	})
+}
+
+func (w *Watcher) publish(msg Message) error {
+	msg.Source = w.id
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return w.transport.Publish(data)
+}
+
+// Update asks other instances to reload the whole policy.
+func (w *Watcher) Update() error {
+	return w.publish(Message{Op: OpReload})
+}
+
+func (w *Watcher) UpdateForAddPolicy(sec, ptype string, params ...string) error {
+	return w.publish(Message{Op: OpAdd, Sec: sec, Ptype: ptype, Rules: [][]string{params}})
+}
+
+func (w *Watcher) UpdateForRemovePolicy(sec, ptype string, params ...string) error {
+	return w.publish(Message{Op: OpRemove, Sec: sec, Ptype: ptype, Rules: [][]string{params}})
+}
+
+func (w *Watcher) UpdateForRemoveFilteredPolicy(sec, ptype string, fieldIndex int, fieldValues ...string) error {
+	return w.publish(Message{Op: OpRemoveFiltered, Sec: sec, Ptype: ptype, FieldIndex: fieldIndex, FieldValues: fieldValues})
+}
+
+func (w *Watcher) UpdateForSavePolicy(model model.Model) error {
+	return w.publish(Message{Op: OpReload})
+}
+
+func (w *Watcher) UpdateForAddPolicies(sec string, ptype string, rules ...[]string) error {
+	return w.publish(Message{Op: OpAdd, Sec: sec, Ptype: ptype, Rules: rules})
+}
+
+func (w *Watcher) UpdateForRemovePolicies(sec string, ptype string, rules ...[]string) error {
+	return w.publish(Message{Op: OpRemove, Sec: sec, Ptype: ptype, Rules: rules})
+}
+
+func (w *Watcher) UpdateForUpdatePolicy(sec string, ptype string, oldRule, newRule []string) error {
+	return w.publish(Message{Op: OpUpdate, Sec: sec, Ptype: ptype, Rules: [][]string{oldRule}, NewRules: [][]string{newRule}})
+}
+
+func (w *Watcher) UpdateForUpdatePolicies(sec string, ptype string, oldRules, newRules [][]string) error {
+	return w.publish(Message{Op: OpUpdate, Sec: sec, Ptype: ptype, Rules: oldRules, NewRules: newRules})
+}
@@ -0,0 +1,153 @@
+package watcher
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"casbin_demo/adapter"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/casbin/casbin/v2"
+	"github.com/redis/go-redis/v9"
+	_ "modernc.org/sqlite"
+)
+
+// newInstance 模拟一个服务副本: 共享同一个数据库, 各自有 Enforcer 和 Watcher
+func newInstance(t *testing.T, dbPath string, transport Transport) *casbin.SyncedEnforcer {
+	t.Helper()
+	a, err := adapter.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { a.Close() })
+	e, err := casbin.NewSyncedEnforcer("../model.pml", a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := New(transport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(w.Close)
+	if err := Sync(e, w); err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("等待%s超时", what)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func enforce(e *casbin.SyncedEnforcer, sub, obj, act string) bool {
+	ok, _ := e.Enforce(sub, obj, act)
+	return ok
+}
+
+func testSync(t *testing.T, newTransport func() Transport) {
+	dbPath := filepath.Join(t.TempDir(), "casbin.db")
+	a, err := adapter.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := adapter.ImportCSV(a, "../policy.csv"); err != nil {
+		t.Fatal(err)
+	}
+	a.Close()
+
+	e1 := newInstance(t, dbPath, newTransport())
+	e2 := newInstance(t, dbPath, newTransport())
+
+	// 增量添加权限和角色
+	if _, err := e1.AddPolicy("kuaiji", "/roleusers", "GET"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e1.AddRoleForUser("lisi", "kuaiji"); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "添加角色同步", func() bool { return enforce(e2, "lisi", "/roleusers", "GET") })
+
+	// 增量删除, 反方向同步
+	if _, err := e2.DeleteRoleForUser("lisi", "kuaiji"); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "删除角色同步", func() bool { return !enforce(e1, "lisi", "/roleusers", "GET") })
+
+	// 按条件删除
+	if _, err := e1.RemoveFilteredPolicy(0, "admin", "/users"); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "按条件删除同步", func() bool { return !enforce(e2, "zhangsan", "/users", "POST") })
+
+	// 接收方只修改内存, 不会重复写入数据库
+	policies, _ := e2.GetPolicy()
+	if len(policies) != 5 {
+		t.Errorf("e2 策略数 = %d; 期望值 5: %v", len(policies), policies)
+	}
+	if !slices.ContainsFunc(policies, func(p []string) bool { return p[0] == "kuaiji" }) {
+		t.Error("e2 缺少 kuaiji 的权限")
+	}
+	a, err = adapter.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	if n, _ := a.Count(); n != 7 {
+		t.Errorf("数据库规则数 = %d; 期望值 7", n)
+	}
+
+	// SavePolicy 之后其它实例整体重新加载
+	e1.GetModel().AddPolicy("p", "p", []string{"lisi", "/index", "GET"})
+	if err := e1.SavePolicy(); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "重新加载", func() bool { return enforce(e2, "lisi", "/index", "GET") })
+}
+
+func TestFileTransport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.events")
+	testSync(t, func() Transport { return NewFileTransport(path, 10*time.Millisecond) })
+}
+
+func TestRedisTransport(t *testing.T) {
+	server := miniredis.RunT(t)
+	testSync(t, func() Transport {
+		client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+		t.Cleanup(func() { client.Close() })
+		return NewRedisTransport(client, "casbin")
+	})
+}
+
+func TestFileTransportOrder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.events")
+	transport := NewFileTransport(path, 10*time.Millisecond)
+	defer transport.Close()
+	received := make(chan string, 4)
+	if err := transport.Subscribe(func(data []byte) { received <- string(data) }); err != nil {
+		t.Fatal(err)
+	}
+	for _, msg := range []string{"a", "b"} {
+		if err := transport.Publish([]byte(msg)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, want := range []string{"a", "b"} {
+		select {
+		case got := <-received:
+			if got != want {
+				t.Errorf("收到 %q; 期望值 %q", got, want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("等待消息超时")
+		}
+	}
+}
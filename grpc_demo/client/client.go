@@ -1,19 +1,94 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"grpc_demo/client_proxy"
+	"grpc_demo/handler"
+	"grpc_demo/hello_client"
+	"grpc_demo/rpc_balancer"
+	"grpc_demo/rpc_pool"
+	"grpc_demo/rpc_stub"
+	"log"
+	"strings"
+	"time"
 )
 
 func main() {
-	// 1.建立连接
-	client := client_proxy.NewHelloServiceClient("tcp", "localhost:1234")
+	codecName := flag.String("codec", "gob", "编码格式, gob 或 json")
+	timeout := flag.Duration("timeout", 3*time.Second, "调用超时时间")
+	addrs := flag.String("addrs", "", "逗号分隔的后端地址, 设置后在多个后端之间负载均衡")
+	addrsFile := flag.String("addrs-file", "", "后端地址文件, 每行一个, 修改后自动生效")
+	srvName := flag.String("srv", "", "通过 DNS SRV 记录发现后端, 如 _hello._tcp.example.com")
+	conns := flag.Int("conns", 1, "连接池的最大连接数")
+	policyName := flag.String("policy", "round_robin", "负载均衡策略: round_robin, least_outstanding, consistent_hash")
+	flag.Parse()
+	codec, err := rpc_stub.ParseCodec(*codecName)
+	if err != nil {
+		log.Fatal(err)
+	}
 
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
 	var reply string
 
-	err := client.Hello("bobby", &reply)
+	if resolver := newResolver(*addrs, *addrsFile, *srvName); resolver != nil {
+		policy, err := rpc_balancer.ParsePolicy(*policyName)
+		if err != nil {
+			log.Fatal(err)
+		}
+		client, err := rpc_balancer.New(ctx, rpc_balancer.Config{Resolver: resolver, Policy: policy, Codec: codec})
+		if err != nil {
+			log.Fatalf("解析后端失败: %v", err)
+		}
+		defer client.Close()
+		if err := client.Call(ctx, handler.HelloServiceName+".Hello", "bobby", &reply); err != nil {
+			log.Fatalf("调用失败: %v", err)
+		}
+		fmt.Println(reply)
+		return
+	}
+
+	// 一个连接时直接使用生成的客户端
+	if *conns <= 1 {
+		client, err := hello_client.DialHelloService(ctx, "tcp", "localhost:1234", codec)
+		if err != nil {
+			log.Fatalf("连接失败: %v", err)
+		}
+		defer client.Close()
+		reply, err := client.Hello(ctx, "bobby")
+		if err != nil {
+			log.Fatalf("调用失败: %v", err)
+		}
+		fmt.Println(reply)
+		return
+	}
+
+	// 1.建立连接
+	client, err := client_proxy.NewHelloServiceClient("tcp", "localhost:1234",
+		client_proxy.WithCodec(codec), client_proxy.WithPool(rpc_pool.Config{MaxSize: *conns}))
 	if err != nil {
-		panic("调用失败")
+		log.Fatalf("连接失败: %v", err)
+	}
+	defer client.Close()
+
+	if err := client.Hello(ctx, "bobby", &reply); err != nil {
+		log.Fatalf("调用失败: %v", err)
 	}
 	fmt.Println(reply)
 }
+
+// newResolver 根据参数选择地址来源, 都没有设置时返回 nil
+func newResolver(addrs, addrsFile, srvName string) rpc_balancer.Resolver {
+	switch {
+	case addrs != "":
+		return rpc_balancer.Static(strings.Split(addrs, ",")...)
+	case addrsFile != "":
+		return rpc_balancer.NewFileResolver(addrsFile)
+	case srvName != "":
+		return &rpc_balancer.SRVResolver{Name: srvName}
+	default:
+		return nil
+	}
+}
@@ -1,26 +1,99 @@
 package client_proxy
 
 import (
+	"context"
 	"grpc_demo/handler"
+	"grpc_demo/rpc_pool"
+	"grpc_demo/rpc_stub"
 	"net/rpc"
+	"time"
 )
 
+// DefaultDialTimeout 建立连接的默认超时时间
+const DefaultDialTimeout = 5 * time.Second
+
+// HelloServiceStub HelloService 的客户端, 通过连接池调用, 连接断开后自动重连
 type HelloServiceStub struct {
-	*rpc.Client
+	network     string
+	address     string
+	codec       rpc_stub.Codec
+	dialTimeout time.Duration
+	pool        rpc_pool.Config
+	conns       *rpc_pool.Pool
 }
 
-func NewHelloServiceClient(protol, address string) HelloServiceStub {
-	conn, err := rpc.Dial(protol, address)
-	if err != nil {
-		panic("connect error")
+// Option 配置 HelloServiceStub
+type Option func(c *HelloServiceStub)
+
+// WithCodec selects the wire codec, the default is gob.
+func WithCodec(codec rpc_stub.Codec) Option {
+	return func(c *HelloServiceStub) {
+		c.codec = codec
+	}
+}
+
+// WithDialTimeout limits the time spent connecting and reconnecting.
+func WithDialTimeout(d time.Duration) Option {
+	return func(c *HelloServiceStub) {
+		c.dialTimeout = d
 	}
-	return HelloServiceStub{conn}
 }
 
-func (c *HelloServiceStub) Hello(request string, reply *string) error {
-	err := c.Call(handler.HelloServiceName+".Hello", request, reply)
+// WithPool configures the connection pool. cfg.Dial is ignored. By default
+// the stub uses a single connection.
+func WithPool(cfg rpc_pool.Config) Option {
+	return func(c *HelloServiceStub) {
+		c.pool = cfg
+	}
+}
+
+// NewHelloServiceClient connects to address and returns the dial error, if any.
+func NewHelloServiceClient(protol, address string, opts ...Option) (*HelloServiceStub, error) {
+	c := &HelloServiceStub{
+		network:     protol,
+		address:     address,
+		dialTimeout: DefaultDialTimeout,
+		pool:        rpc_pool.Config{MaxSize: 1},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	cfg := c.pool
+	cfg.Dial = c.dial
+	conns, err := rpc_pool.New(context.Background(), cfg)
 	if err != nil {
+		return nil, err
+	}
+	c.conns = conns
+	return c, nil
+}
+
+// Hello calls HelloService.Hello. It returns ctx.Err() if ctx is done before
+// the reply arrives; reply is only written on success.
+func (c *HelloServiceStub) Hello(ctx context.Context, request string, reply *string) error {
+	var r string
+	if err := c.conns.Call(ctx, handler.HelloServiceName+".Hello", request, &r); err != nil {
 		return err
 	}
+	*reply = r
 	return nil
 }
+
+// Stats returns the connection pool statistics.
+func (c *HelloServiceStub) Stats() rpc_pool.Stats {
+	return c.conns.Stats()
+}
+
+// Close closes the connections, later calls return rpc_pool.ErrClosed.
+func (c *HelloServiceStub) Close() error {
+	return c.conns.Close()
+}
+
+func (c *HelloServiceStub) dial(ctx context.Context) (*rpc.Client, error) {
+	if c.dialTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.dialTimeout)
+		defer cancel()
+	}
+	return rpc_stub.Dial(ctx, c.network, c.address, c.codec)
+}
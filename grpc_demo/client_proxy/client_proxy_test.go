@@ -0,0 +1,155 @@
+package client_proxy
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/rpc"
+	"sync"
+	"testing"
+	"time"
+
+	"grpc_demo/handler"
+	"grpc_demo/rpc_pool"
+	"grpc_demo/rpc_stub"
+	"grpc_demo/server_proxy"
+)
+
+// slowService Hello 在收到 "slow" 时阻塞, 用于测试超时
+type slowService struct {
+	handler.NewHelloService
+}
+
+func (s *slowService) Hello(request string, reply *string) error {
+	if request == "slow" {
+		time.Sleep(time.Second)
+	}
+	return s.NewHelloService.Hello(request, reply)
+}
+
+func startServer(t *testing.T, codec rpc_stub.Codec) string {
+	t.Helper()
+	addr, _ := startServerConns(t, codec)
+	return addr
+}
+
+// startServerConns 同时返回一个断开所有已有连接的函数
+func startServerConns(t *testing.T, codec rpc_stub.Codec) (string, func()) {
+	t.Helper()
+	server := rpc.NewServer()
+	if err := server_proxy.RegisterHelloServiceOn(server, &slowService{}); err != nil {
+		t.Fatal(err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	var mu sync.Mutex
+	var conns []net.Conn
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			conns = append(conns, conn)
+			mu.Unlock()
+			go rpc_stub.ServeConn(server, conn, codec)
+		}
+	}()
+	drop := func() {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, conn := range conns {
+			conn.Close()
+		}
+		conns = nil
+	}
+	return listener.Addr().String(), drop
+}
+
+func TestHelloServiceStub(t *testing.T) {
+	addr, drop := startServerConns(t, rpc_stub.JSON)
+	// 健康检查发现断开的连接, 不依赖调用的时机
+	ping := func(ctx context.Context, client *rpc.Client) error {
+		var reply string
+		return rpc_stub.Call(ctx, client, handler.HelloServiceName+".Hello", "ping", &reply)
+	}
+	c, err := NewHelloServiceClient("tcp", addr, WithCodec(rpc_stub.JSON), WithDialTimeout(time.Second),
+		WithPool(rpc_pool.Config{MaxSize: 1, HealthInterval: 10 * time.Millisecond, HealthCheck: ping}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	var reply string
+	if err := c.Hello(context.Background(), "bobby", &reply); err != nil || reply != "hellobobby" {
+		t.Errorf("Hello = %q, %v; 期望值 hellobobby", reply, err)
+	}
+
+	reply = ""
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := c.Hello(ctx, "slow", &reply); !errors.Is(err, context.DeadlineExceeded) || reply != "" {
+		t.Errorf("Hello = %q, %v; 期望 context.DeadlineExceeded", reply, err)
+	}
+
+	// 服务端断开连接后自动重连
+	drop()
+	deadline := time.Now().Add(2 * time.Second)
+	for c.Stats().Broken == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("没有发现断开的连接: %+v", c.Stats())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if err := c.Hello(context.Background(), "again", &reply); err != nil || reply != "helloagain" {
+		t.Errorf("重连后 Hello = %q, %v; 期望值 helloagain", reply, err)
+	}
+
+	c.Close()
+	if err := c.Hello(context.Background(), "closed", &reply); !errors.Is(err, rpc_pool.ErrClosed) {
+		t.Errorf("Close 后 Hello 错误 = %v; 期望 rpc_pool.ErrClosed", err)
+	}
+}
+
+func TestNewHelloServiceClientDialError(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+	if c, err := NewHelloServiceClient("tcp", addr); err == nil {
+		c.Close()
+		t.Error("连接失败时应该返回错误")
+	}
+}
+
+func TestHelloServiceStubPool(t *testing.T) {
+	addr := startServer(t, rpc_stub.Gob)
+	c, err := NewHelloServiceClient("tcp", addr, WithPool(rpc_pool.Config{MaxSize: 3}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// 并发的慢调用分散到多个连接, 连接数不超过上限
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var reply string
+			if err := c.Hello(context.Background(), "slow", &reply); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if stats := c.Stats(); stats.Open != 3 || stats.Dials != 3 || stats.Calls != 6 || stats.InFlight != 0 {
+		t.Errorf("Stats = %+v; 期望 3 个连接, 6 次调用", stats)
+	}
+}
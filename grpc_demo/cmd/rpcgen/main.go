@@ -0,0 +1,80 @@
+// rpcgen 根据接口生成 net/rpc 的服务注册代码和客户端, 在接口所在的目录通过 go:generate 调用:
+//
+//	//go:generate go run grpc_demo/cmd/rpcgen -type HelloService -name handler/HelloService -server hello_service_server_gen.go -client ../hello_client/hello_service_client_gen.go
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"grpc_demo/rpcgen"
+)
+
+func main() {
+	typeName := flag.String("type", "", "接口名, 必填")
+	dir := flag.String("dir", ".", "接口所在的包目录")
+	name := flag.String("name", "", "net/rpc 注册的服务名, 默认为接口名")
+	serverOut := flag.String("server", "", "服务注册代码的输出文件, 位于接口所在的包")
+	clientOut := flag.String("client", "", "客户端代码的输出文件")
+	clientPkg := flag.String("client-pkg", "", "客户端代码的包名, 默认为输出目录名")
+	flag.Parse()
+	log.SetFlags(0)
+	log.SetPrefix("rpcgen: ")
+
+	if *typeName == "" || (*serverOut == "" && *clientOut == "") {
+		flag.Usage()
+		os.Exit(2)
+	}
+	iface, err := rpcgen.Parse(*dir, *typeName)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *serverOut != "" {
+		src, err := rpcgen.GenerateServer(iface, *name)
+		if err != nil {
+			log.Fatal(err)
+		}
+		write(*serverOut, src)
+	}
+	if *clientOut != "" {
+		pkg := *clientPkg
+		if pkg == "" {
+			pkg, err = packageName(*dir, *clientOut, iface.Package)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+		src, err := rpcgen.GenerateClient(iface, *name, pkg)
+		if err != nil {
+			log.Fatal(err)
+		}
+		write(*clientOut, src)
+	}
+}
+
+// packageName 输出到接口所在的目录时使用同一个包, 否则使用输出目录名
+func packageName(srcDir, out, srcPkg string) (string, error) {
+	src, err := filepath.Abs(srcDir)
+	if err != nil {
+		return "", err
+	}
+	dst, err := filepath.Abs(filepath.Dir(out))
+	if err != nil {
+		return "", err
+	}
+	if src == dst {
+		return srcPkg, nil
+	}
+	return filepath.Base(dst), nil
+}
+
+func write(path string, src []byte) {
+	if err := os.WriteFile(path, src, 0o644); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("rpcgen: wrote", path)
+}
@@ -1,10 +1,19 @@
 import requests
 
+# 服务端需要以 HTTP 模式启动: go run ./server -http
 request = {
     "id":0,
     "params":["boby"],
-    "method": "HelloService.Hello"
+    "method": "handler/HelloService.Hello"
 }
 
 rsp = requests.post("http://localhost:1234/jsonrpc", json=request)
-print(rsp.text)
\ No newline at end of file
+print(rsp.text)
+
+# JSON-RPC 2.0 批量请求
+batch = [
+    {"jsonrpc": "2.0", "id": 1, "params": ["boby"], "method": "handler/HelloService.Hello"},
+    {"jsonrpc": "2.0", "id": 2, "params": ["alice"], "method": "handler/HelloService.Hello"},
+]
+rsp = requests.post("http://localhost:1234/jsonrpc", json=batch)
+print(rsp.text)
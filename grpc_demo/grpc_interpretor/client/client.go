@@ -6,6 +6,8 @@ import (
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/metadata"
 	"grpc_demo/grpc_interpretor/proto"
+	"grpc_demo/interceptors"
+	"log/slog"
 	"time"
 )
 
@@ -14,16 +16,9 @@ const (
 )
 
 func main() {
-	// 客户端拦截器
-	interceptor := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
-		start := time.Now()
-		err := invoker(ctx, method, req, reply, cc, opts...)
-		fmt.Printf("耗时：%s\n", time.Since(start))
-		return err
-	}
-	var opts []grpc.DialOption
+	// 客户端拦截器: 传递请求 ID, 记录日志和耗时
+	opts := interceptors.DialOptions(slog.Default())
 	opts = append(opts, grpc.WithInsecure())
-	opts = append(opts, grpc.WithUnaryInterceptor(interceptor))
 
 	conn, err := grpc.Dial("127.0.0.1:50051", opts...)
 	if err != nil {
@@ -6,6 +6,8 @@ import (
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/metadata"
 	"grpc_demo/grpc_interpretor/proto"
+	"grpc_demo/interceptors"
+	"log/slog"
 	"net"
 )
 
@@ -26,16 +28,9 @@ func (s *Server) SayHello(ctx context.Context, request *proto.HelloRequest) (*pr
 }
 
 func main() {
-	// 服务端拦截器
-	interceptor := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
-		fmt.Println("拦截器----------收到请求")
-		res, err := handler(ctx, req)
-		// TODO 可以统计服务端的函数执行时间
-		fmt.Printf("请求已完成，res:%s,err:%s\n", res, err)
-		return res, err
-	}
-	opt1 := grpc.UnaryInterceptor(interceptor)
-	g := grpc.NewServer(opt1)
+	// 服务端拦截器: 请求 ID、日志 (含耗时) 和 panic 恢复
+	opts := interceptors.ServerOptions(slog.Default())
+	g := grpc.NewServer(opts...)
 	proto.RegisterGreeterServer(g, &Server{})
 	listen, err := net.Listen("tcp", "0.0.0.0:50051")
 	if err != nil {
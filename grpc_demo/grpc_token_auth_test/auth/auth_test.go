@@ -0,0 +1,166 @@
+package auth
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"grpc_demo/grpc_token_auth_test/proto"
+)
+
+var testKey = []byte("test-secret")
+
+type greeter struct {
+	proto.UnimplementedGreeterServer
+}
+
+func (greeter) SayHello(ctx context.Context, request *proto.HelloRequest) (*proto.HelloReply, error) {
+	claims, _ := ClaimsFromContext(ctx)
+	return &proto.HelloReply{Message: "hello " + claims.Subject}, nil
+}
+
+func startServer(t *testing.T) *bufconn.Listener {
+	t.Helper()
+	a := NewAuthenticator(testKey, map[string][]string{"/Greeter/SayHello": {"greeter:read"}})
+	s := grpc.NewServer(grpc.UnaryInterceptor(a.UnaryServerInterceptor()), grpc.StreamInterceptor(a.StreamServerInterceptor()))
+	proto.RegisterGreeterServer(s, greeter{})
+	l := bufconn.Listen(1 << 16)
+	go s.Serve(l)
+	t.Cleanup(s.Stop)
+	return l
+}
+
+func dial(t *testing.T, l *bufconn.Listener, opts ...grpc.DialOption) proto.GreeterClient {
+	t.Helper()
+	opts = append(opts,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return l.DialContext(ctx) }),
+	)
+	conn, err := grpc.Dial("bufnet", opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return proto.NewGreeterClient(conn)
+}
+
+func mustToken(t *testing.T, key []byte, scopes []string, ttl time.Duration) string {
+	t.Helper()
+	token, err := NewToken(key, "bobby", scopes, ttl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestUnary(t *testing.T) {
+	l := startServer(t)
+
+	// 通过 PerRPCCredentials 携带 token
+	client := dial(t, l, grpc.WithPerRPCCredentials(NewTokenCredentials(mustToken(t, testKey, []string{"greeter:read"}, time.Hour), false)))
+	reply, err := client.SayHello(context.Background(), &proto.HelloRequest{Name: "x"})
+	if err != nil || reply.Message != "hello bobby" {
+		t.Fatalf("SayHello = %v, %v; 期望值 hello bobby", reply, err)
+	}
+
+	plain := dial(t, l)
+	tests := []struct {
+		name          string
+		authorization string
+		want          codes.Code
+	}{
+		{"没有 token", "", codes.Unauthenticated},
+		{"不是 Bearer", "Basic " + mustToken(t, testKey, []string{"greeter:read"}, time.Hour), codes.Unauthenticated},
+		{"签名错误", "Bearer " + mustToken(t, []byte("other"), []string{"greeter:read"}, time.Hour), codes.Unauthenticated},
+		{"已过期", "Bearer " + mustToken(t, testKey, []string{"greeter:read"}, -time.Minute), codes.Unauthenticated},
+		{"格式错误", "Bearer not.a.jwt", codes.Unauthenticated},
+		{"缺少 scope", "Bearer " + mustToken(t, testKey, []string{"greeter:write"}, time.Hour), codes.PermissionDenied},
+		{"有效", "Bearer " + mustToken(t, testKey, []string{"greeter:read", "greeter:write"}, time.Hour), codes.OK},
+	}
+	for _, tt := range tests {
+		ctx := context.Background()
+		if tt.authorization != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, "authorization", tt.authorization)
+		}
+		_, err := plain.SayHello(ctx, &proto.HelloRequest{Name: "x"})
+		if got := status.Code(err); got != tt.want {
+			t.Errorf("%s: 状态码 = %v; 期望值 %v (%v)", tt.name, got, tt.want, err)
+		}
+	}
+}
+
+func TestNoneAlgorithmRejected(t *testing.T) {
+	// header {"alg":"none","typ":"JWT"}, payload {"sub":"bobby","exp":9999999999}
+	token := "eyJhbGciOiJub25lIiwidHlwIjoiSldUIn0.eyJzdWIiOiJib2JieSIsImV4cCI6OTk5OTk5OTk5OX0."
+	if _, err := ParseToken(testKey, token); err == nil {
+		t.Error("alg none 的 token 应该被拒绝")
+	}
+}
+
+// fakeStream 只提供 Context 的 ServerStream
+type fakeStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *fakeStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestStream(t *testing.T) {
+	a := NewAuthenticator(testKey, map[string][]string{"/Greeter/Chat": {"greeter:chat"}})
+	interceptor := a.StreamServerInterceptor()
+	info := &grpc.StreamServerInfo{FullMethod: "/Greeter/Chat", IsClientStream: true, IsServerStream: true}
+
+	tests := []struct {
+		name   string
+		scopes []string
+		want   codes.Code
+	}{
+		{"有效", []string{"greeter:chat"}, codes.OK},
+		{"缺少 scope", []string{"greeter:read"}, codes.PermissionDenied},
+		{"没有 token", nil, codes.Unauthenticated},
+	}
+	for _, tt := range tests {
+		ctx := context.Background()
+		if tt.scopes != nil {
+			md := metadata.Pairs("authorization", "Bearer "+mustToken(t, testKey, tt.scopes, time.Hour))
+			ctx = metadata.NewIncomingContext(ctx, md)
+		}
+		var subject string
+		err := interceptor(nil, &fakeStream{ctx: ctx}, info, func(srv interface{}, ss grpc.ServerStream) error {
+			claims, _ := ClaimsFromContext(ss.Context())
+			subject = claims.Subject
+			return nil
+		})
+		if got := status.Code(err); got != tt.want {
+			t.Errorf("%s: 状态码 = %v; 期望值 %v", tt.name, got, tt.want)
+		}
+		if tt.want == codes.OK && subject != "bobby" {
+			t.Errorf("%s: handler 中的 subject = %q; 期望值 bobby", tt.name, subject)
+		}
+	}
+}
+
+func TestTokenCredentials(t *testing.T) {
+	token := mustToken(t, testKey, []string{"greeter:read"}, time.Hour)
+	creds := NewTokenCredentials(token, true)
+	if !creds.RequireTransportSecurity() {
+		t.Error("RequireTransportSecurity 应该为 true")
+	}
+	md, err := creds.GetRequestMetadata(context.Background())
+	if err != nil || md["authorization"] != "Bearer "+token {
+		t.Errorf("GetRequestMetadata = %v, %v; 期望值 Bearer %s", md, err, token)
+	}
+	if _, err := NewTokenCredentials("", false).GetRequestMetadata(context.Background()); err == nil {
+		t.Error("没有 token 时应该返回错误")
+	}
+}
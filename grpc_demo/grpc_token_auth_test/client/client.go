@@ -1,11 +1,17 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/metadata"
+	"grpc_demo/grpc_token_auth_test/auth"
 	"grpc_demo/grpc_token_auth_test/proto"
+	"grpc_demo/interceptors"
+	"log"
+	"log/slog"
+	"os"
 	"time"
 )
 
@@ -14,16 +20,18 @@ const (
 )
 
 func main() {
-	// 客户端拦截器
-	interceptor := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
-		start := time.Now()
-		err := invoker(ctx, method, req, reply, cc, opts...)
-		fmt.Printf("耗时：%s\n", time.Since(start))
-		return err
+	token := flag.String("token", os.Getenv("GRPC_TOKEN"), "issuer 签发的 token, 默认读取环境变量 GRPC_TOKEN")
+	flag.Parse()
+	if *token == "" {
+		log.Fatal("缺少 token: 用 issuer 签发后通过 -token 或 GRPC_TOKEN 传入")
 	}
-	var opts []grpc.DialOption
+
+	// 客户端拦截器: 传递请求 ID, 记录日志和耗时
+	opts := interceptors.DialOptions(slog.Default())
 	opts = append(opts, grpc.WithInsecure())
-	opts = append(opts, grpc.WithUnaryInterceptor(interceptor))
+	// 每次调用自动带上 token, 演示使用明文连接, 所以不要求 TLS
+	creds := auth.NewTokenCredentials(*token, false)
+	opts = append(opts, grpc.WithPerRPCCredentials(creds))
 
 	conn, err := grpc.Dial("127.0.0.1:50051", opts...)
 	if err != nil {
@@ -32,15 +40,12 @@ func main() {
 	defer conn.Close()
 	client := proto.NewGreeterClient(conn)
 	md := metadata.Pairs("timestamp", time.Now().Format(timestampFormat))
-	//md := metadata.New(map[string]string{
-	//	"name":     "bobby",
-	//	"password": "immoc",
-	//})
 	ctx := metadata.NewOutgoingContext(context.Background(), md)
 
 	r, err := client.SayHello(ctx, &proto.HelloRequest{Name: "Hello world"})
 	if err != nil {
 		fmt.Printf("call server error: %s\n", err)
+		return
 	}
 	fmt.Printf("Reply is %s\n", r.Message)
 }
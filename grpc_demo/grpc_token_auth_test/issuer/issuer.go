@@ -0,0 +1,30 @@
+// issuer 用 HMAC 密钥签发 token 并输出到标准输出, 客户端只需要 token 不需要密钥:
+//
+//	export GRPC_TOKEN=$(go run ./grpc_token_auth_test/issuer -key $KEY -subject bobby)
+package main
+
+import (
+	"flag"
+	"fmt"
+	"grpc_demo/grpc_token_auth_test/auth"
+	"log"
+	"strings"
+	"time"
+)
+
+func main() {
+	key := flag.String("key", "", "签名 token 的 HMAC 密钥, 与服务端一致 (必填)")
+	subject := flag.String("subject", "bobby", "token 中的调用方")
+	scopes := flag.String("scopes", "greeter:read", "逗号分隔的 scope, 去掉 greeter:read 会返回 PermissionDenied")
+	ttl := flag.Duration("ttl", time.Hour, "token 的有效期")
+	flag.Parse()
+	if *key == "" {
+		log.Fatal("缺少 -key")
+	}
+
+	token, err := auth.NewToken([]byte(*key), *subject, strings.Split(*scopes, ","), *ttl)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(token)
+}
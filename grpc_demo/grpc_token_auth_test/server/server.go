@@ -2,10 +2,17 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"grpc_demo/grpc_token_auth_test/auth"
 	"grpc_demo/grpc_token_auth_test/proto"
+	"grpc_demo/interceptors"
+	"log"
+	"log/slog"
 	"net"
 )
 
@@ -14,28 +21,44 @@ type Server struct {
 
 func (s *Server) SayHello(ctx context.Context, request *proto.HelloRequest) (*proto.HelloReply, error) {
 	md, ok := metadata.FromIncomingContext(ctx)
-	if ok {
+	if !ok {
 		fmt.Println("get metadata error")
 	}
 	for key, val := range md {
+		// 不打印 token
+		if key == "authorization" {
+			continue
+		}
 		fmt.Println(key, val)
 	}
+	// 认证通过的调用方, 没有注册认证拦截器时拒绝请求
+	claims, ok := auth.ClaimsFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthenticated")
+	}
 	return &proto.HelloReply{
-		Message: "hello" + request.Name,
+		Message: "hello" + request.Name + ", " + claims.Subject,
 	}, nil
 }
 
 func main() {
-	// 服务端拦截器
-	interceptor := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
-		fmt.Println("拦截器----------收到请求")
-		res, err := handler(ctx, req)
-		// TODO 可以统计服务端的函数执行时间
-		fmt.Printf("请求已完成，res:%s,err:%s\n", res, err)
-		return res, err
+	key := flag.String("key", "", "校验 token 的 HMAC 密钥, 与 issuer 一致 (必填)")
+	flag.Parse()
+	if *key == "" {
+		log.Fatal("缺少 -key")
 	}
-	opt1 := grpc.UnaryInterceptor(interceptor)
-	g := grpc.NewServer(opt1)
+
+	// 服务端拦截器: 请求 ID、日志 (含耗时) 和 panic 恢复
+	opts := interceptors.ServerOptions(slog.Default())
+	// 认证在日志之后, 认证失败的请求也会记录日志
+	authenticator := auth.NewAuthenticator([]byte(*key), map[string][]string{
+		"/Greeter/SayHello": {"greeter:read"},
+	})
+	opts = append(opts,
+		grpc.ChainUnaryInterceptor(authenticator.UnaryServerInterceptor()),
+		grpc.ChainStreamInterceptor(authenticator.StreamServerInterceptor()),
+	)
+	g := grpc.NewServer(opts...)
 	proto.RegisterGreeterServer(g, &Server{})
 	listen, err := net.Listen("tcp", "0.0.0.0:50051")
 	if err != nil {
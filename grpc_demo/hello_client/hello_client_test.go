@@ -0,0 +1,73 @@
+package hello_client
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/rpc"
+	"testing"
+	"time"
+
+	"grpc_demo/handler"
+	"grpc_demo/rpc_stub"
+	"grpc_demo/server_proxy"
+)
+
+// slowService Hello 在收到 "slow" 时阻塞, 用于测试超时
+type slowService struct {
+	handler.NewHelloService
+}
+
+func (s *slowService) Hello(request string, reply *string) error {
+	if request == "slow" {
+		time.Sleep(time.Second)
+	}
+	return s.NewHelloService.Hello(request, reply)
+}
+
+func startServer(t *testing.T, codec rpc_stub.Codec) string {
+	t.Helper()
+	server := rpc.NewServer()
+	if err := server_proxy.RegisterHelloServiceOn(server, &slowService{}); err != nil {
+		t.Fatal(err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go rpc_stub.ServeConn(server, conn, codec)
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func TestHelloServiceClient(t *testing.T) {
+	for _, codec := range []rpc_stub.Codec{rpc_stub.Gob, rpc_stub.JSON} {
+		t.Run(codec.String(), func(t *testing.T) {
+			addr := startServer(t, codec)
+			client, err := DialHelloService(context.Background(), "tcp", addr, codec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer client.Close()
+
+			reply, err := client.Hello(context.Background(), "bobby")
+			if err != nil || reply != "hellobobby" {
+				t.Errorf("Hello = %q, %v; 期望值 hellobobby", reply, err)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			if _, err := client.Hello(ctx, "slow"); !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("Hello 错误 = %v; 期望 context.DeadlineExceeded", err)
+			}
+		})
+	}
+}
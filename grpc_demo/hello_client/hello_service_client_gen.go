@@ -0,0 +1,42 @@
+// Code generated by rpcgen. DO NOT EDIT.
+
+package hello_client
+
+import (
+	"context"
+	"grpc_demo/rpc_stub"
+	"net/rpc"
+)
+
+const helloServiceName = "handler/HelloService"
+
+// HelloServiceClient HelloService 的客户端, 每个方法都支持 context 的超时和取消
+type HelloServiceClient struct {
+	client *rpc.Client
+}
+
+// WrapHelloServiceClient wraps an established net/rpc client.
+func WrapHelloServiceClient(client *rpc.Client) *HelloServiceClient {
+	return &HelloServiceClient{client: client}
+}
+
+// DialHelloService connects to address with codec.
+func DialHelloService(ctx context.Context, network, address string, codec rpc_stub.Codec) (*HelloServiceClient, error) {
+	client, err := rpc_stub.Dial(ctx, network, address, codec)
+	if err != nil {
+		return nil, err
+	}
+	return WrapHelloServiceClient(client), nil
+}
+
+// Close closes the connection.
+func (c *HelloServiceClient) Close() error {
+	return c.client.Close()
+}
+
+// Hello calls handler/HelloService.Hello.
+func (c *HelloServiceClient) Hello(ctx context.Context, request string) (string, error) {
+	var reply string
+	err := rpc_stub.Call(ctx, c.client, helloServiceName+".Hello", request, &reply)
+	return reply, err
+}
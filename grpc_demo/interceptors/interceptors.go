@@ -0,0 +1,48 @@
+// Package interceptors 可以组合的 gRPC 拦截器: 结构化日志、panic 恢复、请求 ID 传递和耗时统计.
+//
+// 每种拦截器都提供 unary 和 stream 版本, 通过 grpc.ChainUnaryInterceptor /
+// grpc.ChainStreamInterceptor 组合, ServerOptions 和 DialOptions 按推荐的顺序组合好了.
+package interceptors
+
+import (
+	"context"
+	"log/slog"
+
+	"google.golang.org/grpc"
+)
+
+// ServerOptions chains request ID, logging and recovery for both unary and
+// stream calls. More interceptors can be chained after them with additional
+// grpc.ChainUnaryInterceptor options.
+func ServerOptions(logger *slog.Logger) []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(
+			UnaryServerRequestID(),
+			UnaryServerLogging(logger),
+			UnaryServerRecovery(logger),
+		),
+		grpc.ChainStreamInterceptor(
+			StreamServerRequestID(),
+			StreamServerLogging(logger),
+			StreamServerRecovery(logger),
+		),
+	}
+}
+
+// DialOptions chains request ID propagation and logging on the client.
+func DialOptions(logger *slog.Logger) []grpc.DialOption {
+	return []grpc.DialOption{
+		grpc.WithChainUnaryInterceptor(UnaryClientRequestID(), UnaryClientLogging(logger)),
+		grpc.WithChainStreamInterceptor(StreamClientRequestID(), StreamClientLogging(logger)),
+	}
+}
+
+// serverStream 替换 ServerStream 的 context
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,215 @@
+package interceptors
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"grpc_demo/grpc_interpretor/proto"
+)
+
+// greeter name 为 panic 时 panic, 否则返回收到的请求 ID
+type greeter struct {
+	proto.UnimplementedGreeterServer
+}
+
+func (greeter) SayHello(ctx context.Context, request *proto.HelloRequest) (*proto.HelloReply, error) {
+	switch request.Name {
+	case "panic":
+		panic("boom")
+	case "invalid":
+		return nil, status.Error(codes.InvalidArgument, "bad name")
+	}
+	id, _ := RequestIDFromContext(ctx)
+	return &proto.HelloReply{Message: id}, nil
+}
+
+// logBuffer 并发安全的日志缓冲, 按行解析 JSON 日志
+type logBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *logBuffer) records(t *testing.T) []map[string]interface{} {
+	t.Helper()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var records []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(b.buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		record := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func (b *logBuffer) find(t *testing.T, msg string) map[string]interface{} {
+	t.Helper()
+	for _, record := range b.records(t) {
+		if record["msg"] == msg {
+			return record
+		}
+	}
+	t.Fatalf("没有找到日志 %q", msg)
+	return nil
+}
+
+func setup(t *testing.T) (proto.GreeterClient, *logBuffer, *logBuffer) {
+	t.Helper()
+	serverLogs, clientLogs := &logBuffer{}, &logBuffer{}
+	s := grpc.NewServer(ServerOptions(slog.New(slog.NewJSONHandler(serverLogs, nil)))...)
+	proto.RegisterGreeterServer(s, greeter{})
+	l := bufconn.Listen(1 << 16)
+	go s.Serve(l)
+	t.Cleanup(s.Stop)
+
+	opts := append(DialOptions(slog.New(slog.NewJSONHandler(clientLogs, nil))),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return l.DialContext(ctx) }),
+	)
+	conn, err := grpc.Dial("bufnet", opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return proto.NewGreeterClient(conn), serverLogs, clientLogs
+}
+
+func TestRequestID(t *testing.T) {
+	client, serverLogs, clientLogs := setup(t)
+
+	// 调用方指定的请求 ID 传递到服务端, 并通过响应头返回
+	var header metadata.MD
+	ctx := WithRequestID(context.Background(), "req-1")
+	reply, err := client.SayHello(ctx, &proto.HelloRequest{Name: "x"}, grpc.Header(&header))
+	if err != nil || reply.Message != "req-1" {
+		t.Fatalf("SayHello = %v, %v; 期望请求 ID req-1", reply, err)
+	}
+	if got := header.Get(RequestIDKey); len(got) != 1 || got[0] != "req-1" {
+		t.Errorf("响应头 %s = %v; 期望值 req-1", RequestIDKey, got)
+	}
+	if got := serverLogs.find(t, "grpc server call")["request_id"]; got != "req-1" {
+		t.Errorf("服务端日志 request_id = %v; 期望值 req-1", got)
+	}
+	if got := clientLogs.find(t, "grpc client call")["request_id"]; got != "req-1" {
+		t.Errorf("客户端日志 request_id = %v; 期望值 req-1", got)
+	}
+
+	// 没有指定时自动生成
+	reply, err = client.SayHello(context.Background(), &proto.HelloRequest{Name: "x"})
+	if err != nil || len(reply.Message) != 32 {
+		t.Errorf("生成的请求 ID = %q, %v; 期望 32 位十六进制", reply.GetMessage(), err)
+	}
+}
+
+func TestRecoveryAndLogging(t *testing.T) {
+	client, serverLogs, _ := setup(t)
+
+	_, err := client.SayHello(context.Background(), &proto.HelloRequest{Name: "panic"})
+	if status.Code(err) != codes.Internal || strings.Contains(err.Error(), "boom") {
+		t.Errorf("panic 后错误 = %v; 期望不包含 panic 内容的 Internal", err)
+	}
+	_, err = client.SayHello(context.Background(), &proto.HelloRequest{Name: "invalid"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("错误 = %v; 期望 InvalidArgument", err)
+	}
+
+	panicked := serverLogs.find(t, "grpc handler panic")
+	if panicked["panic"] != "boom" || !strings.Contains(panicked["stack"].(string), "SayHello") {
+		t.Errorf("panic 日志 = %v", panicked)
+	}
+	var levels []string
+	for _, record := range serverLogs.records(t) {
+		if record["msg"] == "grpc server call" {
+			if record["method"] != "/Greeter/SayHello" || record["duration"] == nil {
+				t.Errorf("调用日志 = %v", record)
+			}
+			levels = append(levels, record["level"].(string)+" "+record["code"].(string))
+		}
+	}
+	if want := []string{"ERROR Internal", "WARN InvalidArgument"}; strings.Join(levels, ",") != strings.Join(want, ",") {
+		t.Errorf("日志级别 = %v; 期望值 %v", levels, want)
+	}
+}
+
+// fakeStream 只提供 Context 的 ServerStream
+type fakeStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *fakeStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestStreamInterceptors(t *testing.T) {
+	var logs logBuffer
+	logger := slog.New(slog.NewJSONHandler(&logs, nil))
+	var observed []codes.Code
+	chain := []grpc.StreamServerInterceptor{
+		StreamServerRequestID(),
+		StreamServerLatency(func(ctx context.Context, method string, code codes.Code, d time.Duration) {
+			observed = append(observed, code)
+		}),
+		StreamServerLogging(logger),
+		StreamServerRecovery(logger),
+	}
+	run := func(ctx context.Context, handler grpc.StreamHandler) error {
+		info := &grpc.StreamServerInfo{FullMethod: "/Greeter/Chat", IsServerStream: true}
+		h := handler
+		for i := len(chain) - 1; i >= 0; i-- {
+			interceptor, next := chain[i], h
+			h = func(srv interface{}, ss grpc.ServerStream) error {
+				return interceptor(srv, ss, info, next)
+			}
+		}
+		return h(nil, &fakeStream{ctx: ctx})
+	}
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(RequestIDKey, "stream-1"))
+	var seen string
+	if err := run(ctx, func(srv interface{}, ss grpc.ServerStream) error {
+		seen, _ = RequestIDFromContext(ss.Context())
+		return nil
+	}); err != nil || seen != "stream-1" {
+		t.Errorf("stream 请求 ID = %q, %v; 期望值 stream-1", seen, err)
+	}
+
+	err := run(context.Background(), func(srv interface{}, ss grpc.ServerStream) error {
+		panic(errors.New("stream boom"))
+	})
+	if status.Code(err) != codes.Internal {
+		t.Errorf("stream panic 后错误 = %v; 期望 Internal", err)
+	}
+	if len(observed) != 2 || observed[0] != codes.OK || observed[1] != codes.Internal {
+		t.Errorf("耗时统计的状态码 = %v; 期望值 [OK Internal]", observed)
+	}
+	if got := logs.find(t, "grpc server call")["kind"]; got != "stream" {
+		t.Errorf("日志 kind = %v; 期望值 stream", got)
+	}
+}
@@ -0,0 +1,111 @@
+package interceptors
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// LatencyFunc 每次调用结束时被调用, code 为返回给调用方的状态码
+type LatencyFunc func(ctx context.Context, fullMethod string, code codes.Code, d time.Duration)
+
+// UnaryServerLatency reports the duration of every unary call to observe.
+func UnaryServerLatency(observe LatencyFunc) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		observe(ctx, info.FullMethod, status.Code(err), time.Since(start))
+		return resp, err
+	}
+}
+
+// StreamServerLatency reports the duration of every stream to observe.
+func StreamServerLatency(observe LatencyFunc) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, ss)
+		observe(ss.Context(), info.FullMethod, status.Code(err), time.Since(start))
+		return err
+	}
+}
+
+// UnaryClientLatency reports the duration of every unary call to observe.
+func UnaryClientLatency(observe LatencyFunc) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		observe(ctx, method, status.Code(err), time.Since(start))
+		return err
+	}
+}
+
+// UnaryServerLogging logs one line per unary call with the method, status
+// code, duration, peer and request ID.
+func UnaryServerLogging(logger *slog.Logger) grpc.UnaryServerInterceptor {
+	return UnaryServerLatency(serverLogFunc(logger, "unary"))
+}
+
+// StreamServerLogging logs one line per stream when it ends.
+func StreamServerLogging(logger *slog.Logger) grpc.StreamServerInterceptor {
+	return StreamServerLatency(serverLogFunc(logger, "stream"))
+}
+
+// UnaryClientLogging logs one line per unary call made by the client.
+func UnaryClientLogging(logger *slog.Logger) grpc.UnaryClientInterceptor {
+	return UnaryClientLatency(func(ctx context.Context, method string, code codes.Code, d time.Duration) {
+		logCall(ctx, logger, "grpc client call", method, "unary", code, d)
+	})
+}
+
+// StreamClientLogging logs when a client stream is established. The stream
+// itself may last much longer.
+func StreamClientLogging(logger *slog.Logger) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		start := time.Now()
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		logCall(ctx, logger, "grpc client stream", method, "stream", status.Code(err), time.Since(start))
+		return cs, err
+	}
+}
+
+func serverLogFunc(logger *slog.Logger, kind string) LatencyFunc {
+	return func(ctx context.Context, fullMethod string, code codes.Code, d time.Duration) {
+		logCall(ctx, logger, "grpc server call", fullMethod, kind, code, d)
+	}
+}
+
+func logCall(ctx context.Context, logger *slog.Logger, msg, method, kind string, code codes.Code, d time.Duration) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	attrs := []slog.Attr{
+		slog.String("method", method),
+		slog.String("kind", kind),
+		slog.String("code", code.String()),
+		slog.Duration("duration", d),
+	}
+	if id, ok := RequestIDFromContext(ctx); ok {
+		attrs = append(attrs, slog.String("request_id", id))
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		attrs = append(attrs, slog.String("peer", p.Addr.String()))
+	}
+	logger.LogAttrs(ctx, level(code), msg, attrs...)
+}
+
+// level 服务端的错误记为 Error, 调用方的错误 (参数错误、未认证等) 记为 Warn
+func level(code codes.Code) slog.Level {
+	switch code {
+	case codes.OK:
+		return slog.LevelInfo
+	case codes.Unknown, codes.Internal, codes.DataLoss, codes.Unimplemented, codes.Unavailable, codes.DeadlineExceeded:
+		return slog.LevelError
+	default:
+		return slog.LevelWarn
+	}
+}
@@ -0,0 +1,97 @@
+package interceptors
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// RequestIDKey 请求 ID 在 metadata 中的键
+const RequestIDKey = "x-request-id"
+
+// maxRequestIDLen 调用方传入的请求 ID 超过该长度时重新生成
+const maxRequestIDLen = 128
+
+type requestIDKey struct{}
+
+// WithRequestID returns a context whose outgoing calls carry id.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the request ID set by the server interceptor
+// or WithRequestID.
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(requestIDKey{}).(string)
+	return id, ok && id != ""
+}
+
+// UnaryServerRequestID takes the request ID from the incoming metadata, or
+// generates one, stores it in the context and echoes it in the response
+// header. Client calls made with that context propagate the same ID.
+func UnaryServerRequestID() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx = serverRequestID(ctx)
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerRequestID is the stream version of UnaryServerRequestID.
+func StreamServerRequestID() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx := serverRequestID(ss.Context())
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// UnaryClientRequestID adds the request ID of ctx, or a new one, to the
+// outgoing metadata.
+func UnaryClientRequestID() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return invoker(clientRequestID(ctx), method, req, reply, cc, opts...)
+	}
+}
+
+// StreamClientRequestID is the stream version of UnaryClientRequestID.
+func StreamClientRequestID() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return streamer(clientRequestID(ctx), desc, cc, method, opts...)
+	}
+}
+
+func serverRequestID(ctx context.Context) context.Context {
+	md, _ := metadata.FromIncomingContext(ctx)
+	var id string
+	if values := md.Get(RequestIDKey); len(values) > 0 && values[0] != "" && len(values[0]) <= maxRequestIDLen {
+		id = values[0]
+	} else {
+		id = newRequestID()
+	}
+	// 设置响应头失败 (例如流已经发送过头) 不影响调用
+	_ = grpc.SetHeader(ctx, metadata.Pairs(RequestIDKey, id))
+	return WithRequestID(ctx, id)
+}
+
+func clientRequestID(ctx context.Context) context.Context {
+	// 调用方已经在 metadata 中设置了请求 ID
+	if md, ok := metadata.FromOutgoingContext(ctx); ok && len(md.Get(RequestIDKey)) > 0 {
+		return ctx
+	}
+	id, ok := RequestIDFromContext(ctx)
+	if !ok {
+		id = newRequestID()
+		ctx = WithRequestID(ctx, id)
+	}
+	return metadata.AppendToOutgoingContext(ctx, RequestIDKey, id)
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,269 @@
+// Package jsonrpc_http 通过 HTTP 以 JSON-RPC 1.0/2.0 协议调用 net/rpc 注册的服务, 支持批量请求.
+//
+//	POST /jsonrpc
+//	{"jsonrpc": "2.0", "method": "handler/HelloService.Hello", "params": ["bobby"], "id": 1}
+//
+// net/rpc 的方法只有一个参数: params 为数组时取第一个元素, 为对象时 (2.0 的命名参数) 整体作为参数.
+package jsonrpc_http
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/rpc"
+	"strings"
+)
+
+// DefaultPath Python 客户端使用的路径
+const DefaultPath = "/jsonrpc"
+
+const maxBodySize = 1 << 20
+
+// JSON-RPC 2.0 定义的错误码
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+	CodeServerError    = -32000 // 服务方法返回的错误
+)
+
+// Error JSON-RPC 错误对象
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+type request struct {
+	Version string           `json:"jsonrpc"`
+	Method  string           `json:"method"`
+	Params  json.RawMessage  `json:"params"`
+	ID      *json.RawMessage `json:"id"` // 没有 id 时为 nil, "id": null 时指向 null
+}
+
+// isNotification 2.0 中没有 id 的请求, 1.0 中 id 为 null 的请求不需要响应
+func (r *request) isNotification() bool {
+	if r.Version == "2.0" {
+		return r.ID == nil
+	}
+	return r.ID == nil || string(*r.ID) == "null"
+}
+
+type response struct {
+	Version string           `json:"jsonrpc,omitempty"`
+	Result  interface{}      `json:"result"`
+	Error   *Error           `json:"error"`
+	ID      *json.RawMessage `json:"id"`
+}
+
+// MarshalJSON 2.0 的响应中 result 和 error 只能出现一个, 1.0 中两者都必须出现
+func (r response) MarshalJSON() ([]byte, error) {
+	if r.Version != "2.0" {
+		type v1 response
+		return json.Marshal(v1(r))
+	}
+	if r.Error != nil {
+		return json.Marshal(struct {
+			Version string           `json:"jsonrpc"`
+			Error   *Error           `json:"error"`
+			ID      *json.RawMessage `json:"id"`
+		}{r.Version, r.Error, r.ID})
+	}
+	return json.Marshal(struct {
+		Version string           `json:"jsonrpc"`
+		Result  interface{}      `json:"result"`
+		ID      *json.RawMessage `json:"id"`
+	}{r.Version, r.Result, r.ID})
+}
+
+// Handler 把 HTTP 请求转发给 rpc.Server
+type Handler struct {
+	server *rpc.Server
+}
+
+// NewHandler creates a JSON-RPC handler for the services registered on
+// server, use rpc.DefaultServer for services registered with rpc.Register.
+func NewHandler(server *rpc.Server) *Handler {
+	return &Handler{server: server}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if err != nil {
+		writeJSON(w, errorResponse("2.0", nil, CodeParseError, err.Error()))
+		return
+	}
+
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(body, &batch); err != nil {
+			writeJSON(w, errorResponse("2.0", nil, CodeParseError, err.Error()))
+			return
+		}
+		if len(batch) == 0 {
+			writeJSON(w, errorResponse("2.0", nil, CodeInvalidRequest, "empty batch"))
+			return
+		}
+		responses := make([]response, 0, len(batch))
+		for _, raw := range batch {
+			if resp, ok := h.handle(raw); ok {
+				responses = append(responses, resp)
+			}
+		}
+		if len(responses) == 0 {
+			// 全部是通知
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		writeJSON(w, responses)
+		return
+	}
+
+	resp, ok := h.handle(body)
+	if !ok {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	writeJSON(w, resp)
+}
+
+// handle 处理单个请求, 通知返回 false
+func (h *Handler) handle(raw json.RawMessage) (response, bool) {
+	var req request
+	if err := json.Unmarshal(raw, &req); err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			return errorResponse("2.0", nil, CodeParseError, err.Error()), true
+		}
+		return errorResponse("2.0", nil, CodeInvalidRequest, err.Error()), true
+	}
+	if req.Version != "" && req.Version != "2.0" {
+		return errorResponse("2.0", req.ID, CodeInvalidRequest, "unsupported jsonrpc version "+req.Version), true
+	}
+	if req.Method == "" {
+		return errorResponse(req.Version, req.ID, CodeInvalidRequest, "method is required"), true
+	}
+
+	params, err := singleParam(req.Params)
+	var result interface{}
+	if err == nil {
+		result, err = h.call(req.Method, params)
+	}
+	if req.isNotification() {
+		return response{}, false
+	}
+	if err != nil {
+		var rpcErr *Error
+		if !errors.As(err, &rpcErr) {
+			rpcErr = &Error{Code: CodeInternalError, Message: err.Error()}
+		}
+		return response{Version: req.Version, Error: rpcErr, ID: req.ID}, true
+	}
+	return response{Version: req.Version, Result: result, ID: req.ID}, true
+}
+
+// singleParam 把 params 转换为 net/rpc 的单个参数
+func singleParam(params json.RawMessage) (json.RawMessage, error) {
+	params = bytes.TrimSpace(params)
+	if len(params) == 0 || params[0] != '[' {
+		// 没有参数, null 或命名参数
+		return params, nil
+	}
+	var list []json.RawMessage
+	if err := json.Unmarshal(params, &list); err != nil {
+		return nil, &Error{Code: CodeInvalidParams, Message: err.Error()}
+	}
+	switch len(list) {
+	case 0:
+		return nil, nil
+	case 1:
+		return list[0], nil
+	default:
+		return nil, &Error{Code: CodeInvalidParams, Message: "net/rpc methods take exactly one parameter"}
+	}
+}
+
+// call 通过单次请求的编解码器调用 rpc.Server
+func (h *Handler) call(method string, params json.RawMessage) (interface{}, error) {
+	codec := &requestCodec{method: method, params: params}
+	h.server.ServeRequest(codec)
+	switch {
+	case codec.badParams != nil:
+		return nil, &Error{Code: CodeInvalidParams, Message: codec.badParams.Error()}
+	case strings.HasPrefix(codec.err, "rpc: can't find"):
+		return nil, &Error{Code: CodeMethodNotFound, Message: codec.err}
+	case codec.err != "":
+		return nil, &Error{Code: CodeServerError, Message: codec.err}
+	case !codec.written:
+		return nil, &Error{Code: CodeInternalError, Message: "no response from service"}
+	}
+	return codec.result, nil
+}
+
+// requestCodec 只包含一个请求的 rpc.ServerCodec
+type requestCodec struct {
+	method    string
+	params    json.RawMessage
+	badParams error
+
+	written bool
+	result  interface{}
+	err     string
+}
+
+func (c *requestCodec) ReadRequestHeader(r *rpc.Request) error {
+	r.ServiceMethod = c.method
+	r.Seq = 0
+	return nil
+}
+
+func (c *requestCodec) ReadRequestBody(body interface{}) error {
+	if body == nil || len(c.params) == 0 || string(c.params) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(c.params, body); err != nil {
+		c.badParams = err
+		return err
+	}
+	return nil
+}
+
+func (c *requestCodec) WriteResponse(r *rpc.Response, body interface{}) error {
+	c.written = true
+	c.err = r.Error
+	if r.Error == "" {
+		c.result = body
+	}
+	return nil
+}
+
+func (c *requestCodec) Close() error {
+	return nil
+}
+
+func errorResponse(version string, id *json.RawMessage, code int, message string) response {
+	if id == nil && version == "2.0" {
+		// 无法确定 id 时 2.0 要求返回 null
+		null := json.RawMessage("null")
+		id = &null
+	}
+	return response{Version: version, Error: &Error{Code: code, Message: message}, ID: id}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
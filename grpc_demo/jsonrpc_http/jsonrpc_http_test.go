@@ -0,0 +1,103 @@
+package jsonrpc_http
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/rpc"
+	"strings"
+	"testing"
+
+	"grpc_demo/handler"
+)
+
+type Args struct {
+	A, B int
+}
+
+type Arith struct{}
+
+func (Arith) Add(args Args, reply *int) error {
+	*reply = args.A + args.B
+	return nil
+}
+
+func (Arith) Div(args Args, reply *int) error {
+	if args.B == 0 {
+		return errors.New("divide by zero")
+	}
+	*reply = args.A / args.B
+	return nil
+}
+
+func newTestHandler(t *testing.T) *Handler {
+	t.Helper()
+	server := rpc.NewServer()
+	if err := server.RegisterName(handler.HelloServiceName, &handler.NewHelloService{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.Register(Arith{}); err != nil {
+		t.Fatal(err)
+	}
+	return NewHandler(server)
+}
+
+func TestServeHTTP(t *testing.T) {
+	h := newTestHandler(t)
+	cases := []struct {
+		name   string
+		body   string
+		status int
+		want   string
+	}{
+		{"1.0 请求", `{"id":0,"params":["boby"],"method":"handler/HelloService.Hello"}`, http.StatusOK,
+			`{"result":"helloboby","error":null,"id":0}`},
+		{"2.0 请求", `{"jsonrpc":"2.0","id":"a","params":["boby"],"method":"handler/HelloService.Hello"}`, http.StatusOK,
+			`{"jsonrpc":"2.0","result":"helloboby","id":"a"}`},
+		{"2.0 命名参数", `{"jsonrpc":"2.0","id":1,"params":{"A":1,"B":2},"method":"Arith.Add"}`, http.StatusOK,
+			`{"jsonrpc":"2.0","result":3,"id":1}`},
+		{"方法不存在", `{"jsonrpc":"2.0","id":1,"params":[1],"method":"Arith.Mul"}`, http.StatusOK,
+			`{"jsonrpc":"2.0","error":{"code":-32601,"message":"rpc: can't find method Arith.Mul"},"id":1}`},
+		{"参数类型错误", `{"jsonrpc":"2.0","id":1,"params":["x"],"method":"Arith.Add"}`, http.StatusOK,
+			`"code":-32602`},
+		{"参数个数错误", `{"jsonrpc":"2.0","id":1,"params":[1,2],"method":"Arith.Add"}`, http.StatusOK,
+			`"code":-32602`},
+		{"服务返回错误", `{"jsonrpc":"2.0","id":1,"params":[{"A":1,"B":0}],"method":"Arith.Div"}`, http.StatusOK,
+			`{"jsonrpc":"2.0","error":{"code":-32000,"message":"divide by zero"},"id":1}`},
+		{"1.0 服务返回错误", `{"id":1,"params":[{"A":1,"B":0}],"method":"Arith.Div"}`, http.StatusOK,
+			`{"result":null,"error":{"code":-32000,"message":"divide by zero"},"id":1}`},
+		{"JSON 格式错误", `{"jsonrpc":"2.0",`, http.StatusOK,
+			`{"jsonrpc":"2.0","error":{"code":-32700,`},
+		{"缺少方法", `{"jsonrpc":"2.0","id":1}`, http.StatusOK,
+			`{"jsonrpc":"2.0","error":{"code":-32600,"message":"method is required"},"id":1}`},
+		{"通知没有响应", `{"jsonrpc":"2.0","params":["boby"],"method":"handler/HelloService.Hello"}`, http.StatusNoContent, ``},
+		{"批量请求", `[{"jsonrpc":"2.0","id":1,"params":[{"A":1,"B":2}],"method":"Arith.Add"},` +
+			`{"jsonrpc":"2.0","params":["x"],"method":"handler/HelloService.Hello"},` +
+			`{"jsonrpc":"2.0","id":2,"params":[{"A":6,"B":3}],"method":"Arith.Div"},1]`, http.StatusOK,
+			`[{"jsonrpc":"2.0","result":3,"id":1},{"jsonrpc":"2.0","result":2,"id":2},` +
+				`{"jsonrpc":"2.0","error":{"code":-32600,"message":"json: cannot unmarshal number into Go value of type jsonrpc_http.request"},"id":null}]`},
+		{"空的批量请求", `[]`, http.StatusOK,
+			`{"jsonrpc":"2.0","error":{"code":-32600,"message":"empty batch"},"id":null}`},
+		{"批量通知", `[{"jsonrpc":"2.0","params":["x"],"method":"handler/HelloService.Hello"}]`, http.StatusNoContent, ``},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, DefaultPath, strings.NewReader(tc.body)))
+			if w.Code != tc.status {
+				t.Errorf("状态码 = %d; 期望值 %d", w.Code, tc.status)
+			}
+			if got := strings.TrimSpace(w.Body.String()); !strings.Contains(got, tc.want) || (tc.want == "" && got != "") {
+				t.Errorf("响应 = %s; 期望包含 %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	w := httptest.NewRecorder()
+	newTestHandler(t).ServeHTTP(w, httptest.NewRequest(http.MethodGet, DefaultPath, nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("状态码 = %d; 期望值 %d", w.Code, http.StatusMethodNotAllowed)
+	}
+}
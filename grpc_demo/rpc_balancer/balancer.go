@@ -0,0 +1,409 @@
+// Package rpc_balancer 客户端负载均衡: 通过 Resolver 发现后端, 按策略选择后端调用,
+// 定期健康检查, 连接失败时自动切换到其它后端.
+package rpc_balancer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/rpc"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"grpc_demo/rpc_stub"
+)
+
+// ErrNoBackends is returned when the resolver returned no addresses or every
+// backend failed.
+var ErrNoBackends = errors.New("rpc_balancer: no available backends")
+
+const (
+	defaultDialTimeout     = 5 * time.Second
+	defaultRefreshInterval = 30 * time.Second
+	defaultHealthInterval  = 5 * time.Second
+)
+
+// Config 负载均衡客户端的配置
+type Config struct {
+	Resolver        Resolver
+	Policy          Policy
+	Codec           rpc_stub.Codec
+	DialTimeout     time.Duration // 默认 5s
+	RefreshInterval time.Duration // 重新解析地址的间隔, 默认 30s
+	HealthInterval  time.Duration // 健康检查的间隔, 默认 5s
+	// HealthCheck 检查一个后端, 为 nil 时只对不健康的后端检查能否重新连接
+	HealthCheck func(ctx context.Context, client *rpc.Client) error
+}
+
+// Backend 后端的状态
+type Backend struct {
+	Addr        string
+	Healthy     bool
+	Outstanding int64 // 进行中的调用数
+}
+
+// dial 建立到后端的连接, 测试中替换
+var dial = rpc_stub.Dial
+
+// backend 一个后端地址, 第一次调用时建立连接
+type backend struct {
+	addr        string
+	outstanding atomic.Int64
+	healthy     atomic.Bool
+
+	mu      sync.Mutex
+	client  *rpc.Client
+	dialing *dialing // 进行中的连接, 其它调用等待它完成而不是持有 mu
+	closed  bool
+}
+
+// dialing 一次进行中的连接及其结果
+type dialing struct {
+	done     chan struct{}
+	client   *rpc.Client
+	err      error
+	canceled bool // 发起连接的调用已经取消, 结果不代表后端的状态
+}
+
+// Client 在多个后端之间负载均衡的 net/rpc 客户端
+type Client struct {
+	cfg  Config
+	next atomic.Uint64 // 轮询计数
+
+	mu       sync.RWMutex
+	backends []*backend
+	ring     ring
+
+	done      chan struct{}
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+}
+
+// New resolves the backends and starts the refresh and health check loop.
+// Connections are established lazily.
+func New(ctx context.Context, cfg Config) (*Client, error) {
+	if cfg.Resolver == nil {
+		return nil, errors.New("rpc_balancer: Resolver is required")
+	}
+	if cfg.DialTimeout <= 0 {
+		cfg.DialTimeout = defaultDialTimeout
+	}
+	if cfg.RefreshInterval <= 0 {
+		cfg.RefreshInterval = defaultRefreshInterval
+	}
+	if cfg.HealthInterval <= 0 {
+		cfg.HealthInterval = defaultHealthInterval
+	}
+	c := &Client{cfg: cfg, done: make(chan struct{})}
+	if err := c.refresh(ctx); err != nil {
+		return nil, err
+	}
+	c.wg.Add(1)
+	go c.loop()
+	return c, nil
+}
+
+// Call invokes method on one backend. If the connection fails the call is
+// retried on the other backends, so methods called through a Client should be
+// idempotent. Errors returned by the service are not retried.
+func (c *Client) Call(ctx context.Context, method string, args, reply interface{}) error {
+	tried := map[*backend]bool{}
+	lastErr := ErrNoBackends
+	for {
+		b := c.pick(ctx, tried)
+		if b == nil {
+			return lastErr
+		}
+		tried[b] = true
+		client, err := c.call(ctx, b, method, args, reply)
+		if err == nil || !isConnError(err) {
+			b.markUp()
+			return err
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+		b.markDown(client)
+		lastErr = err
+	}
+}
+
+// Backends returns the state of the current backends.
+func (c *Client) Backends() []Backend {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	states := make([]Backend, len(c.backends))
+	for i, b := range c.backends {
+		states[i] = Backend{Addr: b.addr, Healthy: b.isHealthy(), Outstanding: b.outstanding.Load()}
+	}
+	return states
+}
+
+// Close stops the background loop and closes every connection.
+func (c *Client) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.wg.Wait()
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		for _, b := range c.backends {
+			b.close()
+		}
+		c.backends, c.ring = nil, nil
+	})
+	return nil
+}
+
+func (c *Client) loop() {
+	defer c.wg.Done()
+	refresh := time.NewTicker(c.cfg.RefreshInterval)
+	defer refresh.Stop()
+	health := time.NewTicker(c.cfg.HealthInterval)
+	defer health.Stop()
+	for {
+		select {
+		case <-refresh.C:
+			ctx, cancel := context.WithTimeout(context.Background(), c.cfg.DialTimeout)
+			// 解析失败时继续使用之前的地址
+			_ = c.refresh(ctx)
+			cancel()
+		case <-health.C:
+			c.checkHealth()
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// refresh 重新解析地址, 保留仍然存在的后端及其连接
+func (c *Client) refresh(ctx context.Context) error {
+	addrs, err := c.cfg.Resolver.Resolve(ctx)
+	if err != nil {
+		return err
+	}
+	if len(addrs) == 0 {
+		return ErrNoBackends
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	old := make(map[string]*backend, len(c.backends))
+	for _, b := range c.backends {
+		old[b.addr] = b
+	}
+	backends := make([]*backend, 0, len(addrs))
+	seen := map[string]bool{}
+	for _, addr := range addrs {
+		if seen[addr] {
+			continue
+		}
+		seen[addr] = true
+		if b, ok := old[addr]; ok {
+			backends = append(backends, b)
+			delete(old, addr)
+		} else {
+			b := &backend{addr: addr}
+			b.healthy.Store(true)
+			backends = append(backends, b)
+		}
+	}
+	for _, b := range old {
+		b.close()
+	}
+	c.backends = backends
+	c.ring = newRing(addrsOf(backends))
+	return nil
+}
+
+// pick 选择一个没有试过的后端, 优先选择健康的后端
+func (c *Client) pick(ctx context.Context, tried map[*backend]bool) *backend {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var healthy, others []*backend
+	for _, b := range c.backends {
+		if tried[b] {
+			continue
+		}
+		if b.isHealthy() {
+			healthy = append(healthy, b)
+		} else {
+			others = append(others, b)
+		}
+	}
+	candidates := healthy
+	if len(candidates) == 0 {
+		// 全部不健康时仍然尝试, 后端可能已经恢复
+		candidates = others
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	switch c.cfg.Policy {
+	case ConsistentHash:
+		if key, ok := hashKeyFrom(ctx); ok {
+			byAddr := make(map[string]*backend, len(candidates))
+			for _, b := range candidates {
+				byAddr[b.addr] = b
+			}
+			if addr, ok := c.ring.lookup(key, func(addr string) bool { return byAddr[addr] != nil }); ok {
+				return byAddr[addr]
+			}
+		}
+	case LeastOutstanding:
+		// 从轮询位置开始找, 调用数相同时分散到不同后端
+		offset := int(c.next.Add(1) % uint64(len(candidates)))
+		best := candidates[offset]
+		for i := 1; i < len(candidates); i++ {
+			b := candidates[(offset+i)%len(candidates)]
+			if b.outstanding.Load() < best.outstanding.Load() {
+				best = b
+			}
+		}
+		return best
+	}
+	return candidates[c.next.Add(1)%uint64(len(candidates))]
+}
+
+// call 返回使用的连接, 连接失败时由调用方关闭这个连接
+func (c *Client) call(ctx context.Context, b *backend, method string, args, reply interface{}) (*rpc.Client, error) {
+	b.outstanding.Add(1)
+	defer b.outstanding.Add(-1)
+	client, err := b.conn(ctx, c.cfg)
+	if err != nil {
+		return nil, err
+	}
+	return client, rpc_stub.Call(ctx, client, method, args, reply)
+}
+
+// checkHealth 并发检查所有后端
+func (c *Client) checkHealth() {
+	c.mu.RLock()
+	backends := append([]*backend(nil), c.backends...)
+	c.mu.RUnlock()
+
+	var wg sync.WaitGroup
+	for _, b := range backends {
+		if c.cfg.HealthCheck == nil && b.isHealthy() {
+			continue
+		}
+		wg.Add(1)
+		go func(b *backend) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), c.cfg.DialTimeout)
+			defer cancel()
+			client, err := b.conn(ctx, c.cfg)
+			if err == nil && c.cfg.HealthCheck != nil {
+				err = c.cfg.HealthCheck(ctx, client)
+			}
+			if err != nil {
+				b.markDown(client)
+				return
+			}
+			b.markUp()
+		}(b)
+	}
+	wg.Wait()
+}
+
+// conn 返回已有的连接或者建立连接. 连接在 mu 之外建立, 同时到达的调用等待同一次连接,
+// 发起连接的调用被取消时重新连接
+func (b *backend) conn(ctx context.Context, cfg Config) (*rpc.Client, error) {
+	b.mu.Lock()
+	for b.client == nil && b.dialing != nil {
+		d := b.dialing
+		b.mu.Unlock()
+		select {
+		case <-d.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if !d.canceled {
+			return d.client, d.err
+		}
+		b.mu.Lock()
+	}
+	if b.client != nil {
+		client := b.client
+		b.mu.Unlock()
+		return client, nil
+	}
+	if b.closed {
+		b.mu.Unlock()
+		return nil, rpc.ErrShutdown
+	}
+	d := &dialing{done: make(chan struct{})}
+	b.dialing = d
+	b.mu.Unlock()
+
+	dialCtx, cancel := context.WithTimeout(ctx, cfg.DialTimeout)
+	d.client, d.err = dial(dialCtx, "tcp", b.addr, cfg.Codec)
+	cancel()
+	d.canceled = d.err != nil && ctx.Err() != nil
+
+	b.mu.Lock()
+	b.dialing = nil
+	if d.err == nil {
+		if b.closed {
+			// 连接期间后端已被移除
+			d.client.Close()
+			d.client, d.err = nil, rpc.ErrShutdown
+		} else {
+			b.client = d.client
+		}
+	}
+	b.mu.Unlock()
+	close(d.done)
+	return d.client, d.err
+}
+
+func (b *backend) isHealthy() bool {
+	return b.healthy.Load()
+}
+
+func (b *backend) markUp() {
+	b.healthy.Store(true)
+}
+
+// markDown 标记为不健康并关闭失败的连接, 由健康检查或下一次调用重新连接.
+// 失败的连接已经被替换时保留新的连接
+func (b *backend) markDown(failed *rpc.Client) {
+	b.healthy.Store(false)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if failed != nil && b.client == failed {
+		b.closeLocked()
+	}
+}
+
+// close 关闭连接, 之后不再建立新连接
+func (b *backend) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	b.closeLocked()
+}
+
+func (b *backend) closeLocked() {
+	if b.client != nil {
+		b.client.Close()
+		b.client = nil
+	}
+}
+
+// isConnError 连接失败或断开的错误, 可以换一个后端重试
+func isConnError(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, rpc.ErrShutdown) || errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) || errors.As(err, &netErr)
+}
+
+func addrsOf(backends []*backend) []string {
+	addrs := make([]string, len(backends))
+	for i, b := range backends {
+		addrs[i] = b.addr
+	}
+	return addrs
+}
@@ -0,0 +1,435 @@
+package rpc_balancer
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/rpc"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"grpc_demo/rpc_stub"
+)
+
+// Who 测试服务, 返回自己的地址
+type Who struct {
+	addr    string
+	release chan struct{}
+}
+
+func (w *Who) Addr(request string, reply *string) error {
+	*reply = w.addr
+	return nil
+}
+
+func (w *Who) Block(request string, reply *string) error {
+	<-w.release
+	*reply = w.addr
+	return nil
+}
+
+func (w *Who) Fail(request string, reply *string) error {
+	return errors.New("boom")
+}
+
+type testBackend struct {
+	addr     string
+	listener net.Listener
+	svc      *Who
+}
+
+func (b *testBackend) stop() {
+	b.listener.Close()
+}
+
+func startBackend(t *testing.T) *testBackend {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return serveBackend(t, l)
+}
+
+func serveBackend(t *testing.T, l net.Listener) *testBackend {
+	t.Helper()
+	b := &testBackend{addr: l.Addr().String(), listener: l, svc: &Who{addr: l.Addr().String(), release: make(chan struct{})}}
+	server := rpc.NewServer()
+	if err := server.Register(b.svc); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		var conns []net.Conn
+		defer func() {
+			// 关闭后端时断开已有连接
+			for _, c := range conns {
+				c.Close()
+			}
+		}()
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conns = append(conns, conn)
+			go rpc_stub.ServeConn(server, conn, rpc_stub.Gob)
+		}
+	}()
+	t.Cleanup(b.stop)
+	return b
+}
+
+func newClient(t *testing.T, cfg Config) *Client {
+	t.Helper()
+	c, err := New(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func who(t *testing.T, c *Client, ctx context.Context) string {
+	t.Helper()
+	var reply string
+	if err := c.Call(ctx, "Who.Addr", "", &reply); err != nil {
+		t.Fatal(err)
+	}
+	return reply
+}
+
+func TestRoundRobin(t *testing.T) {
+	b1, b2 := startBackend(t), startBackend(t)
+	c := newClient(t, Config{Resolver: Static(b1.addr, b2.addr)})
+	counts := map[string]int{}
+	for i := 0; i < 10; i++ {
+		counts[who(t, c, context.Background())]++
+	}
+	if counts[b1.addr] != 5 || counts[b2.addr] != 5 {
+		t.Errorf("调用分布 = %v; 期望每个后端 5 次", counts)
+	}
+
+	// 服务返回的错误不切换后端
+	var reply string
+	if err := c.Call(context.Background(), "Who.Fail", "", &reply); err == nil || err.Error() != "boom" {
+		t.Errorf("Fail = %v; 期望值 boom", err)
+	}
+}
+
+func TestLeastOutstanding(t *testing.T) {
+	b1, b2 := startBackend(t), startBackend(t)
+	c := newClient(t, Config{Resolver: Static(b1.addr, b2.addr), Policy: LeastOutstanding})
+
+	// 一个调用阻塞在某个后端, 之后的调用都应该选择另一个后端
+	blocked := make(chan string, 1)
+	go func() {
+		var reply string
+		c.Call(context.Background(), "Who.Block", "", &reply)
+		blocked <- reply
+	}()
+	deadline := time.Now().Add(2 * time.Second)
+	busy := ""
+	for busy == "" && time.Now().Before(deadline) {
+		for _, b := range c.Backends() {
+			if b.Outstanding == 1 {
+				busy = b.Addr
+			}
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if busy == "" {
+		t.Fatal("阻塞的调用没有开始")
+	}
+	for i := 0; i < 5; i++ {
+		if got := who(t, c, context.Background()); got == busy {
+			t.Errorf("调用选择了忙碌的后端 %s", got)
+		}
+	}
+	close(b1.svc.release)
+	close(b2.svc.release)
+	if got := <-blocked; got != busy {
+		t.Errorf("阻塞的调用返回 %s; 期望值 %s", got, busy)
+	}
+}
+
+func TestConsistentHash(t *testing.T) {
+	b1, b2, b3 := startBackend(t), startBackend(t), startBackend(t)
+	c := newClient(t, Config{Resolver: Static(b1.addr, b2.addr, b3.addr), Policy: ConsistentHash})
+
+	keys := []string{"alice", "bob", "carol", "dave", "erin", "frank"}
+	first := map[string]string{}
+	for _, key := range keys {
+		ctx := WithHashKey(context.Background(), key)
+		first[key] = who(t, c, ctx)
+		if again := who(t, c, ctx); again != first[key] {
+			t.Errorf("键 %s 两次调用到不同的后端 %s %s", key, first[key], again)
+		}
+	}
+
+	// 后端下线后, 其它后端上的键不受影响
+	down := first["alice"]
+	for _, b := range []*testBackend{b1, b2, b3} {
+		if b.addr == down {
+			b.stop()
+		}
+	}
+	for _, key := range keys {
+		got := who(t, c, WithHashKey(context.Background(), key))
+		if got == down {
+			t.Errorf("键 %s 调用到已下线的后端", key)
+		}
+		if first[key] != down && got != first[key] {
+			t.Errorf("键 %s 从 %s 迁移到 %s", key, first[key], got)
+		}
+	}
+}
+
+func TestFailoverAndHealthCheck(t *testing.T) {
+	b1, b2 := startBackend(t), startBackend(t)
+	c := newClient(t, Config{Resolver: Static(b1.addr, b2.addr), HealthInterval: 50 * time.Millisecond})
+	who(t, c, context.Background())
+	who(t, c, context.Background())
+
+	// 下线后调用透明地切换到另一个后端
+	b1.stop()
+	for i := 0; i < 4; i++ {
+		if got := who(t, c, context.Background()); got != b2.addr {
+			t.Errorf("调用到 %s; 期望值 %s", got, b2.addr)
+		}
+	}
+	if states := c.Backends(); states[0].Healthy || !states[1].Healthy {
+		t.Errorf("后端状态 = %+v", states)
+	}
+
+	// 在同一地址重新启动后, 健康检查恢复该后端
+	l, err := net.Listen("tcp", b1.addr)
+	if err != nil {
+		t.Skipf("无法重新监听 %s: %v", b1.addr, err)
+	}
+	b1 = serveBackend(t, l)
+	deadline := time.Now().Add(2 * time.Second)
+	for !c.Backends()[0].Healthy {
+		if time.Now().After(deadline) {
+			t.Fatal("健康检查没有恢复后端")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	seen := map[string]bool{}
+	for i := 0; i < 4; i++ {
+		seen[who(t, c, context.Background())] = true
+	}
+	if !seen[b1.addr] || !seen[b2.addr] {
+		t.Errorf("恢复后调用到 %v; 期望两个后端都被调用", seen)
+	}
+
+	// 全部下线时返回最后一个连接错误
+	b1.stop()
+	b2.stop()
+	time.Sleep(100 * time.Millisecond)
+	var reply string
+	if err := c.Call(context.Background(), "Who.Addr", "", &reply); err == nil || errors.Is(err, ErrNoBackends) {
+		t.Errorf("全部下线时 Call = %v; 期望连接错误", err)
+	}
+}
+
+func TestResolvers(t *testing.T) {
+	srv := &SRVResolver{Service: "hello", Proto: "tcp", Name: "example.com",
+		Lookup: func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+			if service != "hello" || proto != "tcp" || name != "example.com" {
+				return "", nil, errors.New("unexpected query")
+			}
+			return "_hello._tcp.example.com.", []*net.SRV{
+				{Target: "a.example.com.", Port: 1234},
+				{Target: "b.example.com.", Port: 1235},
+			}, nil
+		}}
+	got, err := srv.Resolve(context.Background())
+	if want := []string{"a.example.com:1234", "b.example.com:1235"}; err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("SRV = %v, %v; 期望值 %v", got, err, want)
+	}
+
+	path := filepath.Join(t.TempDir(), "backends.txt")
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("# hello 服务\n127.0.0.1:1234\n\n127.0.0.1:1235\n")
+	fr := NewFileResolver(path)
+	got, err = fr.Resolve(context.Background())
+	if want := []string{"127.0.0.1:1234", "127.0.0.1:1235"}; err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("文件 = %v, %v; 期望值 %v", got, err, want)
+	}
+	write("127.0.0.1:1236\n")
+	got, err = fr.Resolve(context.Background())
+	if want := []string{"127.0.0.1:1236"}; err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("修改后的文件 = %v, %v; 期望值 %v", got, err, want)
+	}
+	write("localhost\n")
+	if _, err := fr.Resolve(context.Background()); err == nil || !strings.Contains(err.Error(), ":1:") {
+		t.Errorf("错误的地址 = %v; 期望带行号的错误", err)
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	b1, b2 := startBackend(t), startBackend(t)
+	path := filepath.Join(t.TempDir(), "backends.txt")
+	if err := os.WriteFile(path, []byte(b1.addr+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c := newClient(t, Config{Resolver: NewFileResolver(path), RefreshInterval: 20 * time.Millisecond})
+	if got := who(t, c, context.Background()); got != b1.addr {
+		t.Errorf("调用到 %s; 期望值 %s", got, b1.addr)
+	}
+
+	if err := os.WriteFile(path, []byte(b2.addr+"\n# b1 已下线\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		states := c.Backends()
+		if len(states) == 1 && states[0].Addr == b2.addr {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("地址没有更新: %+v", states)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := who(t, c, context.Background()); got != b2.addr {
+		t.Errorf("调用到 %s; 期望值 %s", got, b2.addr)
+	}
+}
+
+func TestHangingDial(t *testing.T) {
+	b := startBackend(t)
+	// 不响应的后端: 连接一直等到超时
+	const hanging = "hanging:1"
+	started := make(chan struct{})
+	var once sync.Once
+	dial = func(ctx context.Context, network, address string, codec rpc_stub.Codec) (*rpc.Client, error) {
+		if address != hanging {
+			return rpc_stub.Dial(ctx, network, address, codec)
+		}
+		once.Do(func() { close(started) })
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	t.Cleanup(func() { dial = rpc_stub.Dial })
+
+	c := newClient(t, Config{Resolver: Static(b.addr, hanging), Policy: LeastOutstanding, DialTimeout: time.Second})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		var reply string
+		// 直到有一个调用选中不响应的后端, 它在连接超时后切换到 b
+		for {
+			select {
+			case <-started:
+				return
+			default:
+			}
+			c.Call(context.Background(), "Who.Addr", "", &reply)
+		}
+	}()
+	select {
+	case <-started:
+	case <-time.After(2 * time.Second):
+		t.Fatal("没有连接不响应的后端")
+	}
+
+	// 连接进行中时其它调用不被阻塞
+	for i := 0; i < 5; i++ {
+		start := time.Now()
+		if got := who(t, c, context.Background()); got != b.addr {
+			t.Errorf("调用到 %s; 期望值 %s", got, b.addr)
+		}
+		if elapsed := time.Since(start); elapsed > 200*time.Millisecond {
+			t.Errorf("连接进行中的调用耗时 %v", elapsed)
+		}
+	}
+	<-done
+	for _, s := range c.Backends() {
+		if s.Addr == hanging && s.Healthy {
+			t.Errorf("连接超时后 %s 仍然健康", hanging)
+		}
+	}
+}
+
+func TestCanceledDial(t *testing.T) {
+	tb := startBackend(t)
+	// 第一次连接一直等到调用被取消
+	started := make(chan struct{})
+	var once sync.Once
+	dial = func(ctx context.Context, network, address string, codec rpc_stub.Codec) (*rpc.Client, error) {
+		first := false
+		once.Do(func() { first = true })
+		if !first {
+			return rpc_stub.Dial(ctx, network, address, codec)
+		}
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	t.Cleanup(func() { dial = rpc_stub.Dial })
+
+	b := &backend{addr: tb.addr}
+	cfg := Config{Codec: rpc_stub.Gob, DialTimeout: time.Second}
+	ctx, cancel := context.WithCancel(context.Background())
+	canceled := make(chan error, 1)
+	go func() {
+		_, err := b.conn(ctx, cfg)
+		canceled <- err
+	}()
+	<-started
+	waiter := make(chan error, 1)
+	go func() {
+		_, err := b.conn(context.Background(), cfg)
+		waiter <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+
+	if err := <-canceled; !errors.Is(err, context.Canceled) {
+		t.Errorf("取消的调用 = %v; 期望 context.Canceled", err)
+	}
+	// 等待的调用没有取消, 应该重新连接而不是返回别人的取消错误
+	select {
+	case err := <-waiter:
+		if err != nil {
+			t.Errorf("等待的调用 = %v; 期望重新连接成功", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("等待的调用没有返回")
+	}
+	b.close()
+}
+
+func TestMarkDownStaleClient(t *testing.T) {
+	tb := startBackend(t)
+	b := &backend{addr: tb.addr}
+	cfg := Config{Codec: rpc_stub.Gob, DialTimeout: time.Second}
+	stale, err := b.conn(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.markDown(stale)
+	current, err := b.conn(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.close()
+
+	// 旧连接的失败不应该关闭已经重新建立的连接
+	b.markDown(stale)
+	var reply string
+	if err := current.Call("Who.Addr", "", &reply); err != nil {
+		t.Errorf("新连接被关闭: %v", err)
+	}
+}
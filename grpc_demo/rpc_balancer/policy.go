@@ -0,0 +1,97 @@
+package rpc_balancer
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strconv"
+)
+
+// Policy 选择后端的策略
+type Policy int
+
+const (
+	RoundRobin       Policy = iota // 轮询
+	LeastOutstanding               // 进行中的调用最少的后端
+	ConsistentHash                 // 按 WithHashKey 的键一致性哈希, 没有键时轮询
+)
+
+func (p Policy) String() string {
+	switch p {
+	case RoundRobin:
+		return "round_robin"
+	case LeastOutstanding:
+		return "least_outstanding"
+	case ConsistentHash:
+		return "consistent_hash"
+	default:
+		return fmt.Sprintf("Policy(%d)", int(p))
+	}
+}
+
+// ParsePolicy parses the names returned by Policy.String.
+func ParsePolicy(s string) (Policy, error) {
+	for _, p := range []Policy{RoundRobin, LeastOutstanding, ConsistentHash} {
+		if p.String() == s {
+			return p, nil
+		}
+	}
+	return 0, fmt.Errorf("rpc_balancer: unknown policy %q", s)
+}
+
+type hashKey struct{}
+
+// WithHashKey sets the key used by ConsistentHash for calls made with ctx.
+func WithHashKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, hashKey{}, key)
+}
+
+func hashKeyFrom(ctx context.Context) (string, bool) {
+	key, ok := ctx.Value(hashKey{}).(string)
+	return key, ok
+}
+
+// ringReplicas 每个后端在哈希环上的虚拟节点数
+const ringReplicas = 100
+
+type ringNode struct {
+	hash uint32
+	addr string
+}
+
+// ring 一致性哈希环, 后端变化时重建
+type ring []ringNode
+
+func newRing(addrs []string) ring {
+	r := make(ring, 0, len(addrs)*ringReplicas)
+	for _, addr := range addrs {
+		for i := 0; i < ringReplicas; i++ {
+			r = append(r, ringNode{hash: hash(addr + "#" + strconv.Itoa(i)), addr: addr})
+		}
+	}
+	sort.Slice(r, func(i, j int) bool { return r[i].hash < r[j].hash })
+	return r
+}
+
+// lookup 从 key 的位置顺时针找到第一个满足 ok 的后端
+func (r ring) lookup(key string, ok func(addr string) bool) (string, bool) {
+	if len(r) == 0 {
+		return "", false
+	}
+	h := hash(key)
+	start := sort.Search(len(r), func(i int) bool { return r[i].hash >= h })
+	for i := 0; i < len(r); i++ {
+		node := r[(start+i)%len(r)]
+		if ok(node.addr) {
+			return node.addr, true
+		}
+	}
+	return "", false
+}
+
+func hash(s string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(s))
+	return h.Sum32()
+}
@@ -0,0 +1,108 @@
+package rpc_balancer
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Resolver 返回服务当前的后端地址, Client 按 RefreshInterval 定期调用
+type Resolver interface {
+	Resolve(ctx context.Context) ([]string, error)
+}
+
+// ResolverFunc adapts a function to Resolver.
+type ResolverFunc func(ctx context.Context) ([]string, error)
+
+// Resolve calls f.
+func (f ResolverFunc) Resolve(ctx context.Context) ([]string, error) {
+	return f(ctx)
+}
+
+// Static returns a resolver for a fixed list of addresses.
+func Static(addrs ...string) Resolver {
+	addrs = append([]string(nil), addrs...)
+	return ResolverFunc(func(context.Context) ([]string, error) {
+		return addrs, nil
+	})
+}
+
+// SRVResolver 通过 DNS SRV 记录发现后端, 地址按优先级和权重排序
+type SRVResolver struct {
+	Service string // 如 "hello", 为空时直接查询 Name
+	Proto   string // 如 "tcp"
+	Name    string // 如 "example.com"
+	// Lookup 默认为 net.DefaultResolver.LookupSRV
+	Lookup func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+}
+
+// Resolve looks up the SRV records.
+func (r *SRVResolver) Resolve(ctx context.Context) ([]string, error) {
+	lookup := r.Lookup
+	if lookup == nil {
+		lookup = net.DefaultResolver.LookupSRV
+	}
+	_, records, err := lookup(ctx, r.Service, r.Proto, r.Name)
+	if err != nil {
+		return nil, err
+	}
+	addrs := make([]string, 0, len(records))
+	for _, srv := range records {
+		host := strings.TrimSuffix(srv.Target, ".")
+		addrs = append(addrs, net.JoinHostPort(host, strconv.Itoa(int(srv.Port))))
+	}
+	return addrs, nil
+}
+
+// FileResolver 从文件读取地址, 每行一个, # 开头为注释. 文件修改后下一次 Resolve 重新读取
+type FileResolver struct {
+	path string
+
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+	addrs   []string
+}
+
+// NewFileResolver returns a resolver reading path.
+func NewFileResolver(path string) *FileResolver {
+	return &FileResolver{path: path}
+}
+
+// Resolve returns the addresses in the file, rereading it when it has changed.
+func (r *FileResolver) Resolve(context.Context) ([]string, error) {
+	info, err := os.Stat(r.path)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.addrs != nil && info.ModTime().Equal(r.modTime) && info.Size() == r.size {
+		return r.addrs, nil
+	}
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		return nil, err
+	}
+	addrs := []string{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		addr := strings.TrimSpace(scanner.Text())
+		if addr == "" || strings.HasPrefix(addr, "#") {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			return nil, fmt.Errorf("rpc_balancer: %s:%d: %w", r.path, line, err)
+		}
+		addrs = append(addrs, addr)
+	}
+	r.addrs, r.modTime, r.size = addrs, info.ModTime(), info.Size()
+	return addrs, nil
+}
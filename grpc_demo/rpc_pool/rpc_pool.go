@@ -0,0 +1,317 @@
+// Package rpc_pool net/rpc 连接池. 一个 *rpc.Client 本身可以并发调用,
+// 连接池在所有连接都有进行中的调用时建立新连接, 把高并发的调用分散到多个连接上.
+package rpc_pool
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/rpc"
+	"sync"
+	"time"
+
+	"grpc_demo/rpc_stub"
+)
+
+// ErrClosed is returned by Call after Close.
+var ErrClosed = errors.New("rpc_pool: pool closed")
+
+const (
+	defaultMaxSize        = 4
+	defaultHealthInterval = 30 * time.Second
+)
+
+// Config 连接池配置
+type Config struct {
+	Dial           func(ctx context.Context) (*rpc.Client, error) // 必填
+	MaxSize        int                                            // 最大连接数, 默认 4
+	IdleTimeout    time.Duration                                  // 没有调用超过该时间的连接被关闭, 0 表示不关闭
+	HealthInterval time.Duration                                  // 空闲淘汰和健康检查的间隔, 默认 30s
+	// HealthCheck 检查空闲的连接, 失败的连接被关闭. 为 nil 时不检查
+	HealthCheck func(ctx context.Context, client *rpc.Client) error
+}
+
+// Stats 连接池统计
+type Stats struct {
+	Open       int    // 当前连接数
+	Idle       int    // 没有进行中调用的连接数
+	InFlight   int    // 进行中的调用数
+	Calls      uint64 // 累计调用数
+	Dials      uint64 // 累计建立的连接数
+	DialErrors uint64 // 累计建立连接失败的次数
+	Evicted    uint64 // 因空闲超时关闭的连接数
+	Broken     uint64 // 因连接断开或健康检查失败关闭的连接数
+}
+
+// conn 池中的一个连接, 字段由 Pool.mu 保护
+type conn struct {
+	client   *rpc.Client
+	inflight int
+	lastUsed time.Time
+}
+
+// Pool net/rpc 连接池
+type Pool struct {
+	cfg Config
+
+	mu      sync.Mutex
+	conns   []*conn
+	dialing int
+	ready   chan struct{} // 有连接建立完成时关闭并替换
+	closed  bool
+	stats   Stats
+
+	ctx    context.Context // 后台检查使用, Close 时取消
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New creates a pool and dials the first connection so that dial errors are
+// reported immediately.
+func New(ctx context.Context, cfg Config) (*Pool, error) {
+	if cfg.Dial == nil {
+		return nil, errors.New("rpc_pool: Dial is required")
+	}
+	if cfg.MaxSize <= 0 {
+		cfg.MaxSize = defaultMaxSize
+	}
+	if cfg.HealthInterval <= 0 {
+		cfg.HealthInterval = defaultHealthInterval
+	}
+	p := &Pool{cfg: cfg, ready: make(chan struct{})}
+	p.ctx, p.cancel = context.WithCancel(context.Background())
+	c, err := p.acquire(ctx)
+	if err != nil {
+		p.cancel()
+		return nil, err
+	}
+	p.release(c, nil)
+	p.wg.Add(1)
+	go p.loop()
+	return p, nil
+}
+
+// Call invokes method on the least busy connection. If the connection was
+// shut down, the call is retried once on another connection. The request may
+// already have been sent on the first connection, so methods called through a
+// Pool should be idempotent.
+func (p *Pool) Call(ctx context.Context, method string, args, reply interface{}) error {
+	err := p.call(ctx, method, args, reply)
+	if errors.Is(err, rpc.ErrShutdown) && ctx.Err() == nil {
+		err = p.call(ctx, method, args, reply)
+	}
+	return err
+}
+
+// Stats returns the current statistics.
+func (p *Pool) Stats() Stats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stats := p.stats
+	stats.Open = len(p.conns)
+	for _, c := range p.conns {
+		if c.inflight == 0 {
+			stats.Idle++
+		}
+		stats.InFlight += c.inflight
+	}
+	return stats
+}
+
+// Close closes every connection, in-flight calls fail with rpc.ErrShutdown.
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	conns := p.conns
+	p.conns = nil
+	p.cancel()
+	p.mu.Unlock()
+
+	p.wg.Wait()
+	for _, c := range conns {
+		c.client.Close()
+	}
+	return nil
+}
+
+func (p *Pool) call(ctx context.Context, method string, args, reply interface{}) error {
+	c, err := p.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	p.stats.Calls++
+	p.mu.Unlock()
+	err = rpc_stub.Call(ctx, c.client, method, args, reply)
+	p.release(c, err)
+	return err
+}
+
+// acquire 选择进行中调用最少的连接, 所有连接都忙且没有达到上限时建立新连接
+func (p *Pool) acquire(ctx context.Context) (*conn, error) {
+	for {
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			return nil, ErrClosed
+		}
+		var best *conn
+		for _, c := range p.conns {
+			if best == nil || c.inflight < best.inflight {
+				best = c
+			}
+		}
+		full := len(p.conns)+p.dialing >= p.cfg.MaxSize
+		if best != nil && (best.inflight == 0 || full) {
+			best.inflight++
+			p.mu.Unlock()
+			return best, nil
+		}
+		if !full {
+			p.dialing++
+			p.mu.Unlock()
+			return p.dial(ctx, best)
+		}
+		// 所有名额都在建立连接, 等待其中一个完成
+		ready := p.ready
+		p.mu.Unlock()
+		select {
+		case <-ready:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// dial 建立新连接, 失败时退回到已有的连接 fallback
+func (p *Pool) dial(ctx context.Context, fallback *conn) (*conn, error) {
+	client, err := p.cfg.Dial(ctx)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.dialing--
+	close(p.ready)
+	p.ready = make(chan struct{})
+	if err != nil {
+		p.stats.DialErrors++
+		if fallback != nil && p.contains(fallback) {
+			fallback.inflight++
+			return fallback, nil
+		}
+		return nil, err
+	}
+	if p.closed {
+		client.Close()
+		return nil, ErrClosed
+	}
+	p.stats.Dials++
+	c := &conn{client: client, inflight: 1}
+	p.conns = append(p.conns, c)
+	return c, nil
+}
+
+// release 结束一次调用, 连接断开时从池中移除
+func (p *Pool) release(c *conn, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	c.inflight--
+	c.lastUsed = time.Now()
+	if isBroken(err) && p.remove(c) {
+		p.stats.Broken++
+		c.client.Close()
+	}
+}
+
+func (p *Pool) loop() {
+	defer p.wg.Done()
+	ticker := time.NewTicker(p.cfg.HealthInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			p.evictIdle()
+			p.checkHealth()
+		case <-p.ctx.Done():
+			return
+		}
+	}
+}
+
+// evictIdle 关闭空闲超时的连接
+func (p *Pool) evictIdle() {
+	if p.cfg.IdleTimeout <= 0 {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, c := range append([]*conn(nil), p.conns...) {
+		if c.inflight == 0 && time.Since(c.lastUsed) > p.cfg.IdleTimeout {
+			p.remove(c)
+			p.stats.Evicted++
+			c.client.Close()
+		}
+	}
+}
+
+// checkHealth 检查空闲的连接, 检查期间计为一个进行中的调用, 避免被同时淘汰.
+// Close 时取消进行中的检查
+func (p *Pool) checkHealth() {
+	if p.cfg.HealthCheck == nil {
+		return
+	}
+	p.mu.Lock()
+	var idle []*conn
+	for _, c := range p.conns {
+		if c.inflight == 0 {
+			c.inflight++
+			idle = append(idle, c)
+		}
+	}
+	p.mu.Unlock()
+
+	for _, c := range idle {
+		ctx, cancel := context.WithTimeout(p.ctx, p.cfg.HealthInterval)
+		err := p.cfg.HealthCheck(ctx, c.client)
+		cancel()
+
+		p.mu.Lock()
+		c.inflight--
+		if err != nil && p.remove(c) {
+			p.stats.Broken++
+			c.client.Close()
+		}
+		p.mu.Unlock()
+	}
+}
+
+func (p *Pool) contains(c *conn) bool {
+	for _, x := range p.conns {
+		if x == c {
+			return true
+		}
+	}
+	return false
+}
+
+// remove 从池中移除 c, 已经移除时返回 false
+func (p *Pool) remove(c *conn) bool {
+	for i, x := range p.conns {
+		if x == c {
+			p.conns = append(p.conns[:i], p.conns[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// isBroken 连接已经不可用的错误
+func isBroken(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, rpc.ErrShutdown) || errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) || errors.As(err, &netErr)
+}
@@ -0,0 +1,218 @@
+package rpc_pool
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/rpc"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"grpc_demo/rpc_stub"
+)
+
+// Echo 测试服务
+type Echo struct {
+	release chan struct{}
+}
+
+func (e *Echo) Echo(request string, reply *string) error {
+	*reply = request
+	return nil
+}
+
+func (e *Echo) Block(request string, reply *string) error {
+	<-e.release
+	*reply = request
+	return nil
+}
+
+func startServer(t *testing.T) (string, *Echo) {
+	t.Helper()
+	svc := &Echo{release: make(chan struct{})}
+	server := rpc.NewServer()
+	if err := server.Register(svc); err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go rpc_stub.ServeConn(server, conn, rpc_stub.Gob)
+		}
+	}()
+	return l.Addr().String(), svc
+}
+
+func dialer(addr string, fail *atomic.Bool) func(ctx context.Context) (*rpc.Client, error) {
+	return func(ctx context.Context) (*rpc.Client, error) {
+		if fail != nil && fail.Load() {
+			return nil, errors.New("dial failed")
+		}
+		return rpc_stub.Dial(ctx, "tcp", addr, rpc_stub.Gob)
+	}
+}
+
+func newPool(t *testing.T, cfg Config) *Pool {
+	t.Helper()
+	p, err := New(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { p.Close() })
+	return p
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("等待%s超时", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestGrowAndReuse(t *testing.T) {
+	addr, svc := startServer(t)
+	p := newPool(t, Config{Dial: dialer(addr, nil), MaxSize: 2})
+
+	// 空闲连接被复用, 不会建立新连接
+	var reply string
+	for i := 0; i < 3; i++ {
+		if err := p.Call(context.Background(), "Echo.Echo", "hi", &reply); err != nil || reply != "hi" {
+			t.Fatalf("Echo = %q, %v", reply, err)
+		}
+	}
+	if stats := p.Stats(); stats.Open != 1 || stats.Dials != 1 || stats.Calls != 3 {
+		t.Errorf("Stats = %+v; 期望 1 个连接", stats)
+	}
+
+	// 连接忙时建立新连接, 达到上限后共享连接
+	done := make(chan error, 3)
+	for i := 0; i < 3; i++ {
+		go func() { done <- p.Call(context.Background(), "Echo.Block", "x", new(string)) }()
+	}
+	waitFor(t, "调用开始", func() bool { return p.Stats().InFlight == 3 })
+	if stats := p.Stats(); stats.Open != 2 || stats.Idle != 0 {
+		t.Errorf("Stats = %+v; 期望 2 个忙碌的连接", stats)
+	}
+	close(svc.release)
+	for i := 0; i < 3; i++ {
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	}
+	if stats := p.Stats(); stats.Idle != 2 || stats.InFlight != 0 {
+		t.Errorf("Stats = %+v; 期望 2 个空闲的连接", stats)
+	}
+}
+
+func TestIdleEviction(t *testing.T) {
+	addr, _ := startServer(t)
+	p := newPool(t, Config{Dial: dialer(addr, nil), IdleTimeout: 30 * time.Millisecond, HealthInterval: 10 * time.Millisecond})
+	waitFor(t, "空闲连接被关闭", func() bool { return p.Stats().Open == 0 })
+	if got := p.Stats().Evicted; got != 1 {
+		t.Errorf("Evicted = %d; 期望值 1", got)
+	}
+	// 之后的调用重新建立连接
+	var reply string
+	if err := p.Call(context.Background(), "Echo.Echo", "again", &reply); err != nil || reply != "again" {
+		t.Errorf("Echo = %q, %v", reply, err)
+	}
+}
+
+func TestHealthCheck(t *testing.T) {
+	addr, _ := startServer(t)
+	var healthy atomic.Bool
+	healthy.Store(true)
+	p := newPool(t, Config{
+		Dial:           dialer(addr, nil),
+		HealthInterval: 10 * time.Millisecond,
+		HealthCheck: func(ctx context.Context, client *rpc.Client) error {
+			if !healthy.Load() {
+				return errors.New("unhealthy")
+			}
+			return rpc_stub.Call(ctx, client, "Echo.Echo", "ping", new(string))
+		},
+	})
+	time.Sleep(50 * time.Millisecond)
+	if stats := p.Stats(); stats.Open != 1 || stats.Broken != 0 {
+		t.Errorf("健康时 Stats = %+v", stats)
+	}
+	healthy.Store(false)
+	waitFor(t, "不健康的连接被关闭", func() bool { return p.Stats().Open == 0 })
+	if got := p.Stats().Broken; got != 1 {
+		t.Errorf("Broken = %d; 期望值 1", got)
+	}
+}
+
+func TestDialErrors(t *testing.T) {
+	addr, svc := startServer(t)
+	var fail atomic.Bool
+	fail.Store(true)
+	if _, err := New(context.Background(), Config{Dial: dialer(addr, &fail)}); err == nil {
+		t.Error("第一个连接失败时 New 应该返回错误")
+	}
+
+	fail.Store(false)
+	p := newPool(t, Config{Dial: dialer(addr, &fail), MaxSize: 2})
+	fail.Store(true)
+	// 连接忙时建立新连接, 失败后使用已有的连接
+	done := make(chan error, 1)
+	go func() { done <- p.Call(context.Background(), "Echo.Block", "x", new(string)) }()
+	waitFor(t, "调用开始", func() bool { return p.Stats().InFlight == 1 })
+	var reply string
+	if err := p.Call(context.Background(), "Echo.Echo", "hi", &reply); err != nil {
+		t.Fatal(err)
+	}
+	if stats := p.Stats(); stats.DialErrors != 1 || stats.Open != 1 {
+		t.Errorf("Stats = %+v; 期望 1 次连接失败", stats)
+	}
+	close(svc.release)
+	if err := <-done; err != nil {
+		t.Error(err)
+	}
+	p.Close()
+	if err := p.Call(context.Background(), "Echo.Echo", "hi", &reply); !errors.Is(err, ErrClosed) {
+		t.Errorf("Close 后 Call = %v; 期望 ErrClosed", err)
+	}
+}
+
+func TestCloseCancelsHealthCheck(t *testing.T) {
+	addr, _ := startServer(t)
+	started := make(chan struct{}, 1)
+	checkErr := make(chan error, 1)
+	p, err := New(context.Background(), Config{
+		Dial:           dialer(addr, nil),
+		HealthInterval: 10 * time.Millisecond,
+		HealthCheck: func(ctx context.Context, client *rpc.Client) error {
+			select {
+			case started <- struct{}{}:
+			default:
+				return nil
+			}
+			<-ctx.Done()
+			checkErr <- ctx.Err()
+			return ctx.Err()
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	p.Close()
+	// 检查应该被 Close 取消, 而不是等到超时
+	if err := <-checkErr; !errors.Is(err, context.Canceled) {
+		t.Errorf("健康检查的 context = %v; 期望 context.Canceled", err)
+	}
+}
@@ -0,0 +1,301 @@
+// Package rpc_server 管理 net/rpc 服务的连接: 编码选择、最大连接数、空闲超时、
+// 优雅关闭和调用日志.
+package rpc_server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/rpc"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"grpc_demo/rpc_stub"
+)
+
+// ErrServerClosed is returned by Serve after Shutdown or Close.
+var ErrServerClosed = errors.New("rpc_server: server closed")
+
+// Config 服务配置, 零值表示 gob 编码、不限制连接数、不关闭空闲连接、不记录日志
+type Config struct {
+	Codec       rpc_stub.Codec
+	MaxConns    int           // 最大并发连接数, 达到上限后暂停 Accept
+	IdleTimeout time.Duration // 连接上没有请求也没有进行中的调用超过该时间后关闭
+	Logger      *log.Logger   // 每次调用记录一行日志
+}
+
+// Server 在 listener 上提供 net/rpc 服务
+type Server struct {
+	rpc *rpc.Server
+	cfg Config
+	sem chan struct{}
+
+	mu        sync.Mutex
+	listeners map[net.Listener]struct{}
+	conns     map[*conn]struct{}
+	closing   bool
+	done      chan struct{}
+	wg        sync.WaitGroup // 进行中的连接
+}
+
+// New creates a Server for the services registered on rpcServer, which
+// defaults to rpc.DefaultServer.
+func New(rpcServer *rpc.Server, cfg Config) *Server {
+	if rpcServer == nil {
+		rpcServer = rpc.DefaultServer
+	}
+	s := &Server{
+		rpc:       rpcServer,
+		cfg:       cfg,
+		listeners: map[net.Listener]struct{}{},
+		conns:     map[*conn]struct{}{},
+		done:      make(chan struct{}),
+	}
+	if cfg.MaxConns > 0 {
+		s.sem = make(chan struct{}, cfg.MaxConns)
+	}
+	return s
+}
+
+// ListenAndServe listens on the TCP address and calls Serve.
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve accepts connections on l until Shutdown or Close is called, then it
+// returns ErrServerClosed. Temporary Accept errors, such as running out of file
+// descriptors, are retried with a backoff. Serve closes l.
+func (s *Server) Serve(l net.Listener) error {
+	if !s.trackListener(l) {
+		l.Close()
+		return ErrServerClosed
+	}
+	defer s.untrackListener(l)
+
+	var delay time.Duration // 临时错误后的等待时间, 与 net/http 相同从 5ms 翻倍到 1s
+	for {
+		if s.sem != nil {
+			select {
+			case s.sem <- struct{}{}:
+			case <-s.done:
+				return ErrServerClosed
+			}
+		}
+		nc, err := l.Accept()
+		if err != nil {
+			s.release()
+			if s.shuttingDown() {
+				return ErrServerClosed
+			}
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Temporary() {
+				delay = min(max(2*delay, 5*time.Millisecond), time.Second)
+				if logger := s.cfg.Logger; logger != nil {
+					logger.Printf("rpc_server: accept error: %v; retrying in %v", err, delay)
+				}
+				select {
+				case <-time.After(delay):
+				case <-s.done:
+					return ErrServerClosed
+				}
+				continue
+			}
+			return err
+		}
+		delay = 0
+		c := &conn{Conn: nc, srv: s, started: map[uint64]time.Time{}}
+		if !s.trackConn(c) {
+			nc.Close()
+			s.release()
+			return ErrServerClosed
+		}
+		go c.serve()
+	}
+}
+
+// Shutdown stops accepting connections and reading new requests, then waits
+// for the in-flight calls to finish. If ctx is done first the remaining
+// connections are closed and ctx.Err() is returned.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.closeLocked()
+	for c := range s.conns {
+		c.stopReading()
+	}
+	s.mu.Unlock()
+
+	finished := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		s.Close()
+		return ctx.Err()
+	}
+}
+
+// Close closes the listeners and all connections immediately.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closeLocked()
+	for c := range s.conns {
+		c.Conn.Close()
+	}
+	return nil
+}
+
+func (s *Server) closeLocked() {
+	if s.closing {
+		return
+	}
+	s.closing = true
+	close(s.done)
+	for l := range s.listeners {
+		l.Close()
+	}
+}
+
+func (s *Server) shuttingDown() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closing
+}
+
+func (s *Server) trackListener(l net.Listener) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closing {
+		return false
+	}
+	s.listeners[l] = struct{}{}
+	return true
+}
+
+func (s *Server) untrackListener(l net.Listener) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.listeners, l)
+}
+
+func (s *Server) trackConn(c *conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closing {
+		return false
+	}
+	s.conns[c] = struct{}{}
+	s.wg.Add(1)
+	return true
+}
+
+func (s *Server) untrackConn(c *conn) {
+	s.mu.Lock()
+	delete(s.conns, c)
+	s.mu.Unlock()
+	s.release()
+	s.wg.Done()
+}
+
+// release 归还一个连接名额
+func (s *Server) release() {
+	if s.sem != nil {
+		<-s.sem
+	}
+}
+
+// conn 一个客户端连接. 每次读之前设置空闲超时, 有进行中的调用时超时不断开
+type conn struct {
+	net.Conn
+	srv      *Server
+	inflight atomic.Int64
+
+	mu       sync.Mutex
+	stopping bool
+	started  map[uint64]time.Time // 按 seq 记录调用开始时间
+}
+
+func (c *conn) serve() {
+	defer c.srv.untrackConn(c)
+	// ServeCodec 在读取失败后等待进行中的调用写完响应再关闭连接
+	codec := rpc_stub.NewServerCodec(c, c.srv.cfg.Codec)
+	c.srv.rpc.ServeCodec(&loggingCodec{ServerCodec: codec, conn: c})
+}
+
+// Read 实现空闲超时, 关闭过程中不再读取新的请求
+func (c *conn) Read(p []byte) (int, error) {
+	for {
+		c.mu.Lock()
+		if c.stopping {
+			c.mu.Unlock()
+			return 0, io.EOF
+		}
+		if timeout := c.srv.cfg.IdleTimeout; timeout > 0 {
+			c.Conn.SetReadDeadline(time.Now().Add(timeout))
+		}
+		c.mu.Unlock()
+
+		n, err := c.Conn.Read(p)
+		var ne net.Error
+		if n == 0 && errors.As(err, &ne) && ne.Timeout() && c.inflight.Load() > 0 {
+			continue
+		}
+		return n, err
+	}
+}
+
+// stopReading 让阻塞的 Read 立即返回, 已经在处理的调用仍然可以写响应
+func (c *conn) stopReading() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stopping = true
+	c.Conn.SetReadDeadline(time.Now())
+}
+
+// loggingCodec 统计进行中的调用并记录日志
+type loggingCodec struct {
+	rpc.ServerCodec
+	conn *conn
+}
+
+func (lc *loggingCodec) ReadRequestHeader(r *rpc.Request) error {
+	if err := lc.ServerCodec.ReadRequestHeader(r); err != nil {
+		return err
+	}
+	// 读到请求头后 net/rpc 一定会写一个响应
+	lc.conn.inflight.Add(1)
+	lc.conn.mu.Lock()
+	lc.conn.started[r.Seq] = time.Now()
+	lc.conn.mu.Unlock()
+	return nil
+}
+
+func (lc *loggingCodec) WriteResponse(r *rpc.Response, body interface{}) error {
+	err := lc.ServerCodec.WriteResponse(r, body)
+	lc.conn.mu.Lock()
+	start, ok := lc.conn.started[r.Seq]
+	delete(lc.conn.started, r.Seq)
+	lc.conn.mu.Unlock()
+	if ok {
+		lc.conn.inflight.Add(-1)
+	}
+	if logger := lc.conn.srv.cfg.Logger; logger != nil {
+		status := "ok"
+		if r.Error != "" {
+			status = "error: " + r.Error
+		}
+		logger.Printf("rpc %s %s %s %s", lc.conn.RemoteAddr(), r.ServiceMethod, time.Since(start).Round(time.Microsecond), status)
+	}
+	return err
+}
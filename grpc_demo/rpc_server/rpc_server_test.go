@@ -0,0 +1,279 @@
+package rpc_server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/rpc"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"grpc_demo/rpc_stub"
+)
+
+// Svc 测试服务, Wait 阻塞到 release 关闭
+type Svc struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s *Svc) Echo(request string, reply *string) error {
+	*reply = request
+	return nil
+}
+
+func (s *Svc) Wait(request string, reply *string) error {
+	s.started <- struct{}{}
+	<-s.release
+	*reply = request
+	return nil
+}
+
+// syncBuffer 日志可能在多个连接的协程中写入
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func start(t *testing.T, cfg Config) (*Server, *Svc, string, <-chan error) {
+	t.Helper()
+	svc := &Svc{started: make(chan struct{}, 4), release: make(chan struct{})}
+	rs := rpc.NewServer()
+	if err := rs.Register(svc); err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := New(rs, cfg)
+	errs := make(chan error, 1)
+	go func() { errs <- s.Serve(l) }()
+	t.Cleanup(func() { s.Close() })
+	return s, svc, l.Addr().String(), errs
+}
+
+func dial(t *testing.T, addr string, codec rpc_stub.Codec) *rpc.Client {
+	t.Helper()
+	c, err := rpc_stub.Dial(context.Background(), "tcp", addr, codec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func waitStarted(t *testing.T, svc *Svc) {
+	t.Helper()
+	select {
+	case <-svc.started:
+	case <-time.After(2 * time.Second):
+		t.Fatal("调用没有开始")
+	}
+}
+
+func TestCodecAndLogging(t *testing.T) {
+	for _, codec := range []rpc_stub.Codec{rpc_stub.Gob, rpc_stub.JSON} {
+		t.Run(codec.String(), func(t *testing.T) {
+			var buf syncBuffer
+			_, _, addr, _ := start(t, Config{Codec: codec, Logger: log.New(&buf, "", 0)})
+			c := dial(t, addr, codec)
+			var reply string
+			if err := c.Call("Svc.Echo", "hi", &reply); err != nil || reply != "hi" {
+				t.Errorf("Echo = %q, %v; 期望值 hi", reply, err)
+			}
+			if err := c.Call("Svc.Missing", "hi", &reply); err == nil {
+				t.Error("不存在的方法应该返回错误")
+			}
+			logs := buf.String()
+			if !strings.Contains(logs, "Svc.Echo") || !strings.Contains(logs, " ok\n") || !strings.Contains(logs, "error: rpc: can't find method") {
+				t.Errorf("日志 = %q", logs)
+			}
+		})
+	}
+}
+
+func TestGracefulShutdown(t *testing.T) {
+	s, svc, addr, errs := start(t, Config{})
+	busy := dial(t, addr, rpc_stub.Gob)
+	dial(t, addr, rpc_stub.Gob) // 空闲连接不影响关闭
+
+	call := busy.Go("Svc.Wait", "inflight", new(string), nil)
+	waitStarted(t, svc)
+
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- s.Shutdown(context.Background()) }()
+	select {
+	case err := <-errs:
+		if !errors.Is(err, ErrServerClosed) {
+			t.Errorf("Serve = %v; 期望 ErrServerClosed", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Serve 没有返回")
+	}
+	select {
+	case err := <-shutdown:
+		t.Fatalf("调用结束前 Shutdown 返回 %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(svc.release)
+	<-call.Done
+	if call.Error != nil || *call.Reply.(*string) != "inflight" {
+		t.Errorf("进行中的调用 = %v, %v", *call.Reply.(*string), call.Error)
+	}
+	select {
+	case err := <-shutdown:
+		if err != nil {
+			t.Errorf("Shutdown = %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Shutdown 没有返回")
+	}
+	if _, err := net.Dial("tcp", addr); err == nil {
+		t.Error("关闭后不应该再接受连接")
+	}
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	s, svc, addr, _ := start(t, Config{Codec: rpc_stub.JSON})
+	c := dial(t, addr, rpc_stub.JSON)
+	call := c.Go("Svc.Wait", "x", new(string), nil)
+	waitStarted(t, svc)
+	defer close(svc.release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := s.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown = %v; 期望 context.DeadlineExceeded", err)
+	}
+	// 超时后强制关闭连接
+	select {
+	case <-call.Done:
+		if call.Error == nil {
+			t.Error("强制关闭后调用应该失败")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("强制关闭后调用没有结束")
+	}
+}
+
+func TestMaxConns(t *testing.T) {
+	_, _, addr, _ := start(t, Config{MaxConns: 1})
+	first := dial(t, addr, rpc_stub.Gob)
+	var reply string
+	if err := first.Call("Svc.Echo", "1", &reply); err != nil {
+		t.Fatal(err)
+	}
+
+	// 第二个连接在第一个关闭前不会被处理
+	second := dial(t, addr, rpc_stub.Gob)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := rpc_stub.Call(ctx, second, "Svc.Echo", "2", &reply); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("超过最大连接数时 Echo = %v; 期望 context.DeadlineExceeded", err)
+	}
+
+	first.Close()
+	ctx, cancel = context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := rpc_stub.Call(ctx, second, "Svc.Echo", "2", &reply); err != nil {
+		t.Errorf("第一个连接关闭后 Echo = %v", err)
+	}
+}
+
+func TestIdleTimeout(t *testing.T) {
+	_, svc, addr, _ := start(t, Config{IdleTimeout: 100 * time.Millisecond})
+	c := dial(t, addr, rpc_stub.Gob)
+
+	// 调用时间超过空闲超时, 连接不会被关闭
+	call := c.Go("Svc.Wait", "long", new(string), nil)
+	waitStarted(t, svc)
+	time.Sleep(250 * time.Millisecond)
+	close(svc.release)
+	<-call.Done
+	if call.Error != nil {
+		t.Fatalf("长时间调用 = %v", call.Error)
+	}
+
+	time.Sleep(300 * time.Millisecond)
+	var reply string
+	if err := c.Call("Svc.Echo", "idle", &reply); err == nil {
+		t.Error("空闲连接应该被关闭")
+	}
+}
+
+// tempErr 临时的 Accept 错误, 例如文件描述符用完
+type tempErr struct{}
+
+func (tempErr) Error() string   { return "too many open files" }
+func (tempErr) Timeout() bool   { return false }
+func (tempErr) Temporary() bool { return true }
+
+// flakyListener 前 failures 次 Accept 返回临时错误
+type flakyListener struct {
+	net.Listener
+	mu       sync.Mutex
+	failures int
+}
+
+func (l *flakyListener) Accept() (net.Conn, error) {
+	l.mu.Lock()
+	if l.failures > 0 {
+		l.failures--
+		l.mu.Unlock()
+		return nil, tempErr{}
+	}
+	l.mu.Unlock()
+	return l.Listener.Accept()
+}
+
+func TestTemporaryAcceptError(t *testing.T) {
+	rs := rpc.NewServer()
+	if err := rs.Register(&Svc{}); err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf syncBuffer
+	s := New(rs, Config{Logger: log.New(&buf, "", 0)})
+	errs := make(chan error, 1)
+	go func() { errs <- s.Serve(&flakyListener{Listener: l, failures: 3}) }()
+	t.Cleanup(func() { s.Close() })
+
+	// 临时错误后继续接受连接
+	var reply string
+	if err := dial(t, l.Addr().String(), rpc_stub.Gob).Call("Svc.Echo", "hi", &reply); err != nil || reply != "hi" {
+		t.Errorf("Echo = %q, %v; 期望值 hi", reply, err)
+	}
+	if n := strings.Count(buf.String(), "accept error"); n != 3 {
+		t.Errorf("记录了 %d 次 Accept 错误; 期望值 3:\n%s", n, buf.String())
+	}
+	s.Close()
+	select {
+	case err := <-errs:
+		if !errors.Is(err, ErrServerClosed) {
+			t.Errorf("Serve = %v; 期望 ErrServerClosed", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Close 后 Serve 没有返回")
+	}
+}
@@ -0,0 +1,92 @@
+// Package rpc_stub rpcgen 生成的代码使用的公共函数: 编解码选择、带 context 的拨号和调用.
+package rpc_stub
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+)
+
+// Codec net/rpc 连接使用的编码格式
+type Codec int
+
+const (
+	Gob  Codec = iota // net/rpc 默认的 gob 编码
+	JSON              // net/rpc/jsonrpc 的 JSON-RPC 1.0 编码
+)
+
+func (c Codec) String() string {
+	switch c {
+	case Gob:
+		return "gob"
+	case JSON:
+		return "json"
+	default:
+		return fmt.Sprintf("Codec(%d)", int(c))
+	}
+}
+
+// ParseCodec parses "gob" or "json".
+func ParseCodec(s string) (Codec, error) {
+	switch s {
+	case "gob", "":
+		return Gob, nil
+	case "json", "jsonrpc":
+		return JSON, nil
+	default:
+		return 0, fmt.Errorf("rpc_stub: unknown codec %q", s)
+	}
+}
+
+// NewClient creates a client speaking codec on conn.
+func NewClient(conn io.ReadWriteCloser, codec Codec) *rpc.Client {
+	if codec == JSON {
+		return jsonrpc.NewClient(conn)
+	}
+	return rpc.NewClient(conn)
+}
+
+// NewServerCodec creates the server side codec for conn.
+func NewServerCodec(conn io.ReadWriteCloser, codec Codec) rpc.ServerCodec {
+	if codec == JSON {
+		return jsonrpc.NewServerCodec(conn)
+	}
+	return gobServerCodec(conn)
+}
+
+// ServeConn serves conn with codec until the client hangs up. server defaults
+// to rpc.DefaultServer.
+func ServeConn(server *rpc.Server, conn io.ReadWriteCloser, codec Codec) {
+	if server == nil {
+		server = rpc.DefaultServer
+	}
+	server.ServeCodec(NewServerCodec(conn, codec))
+}
+
+// Dial connects to address, honoring the deadline and cancellation of ctx.
+func Dial(ctx context.Context, network, address string, codec Codec) (*rpc.Client, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(conn, codec), nil
+}
+
+// Call invokes method and waits for the reply or for ctx to be done. On
+// cancellation the call is abandoned: its reply, if any, is discarded.
+func Call(ctx context.Context, client *rpc.Client, method string, args, reply interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	call := client.Go(method, args, reply, make(chan *rpc.Call, 1))
+	select {
+	case <-call.Done:
+		return call.Error
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,172 @@
+package rpcgen
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"sort"
+	"strconv"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	rpcStubImport = "grpc_demo/rpc_stub"
+	header        = "// Code generated by rpcgen. DO NOT EDIT.\n\n"
+)
+
+// GenerateServer generates the registration code for iface. The code belongs to
+// the package that declares the interface; serviceName is the net/rpc service
+// name and defaults to the interface name.
+func GenerateServer(iface *Interface, serviceName string) ([]byte, error) {
+	g := newGenerator(iface, iface.Package, serviceName)
+	g.use("net/rpc")
+
+	adapter := lowerFirst(iface.Name) + "RPC"
+	g.printf("// %s 把 %s 适配为 net/rpc 要求的方法形式, 只导出接口中的方法\n", adapter, iface.Name)
+	g.printf("type %s struct {\n\tsrv %s\n}\n\n", adapter, iface.Name)
+	for _, m := range iface.Methods {
+		request, reply := g.typeString(m.request), g.typeString(m.reply)
+		g.printf("func (s *%s) %s(request %s, reply *%s) error {\n", adapter, m.Name, request, reply)
+		if m.WithContext {
+			g.use("context")
+			g.printf("\tr, err := s.srv.%s(context.Background(), request)\n", m.Name)
+			g.printf("\tif err != nil {\n\t\treturn err\n\t}\n\t*reply = r\n\treturn nil\n}\n\n")
+		} else {
+			g.printf("\treturn s.srv.%s(request, reply)\n}\n\n", m.Name)
+		}
+	}
+
+	g.printf("// Register%[1]s registers srv on rpc.DefaultServer.\n", iface.Name)
+	g.printf("func Register%[1]s(srv %[1]s) error {\n\treturn Register%[1]sOn(rpc.DefaultServer, srv)\n}\n\n", iface.Name)
+	g.printf("// Register%[1]sOn registers srv on server under the name %[2]s.\n", iface.Name, g.serviceName)
+	g.printf("func Register%[1]sOn(server *rpc.Server, srv %[1]s) error {\n", iface.Name)
+	g.printf("\treturn server.RegisterName(%s, &%s{srv})\n}\n", g.nameConst, adapter)
+	return g.source()
+}
+
+// GenerateClient generates a client for iface in package pkg. Types declared in
+// the interface's package are qualified when pkg is a different package.
+func GenerateClient(iface *Interface, serviceName, pkg string) ([]byte, error) {
+	g := newGenerator(iface, pkg, serviceName)
+	g.use("context")
+	g.use("net/rpc")
+	g.use(rpcStubImport)
+
+	client := iface.Name + "Client"
+	g.printf("// %s %s 的客户端, 每个方法都支持 context 的超时和取消\n", client, iface.Name)
+	g.printf("type %s struct {\n\tclient *rpc.Client\n}\n\n", client)
+	g.printf("// Wrap%[1]s wraps an established net/rpc client.\n", client)
+	g.printf("func Wrap%[1]s(client *rpc.Client) *%[1]s {\n\treturn &%[1]s{client: client}\n}\n\n", client)
+	g.printf("// Dial%[1]s connects to address with codec.\n", iface.Name)
+	g.printf("func Dial%s(ctx context.Context, network, address string, codec rpc_stub.Codec) (*%s, error) {\n", iface.Name, client)
+	g.printf("\tclient, err := rpc_stub.Dial(ctx, network, address, codec)\n")
+	g.printf("\tif err != nil {\n\t\treturn nil, err\n\t}\n\treturn Wrap%s(client), nil\n}\n\n", client)
+	g.printf("// Close closes the connection.\n")
+	g.printf("func (c *%s) Close() error {\n\treturn c.client.Close()\n}\n", client)
+
+	for _, m := range iface.Methods {
+		request, reply := g.typeString(m.request), g.typeString(m.reply)
+		g.printf("\n// %s calls %s.%s.\n", m.Name, g.serviceName, m.Name)
+		g.printf("func (c *%s) %s(ctx context.Context, request %s) (%s, error) {\n", client, m.Name, request, reply)
+		g.printf("\tvar reply %s\n", reply)
+		g.printf("\terr := rpc_stub.Call(ctx, c.client, %s+%q, request, &reply)\n", g.nameConst, "."+m.Name)
+		g.printf("\treturn reply, err\n}\n")
+	}
+	return g.source()
+}
+
+// generator 生成一个文件, 记录用到的导入
+type generator struct {
+	iface       *Interface
+	pkg         string
+	serviceName string
+	nameConst   string
+	qualify     bool
+	imports     map[string]bool
+	body        bytes.Buffer
+}
+
+func newGenerator(iface *Interface, pkg, serviceName string) *generator {
+	if serviceName == "" {
+		serviceName = iface.Name
+	}
+	return &generator{
+		iface:       iface,
+		pkg:         pkg,
+		serviceName: serviceName,
+		nameConst:   lowerFirst(iface.Name) + "Name",
+		qualify:     pkg != iface.Package,
+		imports:     map[string]bool{},
+	}
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.body, format, args...)
+}
+
+func (g *generator) use(path string) {
+	g.imports[path] = true
+}
+
+// typeString 输出类型表达式, 记录其中用到的包, 必要时给接口所在包的类型加上包名
+func (g *generator) typeString(expr ast.Expr) string {
+	// 重新解析得到一份副本, 直接修改其中的标识符
+	copied, err := parser.ParseExpr(exprString(expr))
+	if err != nil {
+		return exprString(expr)
+	}
+	selectors := map[*ast.Ident]bool{}
+	ast.Inspect(copied, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.SelectorExpr:
+			selectors[n.Sel] = true
+			if x, ok := n.X.(*ast.Ident); ok {
+				if path, ok := g.iface.imports[x.Name]; ok {
+					g.use(path)
+				}
+				selectors[x] = true
+			}
+		case *ast.Ident:
+			if g.qualify && !selectors[n] && g.iface.local[n.Name] {
+				g.use(g.iface.ImportPath)
+				n.Name = g.iface.Package + "." + n.Name
+			}
+		}
+		return true
+	})
+	return exprString(copied)
+}
+
+func (g *generator) source() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(header)
+	fmt.Fprintf(&buf, "package %s\n\n", g.pkg)
+
+	paths := make([]string, 0, len(g.imports))
+	for path := range g.imports {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	buf.WriteString("import (\n")
+	for _, path := range paths {
+		buf.WriteString("\t" + strconv.Quote(path) + "\n")
+	}
+	buf.WriteString(")\n\n")
+
+	fmt.Fprintf(&buf, "const %s = %q\n\n", g.nameConst, g.serviceName)
+	buf.Write(g.body.Bytes())
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("rpcgen: format generated code: %w", err)
+	}
+	return src, nil
+}
+
+func lowerFirst(s string) string {
+	r, n := utf8.DecodeRuneInString(s)
+	return string(unicode.ToLower(r)) + s[n:]
+}
@@ -0,0 +1,227 @@
+// Package rpcgen 根据 Go 接口生成 net/rpc 的服务注册代码和带 context 的客户端.
+//
+// 接口方法支持两种形式:
+//
+//	Hello(request string, reply *string) error               // net/rpc 形式
+//	Hello(ctx context.Context, request string) (string, error) // 带 context 的形式
+//
+// 生成的客户端方法统一为第二种形式.
+package rpcgen
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Method 接口中的一个方法
+type Method struct {
+	Name        string
+	Request     string // 请求类型
+	Reply       string // 响应类型, 不含指针
+	WithContext bool   // 是否为带 context 的形式
+
+	request, reply ast.Expr
+}
+
+// Interface 解析出的接口
+type Interface struct {
+	Name       string
+	Package    string // 包名
+	ImportPath string // 包的导入路径
+	Methods    []Method
+	// imports 方法签名中用到的其它包, 键为包名
+	imports map[string]string
+	// local 在接口所在包中定义的类型, 生成到其它包时需要加包名
+	local map[string]bool
+}
+
+// Parse finds the interface typeName in the Go package in dir.
+func Parse(dir, typeName string) (*Interface, error) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(info os.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, pkg := range pkgs {
+		for _, file := range pkg.Files {
+			iface, err := parseFile(fset, file, typeName)
+			if err != nil {
+				return nil, err
+			}
+			if iface == nil {
+				continue
+			}
+			iface.local = localTypes(pkg)
+			if iface.ImportPath, err = importPath(dir); err != nil {
+				return nil, err
+			}
+			return iface, nil
+		}
+	}
+	return nil, fmt.Errorf("rpcgen: interface %s not found in %s", typeName, dir)
+}
+
+func parseFile(fset *token.FileSet, file *ast.File, typeName string) (*Interface, error) {
+	var spec *ast.InterfaceType
+	ast.Inspect(file, func(n ast.Node) bool {
+		if ts, ok := n.(*ast.TypeSpec); ok && ts.Name.Name == typeName {
+			spec, _ = ts.Type.(*ast.InterfaceType)
+		}
+		return spec == nil
+	})
+	if spec == nil {
+		return nil, nil
+	}
+
+	iface := &Interface{Name: typeName, Package: file.Name.Name, imports: map[string]string{}}
+	fileImports := map[string]string{}
+	for _, imp := range file.Imports {
+		path, _ := strconv.Unquote(imp.Path.Value)
+		name := filepath.Base(path)
+		if imp.Name != nil {
+			name = imp.Name.Name
+		}
+		fileImports[name] = path
+	}
+	for _, field := range spec.Methods.List {
+		fn, ok := field.Type.(*ast.FuncType)
+		if !ok || len(field.Names) == 0 {
+			return nil, fmt.Errorf("rpcgen: %s: embedded interfaces are not supported", fset.Position(field.Pos()))
+		}
+		m, err := parseMethod(fset, field.Names[0].Name, fn)
+		if err != nil {
+			return nil, err
+		}
+		iface.Methods = append(iface.Methods, m)
+		// 记录签名中用到的包
+		ast.Inspect(fn, func(n ast.Node) bool {
+			if sel, ok := n.(*ast.SelectorExpr); ok {
+				if x, ok := sel.X.(*ast.Ident); ok && fileImports[x.Name] != "" {
+					iface.imports[x.Name] = fileImports[x.Name]
+				}
+			}
+			return true
+		})
+	}
+	delete(iface.imports, "context")
+	return iface, nil
+}
+
+func parseMethod(fset *token.FileSet, name string, fn *ast.FuncType) (Method, error) {
+	params := flatten(fn.Params)
+	results := flatten(fn.Results)
+	m := Method{Name: name}
+	bad := fmt.Errorf("rpcgen: %s: method %s must be M(req T, reply *R) error or M(ctx context.Context, req T) (R, error)",
+		fset.Position(fn.Pos()), name)
+
+	if len(params) != 2 || len(results) == 0 || exprString(results[len(results)-1]) != "error" {
+		return m, bad
+	}
+	if exprString(params[0]) == "context.Context" {
+		if len(results) != 2 {
+			return m, bad
+		}
+		m.WithContext = true
+		m.request, m.reply = params[1], results[0]
+		m.Request, m.Reply = exprString(m.request), exprString(m.reply)
+		return m, nil
+	}
+	star, ok := params[1].(*ast.StarExpr)
+	if !ok || len(results) != 1 {
+		return m, bad
+	}
+	m.request, m.reply = params[0], star.X
+	m.Request, m.Reply = exprString(m.request), exprString(m.reply)
+	return m, nil
+}
+
+// flatten 把 (a, b string) 展开为每个参数一个类型
+func flatten(fields *ast.FieldList) []ast.Expr {
+	if fields == nil {
+		return nil
+	}
+	var types []ast.Expr
+	for _, field := range fields.List {
+		n := len(field.Names)
+		if n == 0 {
+			n = 1
+		}
+		for i := 0; i < n; i++ {
+			types = append(types, field.Type)
+		}
+	}
+	return types
+}
+
+func exprString(expr ast.Expr) string {
+	var buf bytes.Buffer
+	printer.Fprint(&buf, token.NewFileSet(), expr)
+	return buf.String()
+}
+
+func localTypes(pkg *ast.Package) map[string]bool {
+	local := map[string]bool{}
+	for _, file := range pkg.Files {
+		for _, decl := range file.Decls {
+			if gd, ok := decl.(*ast.GenDecl); ok && gd.Tok == token.TYPE {
+				for _, spec := range gd.Specs {
+					local[spec.(*ast.TypeSpec).Name.Name] = true
+				}
+			}
+		}
+	}
+	return local
+}
+
+// importPath 根据 go.mod 计算目录的导入路径
+func importPath(dir string) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for root := abs; ; root = filepath.Dir(root) {
+		if module, err := modulePath(filepath.Join(root, "go.mod")); err == nil {
+			rel, err := filepath.Rel(root, abs)
+			if err != nil {
+				return "", err
+			}
+			if rel == "." {
+				return module, nil
+			}
+			return module + "/" + filepath.ToSlash(rel), nil
+		}
+		if filepath.Dir(root) == root {
+			return "", fmt.Errorf("rpcgen: no go.mod above %s", abs)
+		}
+	}
+}
+
+func modulePath(gomod string) (string, error) {
+	f, err := os.Open(gomod)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); strings.HasPrefix(line, "module ") {
+			module := strings.TrimSpace(strings.TrimPrefix(line, "module "))
+			if unquoted, err := strconv.Unquote(module); err == nil {
+				module = unquoted
+			}
+			return module, nil
+		}
+	}
+	return "", fmt.Errorf("rpcgen: no module line in %s", gomod)
+}
@@ -0,0 +1,110 @@
+package rpcgen
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	iface, err := Parse("testdata/calc", "Calculator")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if iface.Package != "calc" || iface.ImportPath != "grpc_demo/rpcgen/testdata/calc" {
+		t.Errorf("包 = %s %s", iface.Package, iface.ImportPath)
+	}
+	want := []Method{
+		{Name: "Add", Request: "Args", Reply: "int"},
+		{Name: "Div", Request: "*Args", Reply: "[]Args", WithContext: true},
+		{Name: "Sleep", Request: "time.Duration", Reply: "time.Time", WithContext: true},
+	}
+	if len(iface.Methods) != len(want) {
+		t.Fatalf("方法数 = %d; 期望值 %d", len(iface.Methods), len(want))
+	}
+	for i, m := range iface.Methods {
+		m.request, m.reply = nil, nil
+		if m != want[i] {
+			t.Errorf("方法 %d = %+v; 期望值 %+v", i, m, want[i])
+		}
+	}
+
+	if _, err := Parse("testdata/calc", "Missing"); err == nil {
+		t.Error("接口不存在时应该返回错误")
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	iface, err := Parse("testdata/calc", "Calculator")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := GenerateServer(iface, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := GenerateClient(iface, "calc/Calculator", "calcclient")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		src  []byte
+		want []string
+	}{
+		{"服务端", server, []string{
+			"package calc\n",
+			`const calculatorName = "Calculator"`,
+			"func (s *calculatorRPC) Add(request Args, reply *int) error {",
+			"func (s *calculatorRPC) Div(request *Args, reply *[]Args) error {",
+			"r, err := s.srv.Div(context.Background(), request)",
+			"func RegisterCalculatorOn(server *rpc.Server, srv Calculator) error {",
+		}},
+		{"客户端", client, []string{
+			"package calcclient\n",
+			`"grpc_demo/rpcgen/testdata/calc"`,
+			`"time"`,
+			`const calculatorName = "calc/Calculator"`,
+			"func (c *CalculatorClient) Add(ctx context.Context, request calc.Args) (int, error) {",
+			"func (c *CalculatorClient) Div(ctx context.Context, request *calc.Args) ([]calc.Args, error) {",
+			"func (c *CalculatorClient) Sleep(ctx context.Context, request time.Duration) (time.Time, error) {",
+			`rpc_stub.Call(ctx, c.client, calculatorName+".Div", request, &reply)`,
+		}},
+	}
+	for _, tt := range tests {
+		for _, want := range tt.want {
+			if !strings.Contains(string(tt.src), want) {
+				t.Errorf("%s代码缺少 %q:\n%s", tt.name, want, tt.src)
+			}
+		}
+	}
+}
+
+// 提交的生成代码应该与接口保持一致, 修改接口后需要执行 go generate
+func TestGeneratedUpToDate(t *testing.T) {
+	iface, err := Parse("../server_proxy", "HelloService")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := GenerateServer(iface, "handler/HelloService")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := GenerateClient(iface, "handler/HelloService", "hello_client")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, want := range map[string][]byte{
+		"../server_proxy/hello_service_server_gen.go": server,
+		"../hello_client/hello_service_client_gen.go": client,
+	} {
+		got, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != string(want) {
+			t.Errorf("%s 不是最新的, 请在 server_proxy 目录执行 go generate", path)
+		}
+	}
+}
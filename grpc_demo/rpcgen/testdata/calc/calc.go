@@ -0,0 +1,16 @@
+package calc
+
+import (
+	"context"
+	"time"
+)
+
+type Args struct {
+	A, B int
+}
+
+type Calculator interface {
+	Add(args Args, reply *int) error
+	Div(ctx context.Context, args *Args) ([]Args, error)
+	Sleep(ctx context.Context, d time.Duration) (time.Time, error)
+}
@@ -1,24 +1,93 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"grpc_demo/handler"
+	"grpc_demo/jsonrpc_http"
+	"grpc_demo/rpc_server"
+	"grpc_demo/rpc_stub"
 	"grpc_demo/server_proxy"
+	"log"
 	"net"
+	"net/http"
 	"net/rpc"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
 func main() {
+	httpMode := flag.Bool("http", false, "通过 HTTP 提供 JSON-RPC 服务 (/jsonrpc), 供 go_json_rpc_client.py 调用")
+	codecName := flag.String("codec", "gob", "编码格式, gob 或 json, 不能与 -http 同时使用")
+	maxConns := flag.Int("max-conns", 0, "最大并发连接数, 0 表示不限制, 不能与 -http 同时使用")
+	idleTimeout := flag.Duration("idle-timeout", 5*time.Minute, "空闲连接的超时时间, 0 表示不关闭")
+	shutdownTimeout := flag.Duration("shutdown-timeout", 10*time.Second, "收到退出信号后等待进行中调用的时间")
+	flag.Parse()
+	if *httpMode {
+		// HTTP 模式固定使用 JSON-RPC, 连接由 http.Server 管理
+		flag.Visit(func(f *flag.Flag) {
+			if f.Name == "codec" || f.Name == "max-conns" {
+				log.Fatalf("-%s 不能与 -http 同时使用", f.Name)
+			}
+		})
+	}
+	codec, err := rpc_stub.ParseCodec(*codecName)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	// 1.实例化一个server
-	listener, _ := net.Listen("tcp", ":1234")
+	listener, err := net.Listen("tcp", ":1234")
+	if err != nil {
+		log.Fatalf("监听失败: %v", err)
+	}
 	// 2.注册处理逻辑 handler
-	server_proxy.RegisterHelloService(&handler.NewHelloService{})
+	if err := server_proxy.RegisterHelloService(&handler.NewHelloService{}); err != nil {
+		log.Fatalf("注册服务失败: %v", err)
+	}
 
-	for {
-		// 3. 启动服务
-		conn, _ := listener.Accept() // 当一个新的连接进来的时候
-		// rpc.ServeConn(conn)
-		// 设备编码格式为json编码格式
-		go rpc.ServeConn(conn)
+	var (
+		srv interface {
+			Shutdown(ctx context.Context) error
+		}
+		serve func() error
+	)
+	if *httpMode {
+		mux := http.NewServeMux()
+		mux.Handle(jsonrpc_http.DefaultPath, jsonrpc_http.NewHandler(rpc.DefaultServer))
+		hs := &http.Server{Handler: mux, IdleTimeout: *idleTimeout}
+		srv, serve = hs, func() error { return hs.Serve(listener) }
+	} else {
+		rs := rpc_server.New(rpc.DefaultServer, rpc_server.Config{
+			Codec:       codec,
+			MaxConns:    *maxConns,
+			IdleTimeout: *idleTimeout,
+			Logger:      log.Default(),
+		})
+		srv, serve = rs, func() error { return rs.Serve(listener) }
 	}
 
+	// 3. 启动服务, 收到 SIGINT/SIGTERM 后优雅退出
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	errs := make(chan error, 1)
+	go func() { errs <- serve() }()
+	log.Printf("服务启动: %s", listener.Addr())
+
+	select {
+	case err := <-errs:
+		log.Fatalf("服务异常退出: %v", err)
+	case <-ctx.Done():
+	}
+	log.Print("正在关闭服务, 等待进行中的调用")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("关闭服务: %v", err)
+	}
+	if err := <-errs; err != nil && !errors.Is(err, rpc_server.ErrServerClosed) && !errors.Is(err, http.ErrServerClosed) {
+		log.Printf("服务退出: %v", err)
+	}
 }
@@ -0,0 +1,28 @@
+// Code generated by rpcgen. DO NOT EDIT.
+
+package server_proxy
+
+import (
+	"net/rpc"
+)
+
+const helloServiceName = "handler/HelloService"
+
+// helloServiceRPC 把 HelloService 适配为 net/rpc 要求的方法形式, 只导出接口中的方法
+type helloServiceRPC struct {
+	srv HelloService
+}
+
+func (s *helloServiceRPC) Hello(request string, reply *string) error {
+	return s.srv.Hello(request, reply)
+}
+
+// RegisterHelloService registers srv on rpc.DefaultServer.
+func RegisterHelloService(srv HelloService) error {
+	return RegisterHelloServiceOn(rpc.DefaultServer, srv)
+}
+
+// RegisterHelloServiceOn registers srv on server under the name handler/HelloService.
+func RegisterHelloServiceOn(server *rpc.Server, srv HelloService) error {
+	return server.RegisterName(helloServiceName, &helloServiceRPC{srv})
+}
@@ -1,14 +1,8 @@
 package server_proxy
 
-import (
-	"grpc_demo/handler"
-	"net/rpc"
-)
+//go:generate go run grpc_demo/cmd/rpcgen -type HelloService -name handler/HelloService -server hello_service_server_gen.go -client ../hello_client/hello_service_client_gen.go
 
+// HelloService 注册和客户端代码由 rpcgen 生成, 修改接口后执行 go generate
 type HelloService interface {
 	Hello(request string, reply *string) error
 }
-
-func RegisterHelloService(srv HelloService) error {
-	return rpc.RegisterName(handler.HelloServiceName, srv)
-}
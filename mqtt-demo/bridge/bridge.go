@@ -0,0 +1,274 @@
+package bridge
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/gorilla/websocket"
+	"mqtt-demo/broker"
+	"mqtt-demo/config"
+)
+
+const (
+	publishPrefix = "/publish/"
+	subscribePath = "/subscribe"
+)
+
+// Config 桥接服务的配置
+type Config struct {
+	PublishAllow   []string // 允许通过 HTTP 发布的主题过滤器, 为空表示禁止发布
+	SubscribeAllow []string // 允许浏览器订阅的主题过滤器, 为空表示禁止订阅
+	AllowedOrigins []string // WebSocket 允许的 Origin, 为空时只允许同源
+	MaxPayload     int64    // HTTP 发布的最大消息体, 默认 1MB
+	BufferSize     int      // 每个浏览器连接的消息缓冲, 满了之后丢弃新消息, 默认 64
+}
+
+// Message 推送给浏览器的消息
+type Message struct {
+	Topic    string `json:"topic"`
+	Payload  string `json:"payload"`
+	Encoding string `json:"encoding,omitempty"` // 非 UTF-8 内容为 base64
+	Qos      byte   `json:"qos"`
+	Retained bool   `json:"retained"`
+}
+
+// Bridge 通过 HTTP 把 MqttClient 暴露给不能直接连接 MQTT 的浏览器:
+//
+//	POST /publish/{topic}            请求体作为消息发布
+//	GET  /subscribe?filter={filter}  WebSocket 或 SSE 推送匹配的消息
+type Bridge struct {
+	mc       *config.MqttClient
+	config   Config
+	upgrader websocket.Upgrader
+
+	subMu sync.Mutex // 串行化向 broker 的订阅和取消订阅
+	mu    sync.Mutex
+	subs  map[string]*subscription // 主题过滤器 -> 浏览器连接
+}
+
+type subscription struct {
+	listeners map[chan Message]struct{}
+}
+
+func NewBridge(mc *config.MqttClient, cfg Config) *Bridge {
+	if cfg.MaxPayload <= 0 {
+		cfg.MaxPayload = 1 << 20
+	}
+	if cfg.BufferSize <= 0 {
+		cfg.BufferSize = 64
+	}
+	b := &Bridge{
+		mc:     mc,
+		config: cfg,
+		subs:   make(map[string]*subscription),
+	}
+	b.upgrader.CheckOrigin = b.checkOrigin
+	return b
+}
+
+func (b *Bridge) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case strings.HasPrefix(r.URL.Path, publishPrefix):
+		b.handlePublish(w, r)
+	case r.URL.Path == subscribePath:
+		b.handleSubscribe(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (b *Bridge) handlePublish(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	// 主题中的 / 可能被转义, 使用原始路径解码
+	topic, err := url.PathUnescape(strings.TrimPrefix(r.URL.EscapedPath(), publishPrefix))
+	if err != nil || topic == "" || strings.ContainsAny(topic, "+#") {
+		http.Error(w, "invalid topic", http.StatusBadRequest)
+		return
+	}
+	if !allowed(b.config.PublishAllow, topic) {
+		http.Error(w, "topic not allowed", http.StatusForbidden)
+		return
+	}
+	payload, err := io.ReadAll(http.MaxBytesReader(w, r.Body, b.config.MaxPayload))
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			http.Error(w, "payload too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := b.mc.Publish(topic, payload); err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (b *Bridge) handleSubscribe(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	filter := r.URL.Query().Get("filter")
+	if !broker.ValidFilter(filter) {
+		http.Error(w, "invalid filter", http.StatusBadRequest)
+		return
+	}
+	if !allowed(b.config.SubscribeAllow, filter) {
+		http.Error(w, "filter not allowed", http.StatusForbidden)
+		return
+	}
+
+	ch, err := b.addListener(filter)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer b.removeListener(filter, ch)
+
+	if websocket.IsWebSocketUpgrade(r) {
+		conn, err := b.upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		serveWebSocket(conn, ch)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	for {
+		select {
+		case m := <-ch:
+			data, _ := json.Marshal(m)
+			if _, err := fmt.Fprintf(w, "event: message\ndata: %s\n\n", data); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// addListener 第一个浏览器订阅某个过滤器时才向 broker 订阅
+func (b *Bridge) addListener(filter string) (chan Message, error) {
+	ch := make(chan Message, b.config.BufferSize)
+	b.subMu.Lock()
+	defer b.subMu.Unlock()
+
+	b.mu.Lock()
+	if sub, ok := b.subs[filter]; ok {
+		sub.listeners[ch] = struct{}{}
+		b.mu.Unlock()
+		return ch, nil
+	}
+	b.mu.Unlock()
+
+	// 等待 SUBACK 时不能持有 mu, 否则 dispatch 会阻塞 paho 的消息分发
+	err := b.mc.Subscribe(filter, func(client mqtt.Client, message mqtt.Message) {
+		b.dispatch(filter, newMessage(message))
+	})
+	if err != nil {
+		return nil, err
+	}
+	b.mu.Lock()
+	b.subs[filter] = &subscription{listeners: map[chan Message]struct{}{ch: {}}}
+	b.mu.Unlock()
+	return ch, nil
+}
+
+// removeListener 最后一个浏览器断开后取消 broker 订阅
+func (b *Bridge) removeListener(filter string, ch chan Message) {
+	b.subMu.Lock()
+	defer b.subMu.Unlock()
+
+	b.mu.Lock()
+	sub, ok := b.subs[filter]
+	if ok {
+		delete(sub.listeners, ch)
+		ok = len(sub.listeners) == 0
+		if ok {
+			delete(b.subs, filter)
+		}
+	}
+	b.mu.Unlock()
+	if ok {
+		if err := b.mc.Unsubscribe(filter); err != nil {
+			log.Printf("取消订阅[%s]失败: %v", filter, err)
+		}
+	}
+}
+
+func (b *Bridge) dispatch(filter string, m Message) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	sub, ok := b.subs[filter]
+	if !ok {
+		return
+	}
+	for ch := range sub.listeners {
+		// 浏览器消费太慢时丢弃消息, 不能阻塞 paho 的消息分发
+		select {
+		case ch <- m:
+		default:
+		}
+	}
+}
+
+func (b *Bridge) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if len(b.config.AllowedOrigins) == 0 {
+		u, err := url.Parse(origin)
+		return err == nil && strings.EqualFold(u.Host, r.Host)
+	}
+	for _, allowed := range b.config.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+func newMessage(message mqtt.Message) Message {
+	m := Message{
+		Topic:    message.Topic(),
+		Qos:      message.Qos(),
+		Retained: message.Retained(),
+	}
+	if payload := message.Payload(); utf8.Valid(payload) {
+		m.Payload = string(payload)
+	} else {
+		m.Payload = base64.StdEncoding.EncodeToString(payload)
+		m.Encoding = "base64"
+	}
+	return m
+}
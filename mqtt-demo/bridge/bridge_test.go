@@ -0,0 +1,138 @@
+package bridge
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"mqtt-demo/broker"
+	"mqtt-demo/config"
+)
+
+func TestCovers(t *testing.T) {
+	tests := []struct {
+		allow, filter string
+		expected      bool
+	}{
+		{"a/#", "a/b/c", true},
+		{"a/#", "a/+/c", true},
+		{"a/#", "a", true},
+		{"a/+", "a/b", true},
+		{"a/+", "a/#", false},
+		{"a/+", "a/b/c", false},
+		{"a/b", "a/+", false},
+		{"#", "x/y", true},
+	}
+	for _, tt := range tests {
+		if got := covers(tt.allow, tt.filter); got != tt.expected {
+			t.Errorf("covers(%q, %q) = %v; 期望值 %v", tt.allow, tt.filter, got, tt.expected)
+		}
+	}
+}
+
+func newTestBridge(t *testing.T) *httptest.Server {
+	t.Helper()
+	b, err := broker.StartLocal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { b.Close() })
+	mc := config.NewMqttClient(config.MqttConnectConfig{Broker: b.Host(), Port: b.Port(), ClientId: "bridge"})
+	t.Cleanup(mc.Close)
+	srv := httptest.NewServer(NewBridge(mc, Config{
+		PublishAllow:   []string{"devices/+/cmd"},
+		SubscribeAllow: []string{"devices/#"},
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestPublishAllowlist(t *testing.T) {
+	srv := newTestBridge(t)
+	tests := []struct {
+		name   string
+		topic  string
+		status int
+	}{
+		{"允许的主题", "devices/d1/cmd", http.StatusNoContent},
+		{"转义的主题", "devices%2Fd1%2Fcmd", http.StatusNoContent},
+		{"不允许的主题", "devices/d1/state", http.StatusForbidden},
+		{"通配符主题", "devices/+/cmd", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Post(srv.URL+"/publish/"+tt.topic, "text/plain", strings.NewReader("on"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.status {
+				t.Errorf("状态码 = %d; 期望值 %d", resp.StatusCode, tt.status)
+			}
+		})
+	}
+}
+
+func TestServerSentEvents(t *testing.T) {
+	srv := newTestBridge(t)
+	resp, err := http.Get(srv.URL + "/subscribe?filter=devices/%2B/cmd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("状态码 = %d", resp.StatusCode)
+	}
+
+	post, err := http.Post(srv.URL+"/publish/devices/d1/cmd", "text/plain", strings.NewReader("on"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	post.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+		var m Message
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &m); err != nil {
+			t.Fatal(err)
+		}
+		if m.Topic != "devices/d1/cmd" || m.Payload != "on" {
+			t.Errorf("收到消息 %+v", m)
+		}
+		return
+	}
+	t.Fatal("没有收到 SSE 消息")
+}
+
+func TestWebSocket(t *testing.T) {
+	srv := newTestBridge(t)
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/subscribe?filter=devices/%23", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	post, err := http.Post(srv.URL+"/publish/devices/d2/cmd", "text/plain", strings.NewReader("off"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	post.Body.Close()
+
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var m Message
+	if err := conn.ReadJSON(&m); err != nil {
+		t.Fatal(err)
+	}
+	if m.Topic != "devices/d2/cmd" || m.Payload != "off" {
+		t.Errorf("收到消息 %+v", m)
+	}
+}
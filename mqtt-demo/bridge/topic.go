@@ -0,0 +1,37 @@
+package bridge
+
+import "strings"
+
+// allowed 判断主题或过滤器是否被允许列表中的某个过滤器完全覆盖
+func allowed(allowList []string, filter string) bool {
+	for _, allow := range allowList {
+		if covers(allow, filter) {
+			return true
+		}
+	}
+	return false
+}
+
+// covers 判断 allow 匹配的主题集合是否包含 filter 匹配的所有主题,
+// 例如 a/# 覆盖 a/+/c, 但 a/+ 不覆盖 a/#
+func covers(allow, filter string) bool {
+	allowLevels := strings.Split(allow, "/")
+	filterLevels := strings.Split(filter, "/")
+	for i, level := range allowLevels {
+		if level == "#" {
+			return true
+		}
+		if i >= len(filterLevels) {
+			return false
+		}
+		switch {
+		case level == "+":
+			if filterLevels[i] == "#" {
+				return false
+			}
+		case level != filterLevels[i]:
+			return false
+		}
+	}
+	return len(allowLevels) == len(filterLevels)
+}
@@ -0,0 +1,533 @@
+package broker
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Message 经过 broker 转发的消息
+type Message struct {
+	Topic    string
+	Payload  []byte
+	Qos      byte
+	Retained bool
+}
+
+// Broker 进程内的轻量 MQTT 3.1.1 broker, 用于测试和本地开发.
+// 支持 QoS 0/1(QoS 2 的订阅降级为 1), 保留消息, 遗愿消息和通配符订阅,
+// 不保存离线会话.
+type Broker struct {
+	// Authenticate 校验连接的用户名密码, 为 nil 时允许所有连接
+	Authenticate func(clientId, username string, password []byte) bool
+	// MaxPacketSize 客户端报文剩余长度的上限, 超过时断开连接. 0 表示协议允许的最大值.
+	// CONNECT 报文的上限固定为 64KiB
+	MaxPacketSize int
+
+	listener net.Listener
+	mu       sync.RWMutex
+	clients  map[string]*client
+	pending  map[*client]struct{} // 还没有发送 CONNECT 的连接
+	retained map[string]Message
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+func New() *Broker {
+	return &Broker{
+		clients:  make(map[string]*client),
+		pending:  make(map[*client]struct{}),
+		retained: make(map[string]Message),
+	}
+}
+
+// StartLocal starts a broker on a random port of 127.0.0.1.
+func StartLocal() (*Broker, error) {
+	b := New()
+	if err := b.Start("127.0.0.1:0"); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Start listens on addr and serves connections in the background.
+func (b *Broker) Start(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	b.listener = listener
+	b.wg.Add(1)
+	go b.serve()
+	return nil
+}
+
+// Addr returns the listening address, e.g. 127.0.0.1:51234.
+func (b *Broker) Addr() string {
+	return b.listener.Addr().String()
+}
+
+// Host returns the listening host for MqttConnectConfig.Broker.
+func (b *Broker) Host() string {
+	host, _, _ := net.SplitHostPort(b.Addr())
+	return host
+}
+
+// Port returns the listening port for MqttConnectConfig.Port.
+func (b *Broker) Port() int32 {
+	_, port, _ := net.SplitHostPort(b.Addr())
+	p, _ := strconv.Atoi(port)
+	return int32(p)
+}
+
+// Close stops accepting connections, disconnects every client and waits for
+// their goroutines to exit.
+func (b *Broker) Close() error {
+	b.mu.Lock()
+	b.closed = true
+	clients := make([]*client, 0, len(b.clients)+len(b.pending))
+	for _, c := range b.clients {
+		clients = append(clients, c)
+	}
+	// 不等待还没有发送 CONNECT 的连接读超时
+	for c := range b.pending {
+		clients = append(clients, c)
+	}
+	b.mu.Unlock()
+
+	err := b.listener.Close()
+	for _, c := range clients {
+		c.close()
+	}
+	b.wg.Wait()
+	return err
+}
+
+func (b *Broker) serve() {
+	defer b.wg.Done()
+	for {
+		conn, err := b.listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Printf("broker accept error: %v", err)
+			}
+			return
+		}
+		b.wg.Add(1)
+		go func() {
+			defer b.wg.Done()
+			b.handleConn(conn)
+		}()
+	}
+}
+
+func (b *Broker) handleConn(conn net.Conn) {
+	c := newClient(b, conn)
+	defer c.close()
+
+	if !b.addPending(c) {
+		return
+	}
+	// CONNECT 必须是第一个报文, 认证之前只接受较小的报文
+	_ = conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	p, err := readPacket(c.reader, min(b.maxPacketSize(), maxConnectLength))
+	b.removePending(c)
+	if err != nil || p.typ != packetConnect {
+		return
+	}
+	if !c.connect(p) {
+		return
+	}
+	defer b.removeClient(c)
+	for {
+		if c.keepAlive > 0 {
+			_ = conn.SetReadDeadline(time.Now().Add(c.keepAlive * 3 / 2))
+		} else {
+			_ = conn.SetReadDeadline(time.Time{})
+		}
+		p, err := readPacket(c.reader, b.maxPacketSize())
+		if err != nil {
+			return
+		}
+		if !c.handle(p) {
+			return
+		}
+	}
+}
+
+func (b *Broker) maxPacketSize() int {
+	if b.MaxPacketSize <= 0 || b.MaxPacketSize > maxRemainingLength {
+		return maxRemainingLength
+	}
+	return b.MaxPacketSize
+}
+
+// addPending 记录还没有发送 CONNECT 的连接, broker 已经关闭时返回 false
+func (b *Broker) addPending(c *client) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return false
+	}
+	b.pending[c] = struct{}{}
+	return true
+}
+
+func (b *Broker) removePending(c *client) {
+	b.mu.Lock()
+	delete(b.pending, c)
+	b.mu.Unlock()
+}
+
+// register 保存新连接, 相同 clientId 的旧连接会被断开
+func (b *Broker) register(c *client) bool {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return false
+	}
+	old := b.clients[c.id]
+	b.clients[c.id] = c
+	b.mu.Unlock()
+	if old != nil {
+		old.close()
+	}
+	return true
+}
+
+func (b *Broker) removeClient(c *client) {
+	b.mu.Lock()
+	if b.clients[c.id] == c {
+		delete(b.clients, c.id)
+	}
+	b.mu.Unlock()
+}
+
+// publish 处理保留消息并分发给所有匹配的订阅者
+func (b *Broker) publish(m Message) {
+	b.mu.Lock()
+	if m.Retained {
+		if len(m.Payload) == 0 {
+			delete(b.retained, m.Topic)
+		} else {
+			b.retained[m.Topic] = m
+		}
+	}
+	clients := make([]*client, 0, len(b.clients))
+	for _, c := range b.clients {
+		clients = append(clients, c)
+	}
+	b.mu.Unlock()
+
+	// 转发给已有订阅者时保留标志置为 0
+	m.Retained = false
+	for _, c := range clients {
+		if qos, ok := c.matchQos(m.Topic); ok {
+			out := m
+			if out.Qos > qos {
+				out.Qos = qos
+			}
+			c.deliver(out)
+		}
+	}
+}
+
+func (b *Broker) retainedFor(filter string) []Message {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	var messages []Message
+	for topic, m := range b.retained {
+		if matchTopic(filter, topic) {
+			messages = append(messages, m)
+		}
+	}
+	return messages
+}
+
+func newClientId() string {
+	buf := make([]byte, 8)
+	_, _ = rand.Read(buf)
+	return "auto-" + hex.EncodeToString(buf)
+}
+
+// client 一个客户端连接
+type client struct {
+	broker    *Broker
+	conn      net.Conn
+	reader    *bufio.Reader
+	id        string
+	keepAlive time.Duration
+	will      *Message
+
+	mu         sync.Mutex
+	subs       map[string]byte // 订阅过滤器 -> 授予的 qos
+	nextPacket uint16
+
+	outgoing  chan []byte
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newClient(b *Broker, conn net.Conn) *client {
+	c := &client{
+		broker:   b,
+		conn:     conn,
+		reader:   bufio.NewReader(conn),
+		subs:     make(map[string]byte),
+		outgoing: make(chan []byte, 256),
+		done:     make(chan struct{}),
+	}
+	go c.writeLoop()
+	return c
+}
+
+func (c *client) writeLoop() {
+	for {
+		select {
+		case buf := <-c.outgoing:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			if _, err := c.conn.Write(buf); err != nil {
+				c.close()
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+func (c *client) send(buf []byte) {
+	select {
+	case c.outgoing <- buf:
+	case <-c.done:
+	}
+}
+
+// close 关闭连接, 没有收到 DISCONNECT 时发布遗愿消息
+func (c *client) close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		_ = c.conn.Close()
+		c.mu.Lock()
+		will := c.will
+		c.will = nil
+		c.mu.Unlock()
+		if will != nil {
+			c.broker.publish(*will)
+		}
+	})
+}
+
+func (c *client) connect(p packet) bool {
+	r := &reader{buf: p.body}
+	protocol := r.string()
+	level := r.byte()
+	flags := r.byte()
+	keepAlive := r.uint16()
+	if r.err != nil {
+		return false
+	}
+	if !(protocol == "MQTT" && level == 4) && !(protocol == "MQIsdp" && level == 3) {
+		c.sendConnack(connackBadProtocolVersion)
+		return false
+	}
+	id := r.string()
+	var will *Message
+	if flags&0x04 != 0 {
+		will = &Message{
+			Topic:    r.string(),
+			Payload:  r.bytes(),
+			Qos:      (flags >> 3) & 0x03,
+			Retained: flags&0x20 != 0,
+		}
+	}
+	var username string
+	var password []byte
+	if flags&0x80 != 0 {
+		username = r.string()
+	}
+	if flags&0x40 != 0 {
+		password = r.bytes()
+	}
+	if r.err != nil {
+		return false
+	}
+	if id == "" {
+		if flags&0x02 == 0 {
+			// 没有 clientId 时必须使用 clean session
+			c.sendConnack(connackIdentifierRejected)
+			return false
+		}
+		id = newClientId()
+	}
+	if auth := c.broker.Authenticate; auth != nil && !auth(id, username, password) {
+		c.sendConnack(connackBadUsernamePassword)
+		return false
+	}
+
+	c.id = id
+	c.keepAlive = time.Duration(keepAlive) * time.Second
+	c.will = will
+	if !c.broker.register(c) {
+		return false
+	}
+	c.sendConnack(connackAccepted)
+	return true
+}
+
+// sendConnack 直接写入连接, 保证拒绝连接时客户端能在断开前收到返回码
+func (c *client) sendConnack(code byte) {
+	_ = c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	_, _ = c.conn.Write(encodePacket(packetConnack, 0, []byte{0, code}))
+}
+
+// handle 处理一个报文, 返回 false 表示需要断开连接
+func (c *client) handle(p packet) bool {
+	switch p.typ {
+	case packetPublish:
+		return c.handlePublish(p)
+	case packetPubrel:
+		r := &reader{buf: p.body}
+		id := r.uint16()
+		if r.err != nil {
+			return false
+		}
+		c.send(encodeAck(packetPubcomp, 0, id))
+	case packetPuback, packetPubrec, packetPubcomp:
+		// 出站消息不重传, 确认报文直接忽略
+		if p.typ == packetPubrec {
+			r := &reader{buf: p.body}
+			c.send(encodeAck(packetPubrel, 0x02, r.uint16()))
+		}
+	case packetSubscribe:
+		return c.handleSubscribe(p)
+	case packetUnsubscribe:
+		return c.handleUnsubscribe(p)
+	case packetPingreq:
+		c.send(encodePacket(packetPingresp, 0, nil))
+	case packetDisconnect:
+		c.mu.Lock()
+		c.will = nil
+		c.mu.Unlock()
+		return false
+	default:
+		return false
+	}
+	return true
+}
+
+func (c *client) handlePublish(p packet) bool {
+	qos := (p.flags >> 1) & 0x03
+	r := &reader{buf: p.body}
+	topic := r.string()
+	var id uint16
+	if qos > 0 {
+		id = r.uint16()
+	}
+	payload := r.rest()
+	if r.err != nil || qos > 2 || !validTopic(topic) {
+		return false
+	}
+	c.broker.publish(Message{Topic: topic, Payload: payload, Qos: qos, Retained: p.flags&0x01 != 0})
+	switch qos {
+	case 1:
+		c.send(encodeAck(packetPuback, 0, id))
+	case 2:
+		c.send(encodeAck(packetPubrec, 0, id))
+	}
+	return true
+}
+
+func (c *client) handleSubscribe(p packet) bool {
+	r := &reader{buf: p.body}
+	id := r.uint16()
+	var filters []string
+	var codes []byte
+	for r.err == nil && len(r.buf) > 0 {
+		filter := r.string()
+		qos := r.byte()
+		if r.err != nil {
+			return false
+		}
+		if !ValidFilter(filter) || qos > 2 {
+			codes = append(codes, 0x80)
+			continue
+		}
+		if qos > 1 {
+			qos = 1
+		}
+		c.mu.Lock()
+		c.subs[filter] = qos
+		c.mu.Unlock()
+		filters = append(filters, filter)
+		codes = append(codes, qos)
+	}
+	if r.err != nil || len(codes) == 0 {
+		return false
+	}
+	c.send(encodePacket(packetSuback, 0, append(binary.BigEndian.AppendUint16(nil, id), codes...)))
+
+	for _, filter := range filters {
+		for _, m := range c.broker.retainedFor(filter) {
+			if qos, ok := c.matchQos(m.Topic); ok && m.Qos > qos {
+				m.Qos = qos
+			}
+			c.deliver(m)
+		}
+	}
+	return true
+}
+
+func (c *client) handleUnsubscribe(p packet) bool {
+	r := &reader{buf: p.body}
+	id := r.uint16()
+	for r.err == nil && len(r.buf) > 0 {
+		filter := r.string()
+		c.mu.Lock()
+		delete(c.subs, filter)
+		c.mu.Unlock()
+	}
+	if r.err != nil {
+		return false
+	}
+	c.send(encodeAck(packetUnsuback, 0, id))
+	return true
+}
+
+// matchQos 返回匹配主题的订阅中最大的 qos
+func (c *client) matchQos(topic string) (byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var qos byte
+	matched := false
+	for filter, granted := range c.subs {
+		if matchTopic(filter, topic) {
+			matched = true
+			if granted > qos {
+				qos = granted
+			}
+		}
+	}
+	return qos, matched
+}
+
+func (c *client) deliver(m Message) {
+	var id uint16
+	if m.Qos > 0 {
+		c.mu.Lock()
+		c.nextPacket++
+		if c.nextPacket == 0 {
+			c.nextPacket = 1
+		}
+		id = c.nextPacket
+		c.mu.Unlock()
+	}
+	c.send(encodePublish(m, id))
+}
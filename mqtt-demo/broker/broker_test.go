@@ -0,0 +1,197 @@
+package broker
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+// dial 使用原始报文建立连接, 便于模拟异常断开
+func dial(t *testing.T, b *Broker, id string, will *Message) (net.Conn, *bufio.Reader) {
+	t.Helper()
+	conn, err := net.Dial("tcp", b.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := appendString(nil, "MQTT")
+	flags := byte(0x02)
+	if will != nil {
+		flags |= 0x04 | will.Qos<<3
+	}
+	body = append(body, 4, flags, 0, 0)
+	body = appendString(body, id)
+	if will != nil {
+		body = appendString(body, will.Topic)
+		body = appendString(body, string(will.Payload))
+	}
+	if _, err := conn.Write(encodePacket(packetConnect, 0, body)); err != nil {
+		t.Fatal(err)
+	}
+	r := bufio.NewReader(conn)
+	p, err := readPacket(r, maxRemainingLength)
+	if err != nil || p.typ != packetConnack || p.body[1] != connackAccepted {
+		t.Fatalf("连接失败: %v %+v", err, p)
+	}
+	return conn, r
+}
+
+func subscribe(t *testing.T, conn net.Conn, r *bufio.Reader, filter string, qos byte) {
+	t.Helper()
+	body := append(appendString([]byte{0, 1}, filter), qos)
+	if _, err := conn.Write(encodePacket(packetSubscribe, 0x02, body)); err != nil {
+		t.Fatal(err)
+	}
+	p, err := readPacket(r, maxRemainingLength)
+	if err != nil || p.typ != packetSuback {
+		t.Fatalf("订阅失败: %v %+v", err, p)
+	}
+}
+
+func expectPublish(t *testing.T, conn net.Conn, r *bufio.Reader) Message {
+	t.Helper()
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	p, err := readPacket(r, maxRemainingLength)
+	if err != nil || p.typ != packetPublish {
+		t.Fatalf("没有收到消息: %v %+v", err, p)
+	}
+	pr := &reader{buf: p.body}
+	m := Message{Topic: pr.string(), Qos: (p.flags >> 1) & 0x03, Retained: p.flags&0x01 != 0}
+	if m.Qos > 0 {
+		pr.uint16()
+	}
+	m.Payload = pr.rest()
+	return m
+}
+
+func TestWillOnAbnormalDisconnect(t *testing.T) {
+	b, err := StartLocal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	watcher, r := dial(t, b, "watcher", nil)
+	defer watcher.Close()
+	subscribe(t, watcher, r, "status/+", 1)
+
+	device, _ := dial(t, b, "device", &Message{Topic: "status/device", Payload: []byte("offline"), Qos: 1})
+	// 不发送 DISCONNECT 直接断开
+	device.Close()
+
+	m := expectPublish(t, watcher, r)
+	if m.Topic != "status/device" || string(m.Payload) != "offline" || m.Qos != 1 {
+		t.Errorf("遗愿消息 = %+v", m)
+	}
+}
+
+func TestRetainedMessage(t *testing.T) {
+	b, err := StartLocal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	pub, _ := dial(t, b, "pub", nil)
+	defer pub.Close()
+	if _, err := pub.Write(encodePublish(Message{Topic: "config/a", Payload: []byte("v1"), Retained: true}, 0)); err != nil {
+		t.Fatal(err)
+	}
+	// 等待 broker 处理完发布
+	time.Sleep(50 * time.Millisecond)
+
+	sub, r := dial(t, b, "sub", nil)
+	defer sub.Close()
+	subscribe(t, sub, r, "config/#", 0)
+	m := expectPublish(t, sub, r)
+	if m.Topic != "config/a" || string(m.Payload) != "v1" || !m.Retained {
+		t.Errorf("保留消息 = %+v", m)
+	}
+}
+
+func TestReadPacketTooLarge(t *testing.T) {
+	// 剩余长度为 maxRemainingLength, 但没有报文体
+	header := encodePacket(packetPublish, 0, nil)[:1]
+	header = append(header, 0xff, 0xff, 0xff, 0x7f)
+	if _, err := readPacket(bufio.NewReader(bytes.NewReader(header)), 1024); !errors.Is(err, errPacketTooLarge) {
+		t.Errorf("readPacket 错误 = %v; 期望值 errPacketTooLarge", err)
+	}
+}
+
+func TestMaxPacketSize(t *testing.T) {
+	b := New()
+	b.MaxPacketSize = 64
+	if err := b.Start("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	conn, r := dial(t, b, "big", nil)
+	defer conn.Close()
+	if _, err := conn.Write(encodePublish(Message{Topic: "big", Payload: make([]byte, 128)}, 0)); err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	// 没有读取的报文体可能让关闭变成 RST, 只要求连接被关闭而不是读超时
+	var netErr net.Error
+	if _, err := readPacket(r, maxRemainingLength); err == nil || errors.As(err, &netErr) && netErr.Timeout() {
+		t.Errorf("发送超过上限的报文后 readPacket 错误 = %v; 期望连接被关闭", err)
+	}
+}
+
+func TestConnectTooLarge(t *testing.T) {
+	b, err := StartLocal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	conn, err := net.Dial("tcp", b.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	// 声明 1MiB 的 CONNECT 报文, 在读取报文体之前断开
+	if _, err := conn.Write([]byte{packetConnect << 4, 0x80, 0x80, 0x40}); err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var netErr net.Error
+	if _, err := conn.Read(make([]byte, 1)); err == nil || errors.As(err, &netErr) && netErr.Timeout() {
+		t.Errorf("发送超过上限的 CONNECT 后读取错误 = %v; 期望连接被关闭", err)
+	}
+}
+
+func TestCloseWithoutConnect(t *testing.T) {
+	b, err := StartLocal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := net.Dial("tcp", b.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	// 等待 broker 开始读取 CONNECT
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		b.mu.RLock()
+		n := len(b.pending)
+		b.mu.RUnlock()
+		if n == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("连接没有进入等待 CONNECT 的状态")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	start := time.Now()
+	b.Close()
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Close 耗时 %v; 没有发送 CONNECT 的连接应该立即关闭", elapsed)
+	}
+}
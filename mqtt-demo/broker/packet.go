@@ -0,0 +1,172 @@
+package broker
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// MQTT 3.1.1 控制报文类型
+const (
+	packetConnect     byte = 1
+	packetConnack     byte = 2
+	packetPublish     byte = 3
+	packetPuback      byte = 4
+	packetPubrec      byte = 5
+	packetPubrel      byte = 6
+	packetPubcomp     byte = 7
+	packetSubscribe   byte = 8
+	packetSuback      byte = 9
+	packetUnsubscribe byte = 10
+	packetUnsuback    byte = 11
+	packetPingreq     byte = 12
+	packetPingresp    byte = 13
+	packetDisconnect  byte = 14
+)
+
+// CONNACK 返回码
+const (
+	connackAccepted            byte = 0
+	connackBadProtocolVersion  byte = 1
+	connackIdentifierRejected  byte = 2
+	connackBadUsernamePassword byte = 4
+)
+
+const (
+	// maxRemainingLength 剩余长度字段能表示的最大值
+	maxRemainingLength = 268435455
+	// maxConnectLength CONNECT 报文剩余长度的上限, 足够容纳 clientId、遗愿消息和用户名密码,
+	// 避免未认证的连接让 broker 分配大块内存
+	maxConnectLength = 64 << 10
+)
+
+var (
+	errMalformedPacket = errors.New("broker: malformed packet")
+	errPacketTooLarge  = errors.New("broker: packet too large")
+)
+
+type packet struct {
+	typ   byte
+	flags byte
+	body  []byte
+}
+
+// readPacket 读取一个报文, 剩余长度超过 maxLength 时在分配内存之前返回 errPacketTooLarge
+func readPacket(r *bufio.Reader, maxLength int) (packet, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return packet{}, err
+	}
+	length, multiplier := 0, 1
+	for i := 0; ; i++ {
+		if i == 4 {
+			return packet{}, errMalformedPacket
+		}
+		b, err := r.ReadByte()
+		if err != nil {
+			return packet{}, err
+		}
+		length += int(b&0x7f) * multiplier
+		if b&0x80 == 0 {
+			break
+		}
+		multiplier *= 128
+	}
+	if length > maxLength {
+		return packet{}, errPacketTooLarge
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return packet{}, err
+	}
+	return packet{typ: header >> 4, flags: header & 0x0f, body: body}, nil
+}
+
+func encodePacket(typ, flags byte, body []byte) []byte {
+	buf := make([]byte, 0, len(body)+5)
+	buf = append(buf, typ<<4|flags)
+	length := len(body)
+	for {
+		b := byte(length % 128)
+		length /= 128
+		if length > 0 {
+			b |= 0x80
+		}
+		buf = append(buf, b)
+		if length == 0 {
+			break
+		}
+	}
+	return append(buf, body...)
+}
+
+func encodePublish(m Message, packetId uint16) []byte {
+	var flags byte
+	if m.Retained {
+		flags |= 0x01
+	}
+	flags |= m.Qos << 1
+	body := appendString(nil, m.Topic)
+	if m.Qos > 0 {
+		body = binary.BigEndian.AppendUint16(body, packetId)
+	}
+	body = append(body, m.Payload...)
+	return encodePacket(packetPublish, flags, body)
+}
+
+func encodeAck(typ byte, flags byte, packetId uint16) []byte {
+	return encodePacket(typ, flags, binary.BigEndian.AppendUint16(nil, packetId))
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(s)))
+	return append(buf, s...)
+}
+
+// reader 按 MQTT 的编码规则顺序读取报文体
+type reader struct {
+	buf []byte
+	err error
+}
+
+func (r *reader) byte() byte {
+	if r.err != nil || len(r.buf) < 1 {
+		r.err = errMalformedPacket
+		return 0
+	}
+	b := r.buf[0]
+	r.buf = r.buf[1:]
+	return b
+}
+
+func (r *reader) uint16() uint16 {
+	if r.err != nil || len(r.buf) < 2 {
+		r.err = errMalformedPacket
+		return 0
+	}
+	v := binary.BigEndian.Uint16(r.buf)
+	r.buf = r.buf[2:]
+	return v
+}
+
+func (r *reader) bytes() []byte {
+	n := int(r.uint16())
+	if r.err != nil || len(r.buf) < n {
+		r.err = errMalformedPacket
+		return nil
+	}
+	b := r.buf[:n]
+	r.buf = r.buf[n:]
+	return b
+}
+
+func (r *reader) string() string {
+	return string(r.bytes())
+}
+
+func (r *reader) rest() []byte {
+	b := r.buf
+	r.buf = nil
+	return b
+}
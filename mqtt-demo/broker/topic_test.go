@@ -0,0 +1,49 @@
+package broker
+
+import "testing"
+
+func TestMatchTopic(t *testing.T) {
+	tests := []struct {
+		filter, topic string
+		expected      bool
+	}{
+		{"a/b/c", "a/b/c", true},
+		{"a/b/c", "a/b", false},
+		{"a/+/c", "a/b/c", true},
+		{"a/+/c", "a/b/d", false},
+		{"a/+", "a/b/c", false},
+		{"a/#", "a/b/c", true},
+		{"a/#", "a", true},
+		{"#", "a/b", true},
+		{"+/+", "/b", true},
+		{"#", "$SYS/uptime", false},
+		{"+/uptime", "$SYS/uptime", false},
+		{"$SYS/#", "$SYS/uptime", true},
+	}
+	for _, tt := range tests {
+		if got := matchTopic(tt.filter, tt.topic); got != tt.expected {
+			t.Errorf("matchTopic(%q, %q) = %v; 期望值 %v", tt.filter, tt.topic, got, tt.expected)
+		}
+	}
+}
+
+func TestValidFilter(t *testing.T) {
+	tests := []struct {
+		filter   string
+		expected bool
+	}{
+		{"a/b", true},
+		{"a/+/b", true},
+		{"a/#", true},
+		{"#", true},
+		{"", false},
+		{"a/#/b", false},
+		{"a/b#", false},
+		{"a/b+/c", false},
+	}
+	for _, tt := range tests {
+		if got := ValidFilter(tt.filter); got != tt.expected {
+			t.Errorf("ValidFilter(%q) = %v; 期望值 %v", tt.filter, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"mqtt-demo/bridge"
+	"mqtt-demo/broker"
+	"mqtt-demo/config"
+	"net/http"
+	"strings"
+)
+
+func main() {
+	addr := flag.String("addr", ":8080", "HTTP 监听地址")
+	host := flag.String("broker", "broker.emqx.io", "MQTT broker 地址")
+	port := flag.Int("port", 1883, "MQTT broker 端口")
+	user := flag.String("user", "emqx", "MQTT 用户名")
+	password := flag.String("password", "public", "MQTT 密码")
+	local := flag.Bool("local", false, "使用进程内的 broker, 不连接公网")
+	publishAllow := flag.String("publish-allow", "", "允许发布的主题过滤器, 逗号分隔, 为空表示禁止发布")
+	subscribeAllow := flag.String("subscribe-allow", "", "允许订阅的主题过滤器, 逗号分隔, 为空表示禁止订阅")
+	origins := flag.String("origins", "", "WebSocket 允许的 Origin, 逗号分隔")
+	flag.Parse()
+
+	mqttConnectConfig := config.MqttConnectConfig{
+		Broker:   *host,
+		Port:     int32(*port),
+		ClientId: "go_mqtt_bridge",
+		User:     *user,
+		Password: *password,
+	}
+	if *local {
+		b, err := broker.StartLocal()
+		if err != nil {
+			log.Panic(err)
+		}
+		defer b.Close()
+		mqttConnectConfig.Broker = b.Host()
+		mqttConnectConfig.Port = b.Port()
+	}
+	mc := config.NewMqttClient(mqttConnectConfig)
+	defer mc.Close()
+
+	handler := bridge.NewBridge(mc, bridge.Config{
+		PublishAllow:   splitList(*publishAllow),
+		SubscribeAllow: splitList(*subscribeAllow),
+		AllowedOrigins: splitList(*origins),
+	})
+	log.Printf("MQTT 桥接服务监听 %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, handler))
+}
+
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
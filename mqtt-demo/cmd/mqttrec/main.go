@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"mqtt-demo/config"
+	"mqtt-demo/recorder"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+)
+
+const usage = `用法:
+  mqttrec record [flags] -topic a/# [-topic b/+ ...]   录制消息到 JSONL 文件
+  mqttrec replay [flags] -i record.jsonl               回放录制的消息`
+
+// topicList 支持多次指定 -topic
+type topicList []string
+
+func (t *topicList) String() string { return strings.Join(*t, ",") }
+
+func (t *topicList) Set(s string) error {
+	*t = append(*t, s)
+	return nil
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var err error
+	switch os.Args[1] {
+	case "record":
+		err = record(ctx, os.Args[2:])
+	case "replay":
+		err = replay(ctx, os.Args[2:])
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func connectFlags(fs *flag.FlagSet, clientId string) func() config.MqttConnectConfig {
+	host := fs.String("broker", "broker.emqx.io", "MQTT broker 地址")
+	port := fs.Int("port", 1883, "MQTT broker 端口")
+	user := fs.String("user", "emqx", "MQTT 用户名")
+	password := fs.String("password", "public", "MQTT 密码")
+	qos := fs.Int("qos", 1, "订阅使用的 qos")
+	return func() config.MqttConnectConfig {
+		return config.MqttConnectConfig{
+			Broker:   *host,
+			Port:     int32(*port),
+			ClientId: fmt.Sprintf("%s_%d", clientId, os.Getpid()),
+			User:     *user,
+			Password: *password,
+			Qos:      byte(*qos),
+		}
+	}
+}
+
+func record(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("record", flag.ExitOnError)
+	mqttConfig := connectFlags(fs, "mqttrec_record")
+	var topics topicList
+	fs.Var(&topics, "topic", "录制的主题过滤器, 可以指定多次")
+	output := fs.String("o", "", "输出文件, 默认 mqtt-<时间>.jsonl")
+	fs.Parse(args)
+	if len(topics) == 0 {
+		return fmt.Errorf("至少需要一个 -topic")
+	}
+	if *output == "" {
+		*output = fmt.Sprintf("mqtt-%s.jsonl", time.Now().Format("20060102-150405"))
+	}
+
+	file, err := os.OpenFile(*output, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	mc := config.NewMqttClient(mqttConfig())
+	defer mc.Close()
+	rec := recorder.NewRecorder(file)
+	if err := rec.Start(mc, topics...); err != nil {
+		return err
+	}
+	log.Printf("录制 %s 到 %s, Ctrl+C 结束", topics.String(), *output)
+	<-ctx.Done()
+	if err := mc.Unsubscribe(topics...); err != nil {
+		log.Printf("取消订阅失败: %v", err)
+	}
+	log.Printf("共录制 %d 条消息", rec.Count())
+	return rec.Close()
+}
+
+func replay(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	mqttConfig := connectFlags(fs, "mqttrec_replay")
+	input := fs.String("i", "", "录制文件")
+	speed := fs.Float64("speed", 1, "回放速度倍数, 0 表示不等待")
+	fs.Parse(args)
+	if *input == "" {
+		return fmt.Errorf("需要指定 -i 录制文件")
+	}
+
+	file, err := os.Open(*input)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	mc := config.NewMqttClient(mqttConfig())
+	defer mc.Close()
+	count, err := recorder.Replay(ctx, file, mc.PublishMessage, *speed)
+	log.Printf("共回放 %d 条消息", count)
+	return err
+}
@@ -0,0 +1,130 @@
+package codec
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+
+	"github.com/fxamacker/cbor/v2"
+	"google.golang.org/protobuf/proto"
+)
+
+// Codec 消息体的编解码
+type Codec interface {
+	Name() string
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// Compressor 消息体的压缩算法
+type Compressor interface {
+	Name() string
+	Compress(data []byte) ([]byte, error)
+	Decompress(data []byte) ([]byte, error)
+}
+
+var (
+	JSON     Codec      = jsonCodec{}
+	Protobuf Codec      = protobufCodec{}
+	CBOR     Codec      = cborCodec{}
+	Gzip     Compressor = NewGzip(DefaultMaxDecompressedSize)
+)
+
+// DefaultMaxDecompressedSize Gzip 解压后的最大字节数
+const DefaultMaxDecompressedSize = 16 << 20
+
+// ErrTooLarge 解压后的数据超过了上限
+var ErrTooLarge = errors.New("codec: decompressed payload too large")
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string { return "json" }
+
+func (jsonCodec) Marshal(v any) ([]byte, error) { return json.Marshal(v) }
+
+func (jsonCodec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+
+type cborCodec struct{}
+
+func (cborCodec) Name() string { return "cbor" }
+
+func (cborCodec) Marshal(v any) ([]byte, error) { return cbor.Marshal(v) }
+
+func (cborCodec) Unmarshal(data []byte, v any) error { return cbor.Unmarshal(data, v) }
+
+type protobufCodec struct{}
+
+func (protobufCodec) Name() string { return "protobuf" }
+
+func (protobufCodec) Marshal(v any) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("codec: %T is not a proto.Message", v)
+	}
+	return proto.Marshal(m)
+}
+
+// Unmarshal accepts a proto.Message, or a pointer to a nil proto.Message
+// pointer which is allocated before decoding (the *T of SubscribeTyped[T]).
+func (protobufCodec) Unmarshal(data []byte, v any) error {
+	if m, ok := v.(proto.Message); ok {
+		return proto.Unmarshal(data, m)
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Pointer && rv.Elem().Kind() == reflect.Pointer {
+		elem := rv.Elem()
+		if elem.IsNil() {
+			elem.Set(reflect.New(elem.Type().Elem()))
+		}
+		if m, ok := elem.Interface().(proto.Message); ok {
+			return proto.Unmarshal(data, m)
+		}
+	}
+	return fmt.Errorf("codec: %T is not a proto.Message", v)
+}
+
+// NewGzip returns a gzip Compressor whose Decompress fails with ErrTooLarge
+// when the data expands to more than maxSize bytes, which protects
+// subscribers against gzip bombs.
+func NewGzip(maxSize int64) Compressor {
+	return gzipCompressor{maxSize: maxSize}
+}
+
+type gzipCompressor struct {
+	maxSize int64 // 解压后的最大字节数
+}
+
+func (gzipCompressor) Name() string { return "gzip" }
+
+func (gzipCompressor) Compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (c gzipCompressor) Decompress(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	// 多读一个字节用于判断是否超过上限
+	out, err := io.ReadAll(io.LimitReader(r, c.maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(out)) > c.maxSize {
+		return nil, fmt.Errorf("%w: more than %d bytes", ErrTooLarge, c.maxSize)
+	}
+	return out, nil
+}
@@ -0,0 +1,102 @@
+package codec
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type reading struct {
+	Device string  `json:"device" cbor:"device"`
+	Value  float64 `json:"value" cbor:"value"`
+}
+
+func TestCodecRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		codec Codec
+	}{
+		{"json", JSON},
+		{"cbor", CBOR},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := reading{Device: "sensor-1", Value: 21.5}
+			data, err := tt.codec.Marshal(want)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got reading
+			if err := tt.codec.Unmarshal(data, &got); err != nil {
+				t.Fatal(err)
+			}
+			if got != want {
+				t.Errorf("Unmarshal = %+v; 期望值 %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestProtobufNilPointer(t *testing.T) {
+	data, err := Protobuf.Marshal(wrapperspb.String("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// SubscribeTyped[*wrapperspb.StringValue] 解码时传入的是 **StringValue
+	var got *wrapperspb.StringValue
+	if err := Protobuf.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !proto.Equal(got, wrapperspb.String("hello")) {
+		t.Errorf("Unmarshal = %v; 期望值 hello", got)
+	}
+	if _, err := Protobuf.Marshal(reading{}); err == nil {
+		t.Error("Marshal 非 proto.Message 应该返回错误")
+	}
+}
+
+func TestGzip(t *testing.T) {
+	data := []byte("payload payload payload payload")
+	compressed, err := Gzip.Compress(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Gzip.Decompress(compressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(data) {
+		t.Errorf("Decompress = %s; 期望值 %s", got, data)
+	}
+}
+
+func TestGzipLimit(t *testing.T) {
+	data := bytes.Repeat([]byte{0}, 1<<20)
+	compressed, err := Gzip.Compress(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		maxSize int64
+		wantErr error
+	}{
+		{"等于上限", 1 << 20, nil},
+		{"超过上限", 1<<20 - 1, ErrTooLarge},
+		{"上限很小", 1024, ErrTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewGzip(tt.maxSize).Decompress(compressed)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Decompress 错误 = %v; 期望值 %v", err, tt.wantErr)
+			}
+			if err == nil && len(got) != len(data) {
+				t.Errorf("Decompress 长度 = %d; 期望值 %d", len(got), len(data))
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package config
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sync"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// FullPolicy 分发队列满了之后的处理方式
+type FullPolicy int
+
+const (
+	FullBlock      FullPolicy = iota // 阻塞 paho 的分发协程, 对 broker 形成背压
+	FullDropNewest                   // 丢弃新收到的消息
+	FullDropOldest                   // 丢弃队列中最早的消息
+)
+
+// DispatchConfig 订阅的 worker 池配置
+type DispatchConfig struct {
+	Workers         int        // worker 数量, 默认 1
+	QueueSize       int        // 队列长度, 默认 64; 按主题有序时为每个 worker 的队列长度
+	OrderedPerTopic bool       // 同一主题的消息按顺序由同一个 worker 处理
+	Policy          FullPolicy // 队列满时的策略
+}
+
+type subscribeOptions struct {
+	dispatch *DispatchConfig
+}
+
+// SubscribeOption 配置 Subscribe/Subscribes
+type SubscribeOption func(o *subscribeOptions)
+
+// WithDispatcher runs the handler on a worker pool instead of paho's router
+// goroutine, so a slow handler doesn't stall the other subscriptions.
+func WithDispatcher(cfg DispatchConfig) SubscribeOption {
+	return func(o *subscribeOptions) {
+		o.dispatch = &cfg
+	}
+}
+
+// dispatcher 一个订阅的 worker 池
+type dispatcher struct {
+	mc        *MqttClient
+	handler   mqtt.MessageHandler
+	policy    FullPolicy
+	ordered   bool
+	queues    []chan dispatchItem
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+type dispatchItem struct {
+	client  mqtt.Client
+	message mqtt.Message
+}
+
+func newDispatcher(mc *MqttClient, handler mqtt.MessageHandler, cfg DispatchConfig) *dispatcher {
+	if cfg.Workers <= 0 {
+		cfg.Workers = 1
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 64
+	}
+	d := &dispatcher{
+		mc:      mc,
+		handler: handler,
+		policy:  cfg.Policy,
+		ordered: cfg.OrderedPerTopic,
+		done:    make(chan struct{}),
+	}
+	if d.ordered {
+		// 每个 worker 一个队列, 按主题哈希选择
+		d.queues = make([]chan dispatchItem, cfg.Workers)
+		for i := range d.queues {
+			d.queues[i] = make(chan dispatchItem, cfg.QueueSize)
+		}
+	} else {
+		// 所有 worker 共享一个队列
+		d.queues = []chan dispatchItem{make(chan dispatchItem, cfg.QueueSize)}
+	}
+	for i := 0; i < cfg.Workers; i++ {
+		go d.work(d.queues[i%len(d.queues)])
+	}
+	return d
+}
+
+// dispatch 作为 paho 的 MessageHandler, 把消息放入队列
+func (d *dispatcher) dispatch(client mqtt.Client, message mqtt.Message) {
+	queue := d.queues[0]
+	if d.ordered {
+		h := fnv.New32a()
+		h.Write([]byte(message.Topic()))
+		queue = d.queues[h.Sum32()%uint32(len(d.queues))]
+	}
+	item := dispatchItem{client: client, message: message}
+
+	switch d.policy {
+	case FullDropNewest:
+		select {
+		case queue <- item:
+		case <-d.done:
+		default:
+			d.dropped(message)
+		}
+	case FullDropOldest:
+		for {
+			select {
+			case queue <- item:
+				return
+			case <-d.done:
+				return
+			default:
+			}
+			select {
+			case old := <-queue:
+				d.dropped(old.message)
+			default:
+			}
+		}
+	default:
+		select {
+		case queue <- item:
+		case <-d.done:
+		}
+	}
+}
+
+func (d *dispatcher) dropped(message mqtt.Message) {
+	d.mc.metrics.messagesDropped.Add(1)
+	d.mc.emit(Event{Type: EventMessageDropped, Topic: message.Topic()})
+}
+
+func (d *dispatcher) work(queue chan dispatchItem) {
+	for {
+		select {
+		case item := <-queue:
+			d.handle(item)
+		case <-d.done:
+			return
+		}
+	}
+}
+
+func (d *dispatcher) handle(item dispatchItem) {
+	defer func() {
+		if r := recover(); r != nil {
+			d.mc.emit(Event{Type: EventHandlerPanic, Topic: item.message.Topic(), Err: fmt.Errorf("handler panic: %v", r)})
+		}
+	}()
+	d.handler(item.client, item.message)
+}
+
+// close 通知所有 worker 处理完当前消息后退出, 队列中未处理的消息会被丢弃.
+// 不等待 worker 退出, 因为 handler 中可能调用 Unsubscribe
+func (d *dispatcher) close() {
+	d.closeOnce.Do(func() {
+		close(d.done)
+	})
+}
@@ -0,0 +1,137 @@
+package config
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// EventType 连接生命周期事件的类型
+type EventType int
+
+const (
+	EventConnected      EventType = iota // 连接成功(包括重连成功)
+	EventConnectionLost                  // 连接断开
+	EventReconnecting                    // 开始一次重连尝试
+	EventResubscribed                    // 重连后重新订阅了已有主题
+	EventPublishFailed                   // 发布消息失败
+	EventMessageDropped                  // 分发队列已满, 消息被丢弃
+	EventHandlerPanic                    // 消息 handler 发生 panic
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventConnected:
+		return "connected"
+	case EventConnectionLost:
+		return "connection_lost"
+	case EventReconnecting:
+		return "reconnecting"
+	case EventResubscribed:
+		return "resubscribed"
+	case EventPublishFailed:
+		return "publish_failed"
+	case EventMessageDropped:
+		return "message_dropped"
+	case EventHandlerPanic:
+		return "handler_panic"
+	default:
+		return "unknown"
+	}
+}
+
+// Event 客户端的一个生命周期事件
+type Event struct {
+	Type   EventType
+	Time   time.Time
+	Topic  string   // 发布失败, 丢弃或 handler panic 的主题
+	Topics []string // 重新订阅的主题
+	Err    error
+}
+
+// EventHandler 接收 MqttClient 的事件, 在 paho 的协程中同步调用, 不能阻塞
+type EventHandler interface {
+	HandleEvent(event Event)
+}
+
+// EventHandlerFunc 把普通函数适配为 EventHandler
+type EventHandlerFunc func(event Event)
+
+func (f EventHandlerFunc) HandleEvent(event Event) {
+	f(event)
+}
+
+// EventChannel returns an EventHandler that forwards events to the returned
+// channel. Events are dropped when the channel buffer is full.
+func EventChannel(size int) (EventHandler, <-chan Event) {
+	ch := make(chan Event, size)
+	return EventHandlerFunc(func(event Event) {
+		select {
+		case ch <- event:
+		default:
+		}
+	}), ch
+}
+
+// Metrics MqttClient 的计数器快照
+type Metrics struct {
+	MessagesIn      uint64
+	MessagesOut     uint64
+	BytesIn         uint64
+	BytesOut        uint64
+	Reconnects      uint64 // 断开后重新连接成功的次数
+	PublishFailures uint64
+	MessagesDropped uint64        // 分发队列满时丢弃的消息
+	PublishCount    uint64        // 统计延迟的发布次数
+	PublishLatency  time.Duration // 平均发布延迟(等待 broker 确认)
+	MaxLatency      time.Duration
+}
+
+// metrics 内部使用原子操作的计数器
+type metrics struct {
+	messagesIn      atomic.Uint64
+	messagesOut     atomic.Uint64
+	bytesIn         atomic.Uint64
+	bytesOut        atomic.Uint64
+	reconnects      atomic.Uint64
+	publishFailures atomic.Uint64
+	messagesDropped atomic.Uint64
+	publishCount    atomic.Uint64
+	latencyTotal    atomic.Int64
+	latencyMax      atomic.Int64
+}
+
+func (m *metrics) received(size int) {
+	m.messagesIn.Add(1)
+	m.bytesIn.Add(uint64(size))
+}
+
+func (m *metrics) published(size int, latency time.Duration) {
+	m.messagesOut.Add(1)
+	m.bytesOut.Add(uint64(size))
+	m.publishCount.Add(1)
+	m.latencyTotal.Add(int64(latency))
+	for {
+		max := m.latencyMax.Load()
+		if int64(latency) <= max || m.latencyMax.CompareAndSwap(max, int64(latency)) {
+			return
+		}
+	}
+}
+
+func (m *metrics) snapshot() Metrics {
+	s := Metrics{
+		MessagesIn:      m.messagesIn.Load(),
+		MessagesOut:     m.messagesOut.Load(),
+		BytesIn:         m.bytesIn.Load(),
+		BytesOut:        m.bytesOut.Load(),
+		Reconnects:      m.reconnects.Load(),
+		PublishFailures: m.publishFailures.Load(),
+		MessagesDropped: m.messagesDropped.Load(),
+		PublishCount:    m.publishCount.Load(),
+		MaxLatency:      time.Duration(m.latencyMax.Load()),
+	}
+	if s.PublishCount > 0 {
+		s.PublishLatency = time.Duration(m.latencyTotal.Load() / int64(s.PublishCount))
+	}
+	return s
+}
@@ -6,6 +6,8 @@ import (
 	"fmt"
 	mqtt "github.com/eclipse/paho.mqtt.golang"
 	"log"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -18,21 +20,31 @@ type MqttConnectConfig struct {
 	Certificate      string // 证书文件
 	PrivateKey       string // 密钥
 	ClientId         string
-	WillEnabled      bool   // 遗愿
-	WillTopic        string // 遗愿主题
-	WillPayload      string // 遗愿消息
-	WillQos          byte   //遗愿服务质量
-	Qos              byte   // 服务质量
-	Retained         bool   // 保留消息
+	WillEnabled      bool          // 遗愿
+	WillTopic        string        // 遗愿主题
+	WillPayload      string        // 遗愿消息
+	WillQos          byte          //遗愿服务质量
+	Qos              byte          // 服务质量
+	Retained         bool          // 保留消息
+	RequestTimeout   time.Duration // Request/HandleRequests 的默认超时时间
+	EventHandler     EventHandler  // 连接生命周期事件, 可以使用 EventChannel 转为 channel
 	OnConnect        mqtt.OnConnectHandler
 	OnConnectionLost mqtt.ConnectionLostHandler
 }
 
 type MqttClient struct {
-	qos      byte
-	retained bool
-	Client   mqtt.Client
-	topics   map[string]mqtt.MessageHandler
+	qos            byte
+	retained       bool
+	clientId       string
+	requestTimeout time.Duration
+	Client         mqtt.Client
+	topicsMu       sync.RWMutex
+	topics         map[string]mqtt.MessageHandler
+	dispatchers    map[string]*dispatcher
+	rpc            rpcState
+	events         EventHandler
+	metrics        metrics
+	connectedOnce  atomic.Bool
 }
 
 // 新建证书,也可以不用
@@ -75,11 +87,25 @@ func NewMqttClient(config MqttConnectConfig) *MqttClient {
 
 		}
 	}
-	opts.SetOnConnectHandler(c.connectHandler(config.OnConnect)).SetConnectionLostHandler(config.OnConnectionLost)
+	c.events = config.EventHandler
+	if c.events == nil {
+		c.events = EventHandlerFunc(func(event Event) {})
+	}
+	opts.SetOnConnectHandler(c.connectHandler(config.OnConnect)).
+		SetConnectionLostHandler(c.onConnectionLostHandler(config.OnConnectionLost)).
+		SetReconnectingHandler(func(client mqtt.Client, options *mqtt.ClientOptions) {
+			c.emit(Event{Type: EventReconnecting})
+		})
 	c.Client = mqtt.NewClient(opts)
 	c.qos = config.Qos                              // qos的级别
 	c.retained = config.Retained                    // 保留消息
 	c.topics = make(map[string]mqtt.MessageHandler) //topic
+	c.dispatchers = make(map[string]*dispatcher)
+	c.clientId = config.ClientId
+	c.requestTimeout = config.RequestTimeout
+	if c.requestTimeout <= 0 {
+		c.requestTimeout = defaultRequestTimeout
+	}
 	if tc := c.Client.Connect(); tc.Wait() && tc.Error() != nil {
 		log.Panic(tc.Error())
 		return nil
@@ -89,10 +115,26 @@ func NewMqttClient(config MqttConnectConfig) *MqttClient {
 
 func (mc *MqttClient) connectHandler(handler mqtt.OnConnectHandler) mqtt.OnConnectHandler {
 	return func(c mqtt.Client) {
+		if mc.connectedOnce.Swap(true) {
+			mc.metrics.reconnects.Add(1)
+		}
+		mc.emit(Event{Type: EventConnected})
 
+		mc.topicsMu.RLock()
+		topics := make(map[string]mqtt.MessageHandler, len(mc.topics))
 		for topic, onMessage := range mc.topics {
-			fmt.Printf("Connected:%s", topic)
-			mc.Client.Subscribe(topic, mc.qos, onMessage)
+			topics[topic] = onMessage
+		}
+		mc.topicsMu.RUnlock()
+		if len(topics) > 0 {
+			event := Event{Type: EventResubscribed}
+			for topic, onMessage := range topics {
+				event.Topics = append(event.Topics, topic)
+				if tc := mc.Client.Subscribe(topic, mc.qos, onMessage); tc.Wait() && tc.Error() != nil && event.Err == nil {
+					event.Err = tc.Error()
+				}
+			}
+			mc.emit(event)
 		}
 		handler(c)
 	}
@@ -100,41 +142,101 @@ func (mc *MqttClient) connectHandler(handler mqtt.OnConnectHandler) mqtt.OnConne
 
 func (mc *MqttClient) onConnectionLostHandler(handler mqtt.ConnectionLostHandler) mqtt.ConnectionLostHandler {
 	return func(c mqtt.Client, e error) {
-		fmt.Printf("Connected lost:%v", e)
+		mc.emit(Event{Type: EventConnectionLost, Err: e})
 		handler(c, e)
 	}
 }
 
+func (mc *MqttClient) emit(event Event) {
+	event.Time = time.Now()
+	mc.events.HandleEvent(event)
+}
+
+// Metrics returns a snapshot of the client's message, byte, reconnect and
+// publish latency counters.
+func (mc *MqttClient) Metrics() Metrics {
+	return mc.metrics.snapshot()
+}
+
+// publish 所有发布路径共用, 统计发布延迟并在失败时发送事件
+func (mc *MqttClient) publish(topic string, qos byte, retained bool, payload []byte) error {
+	if mc == nil || !mc.Client.IsConnected() {
+		err := errors.New("mqttClient is nil or disconnected")
+		if mc != nil {
+			mc.metrics.publishFailures.Add(1)
+			mc.emit(Event{Type: EventPublishFailed, Topic: topic, Err: err})
+		}
+		return err
+	}
+	start := time.Now()
+	if tc := mc.Client.Publish(topic, qos, retained, payload); tc.Wait() && tc.Error() != nil {
+		mc.metrics.publishFailures.Add(1)
+		mc.emit(Event{Type: EventPublishFailed, Topic: topic, Err: tc.Error()})
+		return tc.Error()
+	}
+	mc.metrics.published(len(payload), time.Since(start))
+	return nil
+}
+
+// received 包装订阅的 handler, 统计收到的消息
+func (mc *MqttClient) received(onMessage mqtt.MessageHandler) mqtt.MessageHandler {
+	return func(client mqtt.Client, message mqtt.Message) {
+		mc.metrics.received(len(message.Payload()))
+		onMessage(client, message)
+	}
+}
+
 // Publish  Mqtt message.
 func (mc *MqttClient) Publish(topic string, payload []byte) error {
-	if mc != nil && mc.Client.IsConnected() {
-		if tc := mc.Client.Publish(topic, mc.qos, mc.retained, payload); tc.Wait() && tc.Error() != nil {
-			return tc.Error()
-		}
-		return nil
+	if mc == nil {
+		return errors.New("mqttClient is nil or disconnected")
 	}
-	return errors.New("mqttClient is nil or disconnected")
+	return mc.publish(topic, mc.qos, mc.retained, payload)
+}
+
+// PublishMessage publish a Mqtt message with its own qos and retained flag.
+func (mc *MqttClient) PublishMessage(topic string, qos byte, retained bool, payload []byte) error {
+	return mc.publish(topic, qos, retained, payload)
 }
 
 // Subscribes subscribe a Mqtt topic
-func (mc *MqttClient) Subscribes(topics []string, onMessage mqtt.MessageHandler) error {
+func (mc *MqttClient) Subscribes(topics []string, onMessage mqtt.MessageHandler, opts ...SubscribeOption) error {
 	for _, topic := range topics {
-		if tc := mc.Client.Subscribe(topic, mc.qos, onMessage); tc.Wait() && tc.Error() != nil {
-			return tc.Error()
+		if err := mc.Subscribe(topic, onMessage, opts...); err != nil {
+			return err
 		}
-		mc.topics[topic] = onMessage
-		log.Println(fmt.Sprintf("订阅主题[%s]成功", topic))
 	}
 	return nil
 }
 
-// Subscribe subscribe a Mqtt topic
-func (mc *MqttClient) Subscribe(topic string, onMessage mqtt.MessageHandler) error {
-
+// Subscribe subscribe a Mqtt topic, WithDispatcher moves the handler off paho's router goroutine
+func (mc *MqttClient) Subscribe(topic string, onMessage mqtt.MessageHandler, opts ...SubscribeOption) error {
+	var o subscribeOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	var d *dispatcher
+	if o.dispatch != nil {
+		d = newDispatcher(mc, onMessage, *o.dispatch)
+		onMessage = d.dispatch
+	}
+	onMessage = mc.received(onMessage)
 	if tc := mc.Client.Subscribe(topic, mc.qos, onMessage); tc.Wait() && tc.Error() != nil {
+		if d != nil {
+			d.close()
+		}
 		return tc.Error()
 	}
+	mc.topicsMu.Lock()
 	mc.topics[topic] = onMessage
+	if old, ok := mc.dispatchers[topic]; ok {
+		old.close()
+		delete(mc.dispatchers, topic)
+	}
+	if d != nil {
+		mc.dispatchers[topic] = d
+	}
+	mc.topicsMu.Unlock()
 	log.Println(fmt.Sprintf("订阅主题[%s]成功", topic))
 	return nil
 }
@@ -144,12 +246,24 @@ func (mc *MqttClient) Unsubscribe(topics ...string) error {
 	if tc := mc.Client.Unsubscribe(topics...); tc.Wait() && tc.Error() != nil {
 		return tc.Error()
 	}
+	mc.topicsMu.Lock()
 	for _, topic := range topics {
 		delete(mc.topics, topic)
+		if d, ok := mc.dispatchers[topic]; ok {
+			d.close()
+			delete(mc.dispatchers, topic)
+		}
 	}
+	mc.topicsMu.Unlock()
 	return nil
 }
 
 func (mc *MqttClient) Close() {
 	mc.Client.Disconnect(250) // millisecond
+	mc.topicsMu.Lock()
+	for topic, d := range mc.dispatchers {
+		d.close()
+		delete(mc.dispatchers, topic)
+	}
+	mc.topicsMu.Unlock()
 }
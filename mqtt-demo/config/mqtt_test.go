@@ -0,0 +1,277 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"mqtt-demo/broker"
+)
+
+func startBroker(t *testing.T) *broker.Broker {
+	t.Helper()
+	b, err := broker.StartLocal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { b.Close() })
+	return b
+}
+
+func newTestClient(t *testing.T, b *broker.Broker, clientId string) *MqttClient {
+	t.Helper()
+	mc := NewMqttClient(MqttConnectConfig{
+		Broker:         b.Host(),
+		Port:           b.Port(),
+		ClientId:       clientId,
+		Qos:            1,
+		RequestTimeout: time.Second,
+	})
+	t.Cleanup(mc.Close)
+	return mc
+}
+
+func receive(t *testing.T, ch <-chan mqtt.Message) mqtt.Message {
+	t.Helper()
+	select {
+	case m := <-ch:
+		return m
+	case <-time.After(2 * time.Second):
+		t.Fatal("等待消息超时")
+		return nil
+	}
+}
+
+func TestPublishSubscribe(t *testing.T) {
+	b := startBroker(t)
+	sub := newTestClient(t, b, "sub")
+	pub := newTestClient(t, b, "pub")
+
+	ch := make(chan mqtt.Message, 1)
+	if err := sub.Subscribe("sensors/+/temp", func(client mqtt.Client, message mqtt.Message) {
+		ch <- message
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := pub.Publish("sensors/kitchen/temp", []byte("21")); err != nil {
+		t.Fatal(err)
+	}
+	m := receive(t, ch)
+	if m.Topic() != "sensors/kitchen/temp" || string(m.Payload()) != "21" {
+		t.Errorf("收到消息 %s %s", m.Topic(), m.Payload())
+	}
+}
+
+func TestRequest(t *testing.T) {
+	b := startBroker(t)
+	server := newTestClient(t, b, "server")
+	client := newTestClient(t, b, "client")
+
+	if err := server.HandleRequests("svc/echo", func(ctx context.Context, payload []byte) ([]byte, error) {
+		if string(payload) == "fail" {
+			return nil, errors.New("bad request")
+		}
+		return append([]byte("echo:"), payload...), nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.HandleRequests("svc/slow", func(ctx context.Context, payload []byte) ([]byte, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	reply, err := client.Request(context.Background(), "svc/echo", []byte("hi"))
+	if err != nil || string(reply) != "echo:hi" {
+		t.Errorf("Request = %s, %v; 期望值 echo:hi", reply, err)
+	}
+	var remote *RemoteError
+	if _, err := client.Request(context.Background(), "svc/echo", []byte("fail")); !errors.As(err, &remote) {
+		t.Errorf("Request 错误 = %v; 期望 RemoteError", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if _, err := client.Request(ctx, "svc/slow", nil); !errors.Is(err, ErrRequestTimeout) {
+		t.Errorf("Request 错误 = %v; 期望 ErrRequestTimeout", err)
+	}
+}
+
+func TestTypedJSON(t *testing.T) {
+	type reading struct {
+		Device string  `json:"device"`
+		Value  float64 `json:"value"`
+	}
+	b := startBroker(t)
+	sub := newTestClient(t, b, "sub")
+	pub := newTestClient(t, b, "pub")
+
+	ch := make(chan reading, 1)
+	decodeErrors := make(chan error, 1)
+	err := SubscribeTyped(sub, "readings", func(message mqtt.Message, v reading) {
+		ch <- v
+	}, OnDecodeError(func(message mqtt.Message, err error) {
+		decodeErrors <- err
+	}), WithSubscribeOptions(WithDispatcher(DispatchConfig{Workers: 2})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub.topicsMu.RLock()
+	_, dispatched := sub.dispatchers["readings"]
+	sub.topicsMu.RUnlock()
+	if !dispatched {
+		t.Error("SubscribeTyped 没有使用 WithDispatcher")
+	}
+
+	if err := pub.Publish("readings", []byte("not json")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-decodeErrors:
+	case <-time.After(2 * time.Second):
+		t.Fatal("解码失败没有调用 OnDecodeError")
+	}
+
+	want := reading{Device: "d1", Value: 1.5}
+	if err := PublishJSON(pub, "readings", want); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case got := <-ch:
+		if got != want {
+			t.Errorf("收到 %+v; 期望值 %+v", got, want)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("等待消息超时")
+	}
+}
+
+func waitEvent(t *testing.T, events <-chan Event, typ EventType) Event {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case event := <-events:
+			if event.Type == typ {
+				return event
+			}
+		case <-timeout:
+			t.Fatalf("等待 %s 事件超时", typ)
+			return Event{}
+		}
+	}
+}
+
+func TestEventsAndMetrics(t *testing.T) {
+	b := startBroker(t)
+	addr := b.Addr()
+	handler, events := EventChannel(32)
+	mc := NewMqttClient(MqttConnectConfig{
+		Broker:       b.Host(),
+		Port:         b.Port(),
+		ClientId:     "events",
+		Qos:          1,
+		EventHandler: handler,
+	})
+	defer mc.Close()
+	waitEvent(t, events, EventConnected)
+
+	ch := make(chan mqtt.Message, 1)
+	if err := mc.Subscribe("metrics/test", func(client mqtt.Client, message mqtt.Message) {
+		ch <- message
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := mc.Publish("metrics/test", []byte("12345")); err != nil {
+		t.Fatal(err)
+	}
+	receive(t, ch)
+	m := mc.Metrics()
+	if m.MessagesOut != 1 || m.BytesOut != 5 || m.MessagesIn != 1 || m.BytesIn != 5 || m.PublishCount != 1 {
+		t.Errorf("Metrics = %+v", m)
+	}
+
+	// 重启 broker, 客户端应该自动重连并重新订阅
+	b.Close()
+	waitEvent(t, events, EventConnectionLost)
+	restarted := broker.New()
+	if err := restarted.Start(addr); err != nil {
+		t.Fatal(err)
+	}
+	defer restarted.Close()
+	waitEvent(t, events, EventReconnecting)
+	waitEvent(t, events, EventConnected)
+	resubscribed := waitEvent(t, events, EventResubscribed)
+	if resubscribed.Err != nil || len(resubscribed.Topics) != 1 || resubscribed.Topics[0] != "metrics/test" {
+		t.Errorf("重新订阅事件 = %+v", resubscribed)
+	}
+	if got := mc.Metrics().Reconnects; got != 1 {
+		t.Errorf("Reconnects = %d; 期望值 1", got)
+	}
+}
+
+func TestDispatcher(t *testing.T) {
+	b := startBroker(t)
+	handler, events := EventChannel(64)
+	mc := NewMqttClient(MqttConnectConfig{
+		Broker:       b.Host(),
+		Port:         b.Port(),
+		ClientId:     "dispatcher",
+		Qos:          1,
+		EventHandler: handler,
+	})
+	defer mc.Close()
+
+	// 慢 handler 使用 worker 池, 不应该阻塞其他主题
+	release := make(chan struct{})
+	defer close(release)
+	started := make(chan struct{}, 1)
+	if err := mc.Subscribe("slow", func(client mqtt.Client, message mqtt.Message) {
+		if string(message.Payload()) == "panic" {
+			panic("boom")
+		}
+		started <- struct{}{}
+		<-release
+	}, WithDispatcher(DispatchConfig{Workers: 1, QueueSize: 1, Policy: FullDropNewest})); err != nil {
+		t.Fatal(err)
+	}
+	fast := make(chan mqtt.Message, 1)
+	if err := mc.Subscribe("fast", func(client mqtt.Client, message mqtt.Message) {
+		fast <- message
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mc.Publish("slow", []byte("panic")); err != nil {
+		t.Fatal(err)
+	}
+	event := waitEvent(t, events, EventHandlerPanic)
+	if event.Topic != "slow" || event.Err == nil {
+		t.Errorf("panic 事件 = %+v", event)
+	}
+
+	// 第一条消息占用 worker, 第二条进入队列, 第三条被丢弃
+	if err := mc.Publish("slow", []byte("block")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-started:
+	case <-time.After(2 * time.Second):
+		t.Fatal("worker 没有开始处理消息")
+	}
+	for i := 0; i < 2; i++ {
+		if err := mc.Publish("slow", []byte("block")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := mc.Publish("fast", []byte("ok")); err != nil {
+		t.Fatal(err)
+	}
+	receive(t, fast)
+	waitEvent(t, events, EventMessageDropped)
+	if got := mc.Metrics().MessagesDropped; got != 1 {
+		t.Errorf("MessagesDropped = %d; 期望值 1", got)
+	}
+}
@@ -0,0 +1,435 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/eclipse/paho.golang/autopaho"
+	"github.com/eclipse/paho.golang/paho"
+)
+
+// MqttV5ConnectConfig MQTT 5 连接的相关配置
+type MqttV5ConnectConfig struct {
+	Broker            string
+	Port              int32
+	User              string
+	Password          string
+	Certificate       string // 证书文件
+	PrivateKey        string // 密钥
+	ClientId          string
+	WillEnabled       bool          // 遗愿
+	WillTopic         string        // 遗愿主题
+	WillPayload       string        // 遗愿消息
+	WillQos           byte          // 遗愿服务质量
+	Qos               byte          // 服务质量
+	Retained          bool          // 保留消息
+	KeepAlive         uint16        // 心跳间隔(秒), 默认 30
+	SessionExpiry     uint32        // 会话过期时间(秒), 0 表示断开即结束会话
+	TopicAliasMaximum uint16        // 客户端发布 QoS 0 消息时最多使用的主题别名数量, 0 表示不使用
+	ConnectTimeout    time.Duration // 首次连接的超时时间, 默认 10s
+	RequestTimeout    time.Duration // Request/HandleRequests 的默认超时时间
+	OnConnect         func(connack *paho.Connack)
+	OnConnectionLost  func(err error)
+}
+
+// MqttV5Client 与 MqttClient 相同的 Publish/Subscribe 接口, 基于 MQTT 5 协议
+type MqttV5Client struct {
+	qos            byte
+	retained       bool
+	requestTimeout time.Duration
+	Client         *autopaho.ConnectionManager
+	router         *paho.StandardRouter
+	topicsMu       sync.RWMutex
+	topics         map[string]paho.MessageHandler
+	aliases        topicAliases
+	rpc            *MqttV5Rpc
+}
+
+// ReasonCodeError broker 在 PUBACK/SUBACK/UNSUBACK 中返回了失败的原因码(>= 0x80)
+type ReasonCodeError struct {
+	Code   byte
+	Reason string
+}
+
+func (e *ReasonCodeError) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("mqtt reason code 0x%02x", e.Code)
+	}
+	return fmt.Sprintf("mqtt reason code 0x%02x: %s", e.Code, e.Reason)
+}
+
+// PublishOption 设置 MQTT 5 发布消息的属性
+type PublishOption func(p *paho.Publish)
+
+// WithUserProperty adds a user property to the published message.
+func WithUserProperty(key, value string) PublishOption {
+	return func(p *paho.Publish) {
+		p.Properties.User.Add(key, value)
+	}
+}
+
+// WithMessageExpiry sets how long the broker keeps the message for offline subscribers.
+func WithMessageExpiry(expiry time.Duration) PublishOption {
+	return func(p *paho.Publish) {
+		seconds := uint32(expiry / time.Second)
+		p.Properties.MessageExpiry = &seconds
+	}
+}
+
+// WithContentType sets the content type property of the message.
+func WithContentType(contentType string) PublishOption {
+	return func(p *paho.Publish) {
+		p.Properties.ContentType = contentType
+	}
+}
+
+// WithQos overrides the client's default qos for one message.
+func WithQos(qos byte) PublishOption {
+	return func(p *paho.Publish) {
+		p.QoS = qos
+	}
+}
+
+// WithRetained overrides the client's default retained flag for one message.
+func WithRetained(retained bool) PublishOption {
+	return func(p *paho.Publish) {
+		p.Retain = retained
+	}
+}
+
+// SharedTopic returns the shared subscription filter $share/group/topic.
+func SharedTopic(group, topic string) string {
+	return fmt.Sprintf("$share/%s/%s", group, topic)
+}
+
+func NewMqttV5Client(config MqttV5ConnectConfig) *MqttV5Client {
+	c := &MqttV5Client{
+		qos:            config.Qos,
+		retained:       config.Retained,
+		requestTimeout: config.RequestTimeout,
+		router:         paho.NewStandardRouter(),
+		topics:         make(map[string]paho.MessageHandler),
+	}
+	if c.requestTimeout <= 0 {
+		c.requestTimeout = defaultRequestTimeout
+	}
+	c.rpc = newMqttV5Rpc(config.ClientId, config.Qos, c.requestTimeout, c.publishPacket, c.Subscribe)
+	if config.KeepAlive == 0 {
+		config.KeepAlive = 30
+	}
+	if config.ConnectTimeout <= 0 {
+		config.ConnectTimeout = 10 * time.Second
+	}
+	if config.OnConnect == nil {
+		config.OnConnect = func(connack *paho.Connack) {}
+	}
+	if config.OnConnectionLost == nil {
+		config.OnConnectionLost = func(err error) {}
+	}
+
+	scheme := "mqtt"
+	cfg := autopaho.ClientConfig{
+		KeepAlive:                     config.KeepAlive,
+		CleanStartOnInitialConnection: config.SessionExpiry == 0,
+		SessionExpiryInterval:         config.SessionExpiry,
+		ReconnectBackoff:              autopaho.NewConstantBackoff(time.Second * 5),
+		ConnectTimeout:                config.ConnectTimeout,
+		OnConnectionUp: func(cm *autopaho.ConnectionManager, connack *paho.Connack) {
+			c.onConnectionUp(cm, connack, config.TopicAliasMaximum)
+			config.OnConnect(connack)
+		},
+		OnConnectError: func(err error) {
+			log.Printf("MQTT5 连接失败: %v", err)
+		},
+		ClientConfig: paho.ClientConfig{
+			ClientID: config.ClientId,
+			OnPublishReceived: []func(paho.PublishReceived) (bool, error){
+				func(pr paho.PublishReceived) (bool, error) {
+					c.router.Route(pr.Packet.Packet())
+					return true, nil
+				},
+			},
+			OnClientError: func(err error) {
+				c.aliases.reset(0)
+				config.OnConnectionLost(err)
+			},
+			OnServerDisconnect: func(d *paho.Disconnect) {
+				c.aliases.reset(0)
+				config.OnConnectionLost(disconnectError(d))
+			},
+		},
+	}
+	// 判断是否设置证书
+	if config.Certificate != "" {
+		tlsConfig, err := newTLSConfig(config.Certificate, config.PrivateKey)
+		if err != nil {
+			log.Panic(err)
+			return nil
+		}
+		cfg.TlsCfg = tlsConfig
+		scheme = "tls"
+	} else {
+		cfg.ConnectUsername = config.User
+		cfg.ConnectPassword = []byte(config.Password)
+	}
+	serverUrl, err := url.Parse(fmt.Sprintf("%s://%s:%d", scheme, config.Broker, config.Port))
+	if err != nil {
+		log.Panic(err)
+		return nil
+	}
+	cfg.ServerUrls = []*url.URL{serverUrl}
+	if config.WillEnabled {
+		cfg.SetWillMessage(config.WillTopic, []byte(config.WillPayload), config.WillQos, config.Retained)
+	}
+
+	cm, err := autopaho.NewConnection(context.Background(), cfg)
+	if err != nil {
+		log.Panic(err)
+		return nil
+	}
+	c.Client = cm
+	ctx, cancel := context.WithTimeout(context.Background(), config.ConnectTimeout)
+	defer cancel()
+	if err := cm.AwaitConnection(ctx); err != nil {
+		log.Panic(err)
+		return nil
+	}
+	return c
+}
+
+// onConnectionUp 每次(重新)连接成功后重置主题别名并重新订阅
+func (mc *MqttV5Client) onConnectionUp(cm *autopaho.ConnectionManager, connack *paho.Connack, aliasMaximum uint16) {
+	mc.aliases.reset(topicAliasMaximum(connack, aliasMaximum))
+
+	mc.topicsMu.RLock()
+	defer mc.topicsMu.RUnlock()
+	if len(mc.topics) == 0 {
+		return
+	}
+	sub := &paho.Subscribe{}
+	for topic := range mc.topics {
+		sub.Subscriptions = append(sub.Subscriptions, paho.SubscribeOptions{Topic: topic, QoS: mc.qos})
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), mc.requestTimeout)
+		defer cancel()
+		if _, err := cm.Subscribe(ctx, sub); err != nil {
+			log.Printf("重新订阅失败: %v", err)
+		}
+	}()
+}
+
+// Publish  Mqtt message, opts can set MQTT 5 properties such as user properties and message expiry.
+func (mc *MqttV5Client) Publish(topic string, payload []byte, opts ...PublishOption) error {
+	if mc == nil {
+		return errors.New("mqttClient is nil or disconnected")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), mc.requestTimeout)
+	defer cancel()
+	pr, err := mc.aliases.publish(mc.newPublish(topic, payload, opts), func(p *paho.Publish) (*paho.PublishResponse, error) {
+		return mc.Client.Publish(ctx, p)
+	})
+	if pr != nil && pr.ReasonCode >= 0x80 {
+		return reasonCodeError(pr.ReasonCode, pr.Properties)
+	}
+	return err
+}
+
+// newPublish 使用客户端默认的 qos 和保留标志创建消息, opts 可以覆盖
+func (mc *MqttV5Client) newPublish(topic string, payload []byte, opts []PublishOption) *paho.Publish {
+	p := &paho.Publish{
+		QoS:        mc.qos,
+		Retain:     mc.retained,
+		Topic:      topic,
+		Payload:    payload,
+		Properties: &paho.PublishProperties{},
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// Subscribes subscribe Mqtt topics
+func (mc *MqttV5Client) Subscribes(topics []string, onMessage paho.MessageHandler) error {
+	for _, topic := range topics {
+		if err := mc.Subscribe(topic, onMessage); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Subscribe subscribe a Mqtt topic, shared subscriptions are built with SharedTopic
+func (mc *MqttV5Client) Subscribe(topic string, onMessage paho.MessageHandler) error {
+	// 先注册 handler, 避免 SUBACK 之前到达的保留消息被丢弃
+	mc.setHandler(topic, onMessage)
+	ctx, cancel := context.WithTimeout(context.Background(), mc.requestTimeout)
+	defer cancel()
+	sa, err := mc.Client.Subscribe(ctx, &paho.Subscribe{
+		Subscriptions: []paho.SubscribeOptions{{Topic: topic, QoS: mc.qos}},
+	})
+	if sa != nil && len(sa.Reasons) > 0 && sa.Reasons[0] >= 0x80 {
+		err = subackError(sa)
+	}
+	if err != nil {
+		// 恢复之前订阅的 handler
+		mc.topicsMu.RLock()
+		old, ok := mc.topics[topic]
+		mc.topicsMu.RUnlock()
+		if ok {
+			mc.setHandler(topic, old)
+		} else {
+			mc.router.UnregisterHandler(topic)
+		}
+		return err
+	}
+	mc.topicsMu.Lock()
+	mc.topics[topic] = onMessage
+	mc.topicsMu.Unlock()
+	log.Println(fmt.Sprintf("订阅主题[%s]成功", topic))
+	return nil
+}
+
+// setHandler 替换 topic 的 handler, StandardRouter.RegisterHandler 会追加, 重复订阅时 handler 会被调用多次
+func (mc *MqttV5Client) setHandler(topic string, onMessage paho.MessageHandler) {
+	mc.router.UnregisterHandler(topic)
+	mc.router.RegisterHandler(topic, onMessage)
+}
+
+// Unsubscribe unsubscribe mqtt topics
+func (mc *MqttV5Client) Unsubscribe(topics ...string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), mc.requestTimeout)
+	defer cancel()
+	ua, err := mc.Client.Unsubscribe(ctx, &paho.Unsubscribe{Topics: topics})
+	if ua != nil {
+		for _, code := range ua.Reasons {
+			if code >= 0x80 {
+				var reason string
+				if ua.Properties != nil {
+					reason = ua.Properties.ReasonString
+				}
+				return &ReasonCodeError{Code: code, Reason: reason}
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+	mc.topicsMu.Lock()
+	for _, topic := range topics {
+		delete(mc.topics, topic)
+		mc.router.UnregisterHandler(topic)
+	}
+	mc.topicsMu.Unlock()
+	return nil
+}
+
+func (mc *MqttV5Client) Close() {
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	_ = mc.Client.Disconnect(ctx)
+}
+
+// publishPacket 发送 p, broker 返回失败的原因码时返回 ReasonCodeError
+func (mc *MqttV5Client) publishPacket(ctx context.Context, p *paho.Publish) error {
+	pr, err := mc.Client.Publish(ctx, p)
+	if pr != nil && pr.ReasonCode >= 0x80 {
+		return reasonCodeError(pr.ReasonCode, pr.Properties)
+	}
+	return err
+}
+
+func reasonCodeError(code byte, props *paho.PublishResponseProperties) error {
+	var reason string
+	if props != nil {
+		reason = props.ReasonString
+	}
+	return &ReasonCodeError{Code: code, Reason: reason}
+}
+
+func subackError(sa *paho.Suback) error {
+	var reason string
+	if sa.Properties != nil {
+		reason = sa.Properties.ReasonString
+	}
+	return &ReasonCodeError{Code: sa.Reasons[0], Reason: reason}
+}
+
+func disconnectError(d *paho.Disconnect) error {
+	var reason string
+	if d.Properties != nil {
+		reason = d.Properties.ReasonString
+	}
+	return &ReasonCodeError{Code: d.ReasonCode, Reason: reason}
+}
+
+// topicAliasMaximum 客户端可以使用的别名数量, CONNACK 没有 Topic Alias Maximum 属性时 broker 不接受别名
+func topicAliasMaximum(connack *paho.Connack, configured uint16) uint16 {
+	if connack == nil || connack.Properties == nil || connack.Properties.TopicAliasMaximum == nil {
+		return 0
+	}
+	if broker := *connack.Properties.TopicAliasMaximum; broker < configured {
+		return broker
+	}
+	return configured
+}
+
+// topicAliases 发布 QoS 0 消息时自动为主题分配别名, 别名只在当前连接内有效.
+// QoS 1/2 的消息会保存在会话中, 重连后按原样重发, 而新连接上别名已经失效, 因此不使用别名
+type topicAliases struct {
+	mu      sync.Mutex
+	maximum uint16
+	aliases map[string]uint16
+}
+
+// reset 连接建立或断开时调用, maximum 为 0 时不使用别名
+func (t *topicAliases) reset(maximum uint16) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.maximum = maximum
+	t.aliases = make(map[string]uint16)
+}
+
+// publish 为 QoS 0 的消息分配别名后调用 send. 分配和发送都在锁内完成,
+// 保证新别名的第一条消息(带主题)先于只带别名的消息写入连接
+func (t *topicAliases) publish(p *paho.Publish, send func(p *paho.Publish) (*paho.PublishResponse, error)) (*paho.PublishResponse, error) {
+	if p.QoS != 0 {
+		return send(p)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.apply(p)
+	pr, err := send(p)
+	if err != nil {
+		// 连接可能已经断开, broker 不一定收到了别名, 在重新连接之前不再使用别名
+		t.maximum = 0
+		t.aliases = make(map[string]uint16)
+	}
+	return pr, err
+}
+
+// apply 已分配别名的主题只发送别名, 调用方持有 t.mu
+func (t *topicAliases) apply(p *paho.Publish) {
+	if t.maximum == 0 || p.Topic == "" {
+		return
+	}
+	if p.Properties == nil {
+		p.Properties = &paho.PublishProperties{}
+	}
+	if alias, ok := t.aliases[p.Topic]; ok {
+		p.Properties.TopicAlias = &alias
+		p.Topic = ""
+		return
+	}
+	if uint16(len(t.aliases)) < t.maximum {
+		alias := uint16(len(t.aliases) + 1)
+		t.aliases[p.Topic] = alias
+		p.Properties.TopicAlias = &alias
+	}
+}
@@ -0,0 +1,173 @@
+package config
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/eclipse/paho.golang/packets"
+	"github.com/eclipse/paho.golang/paho"
+)
+
+func uint16Ptr(v uint16) *uint16 {
+	return &v
+}
+
+func TestTopicAliasMaximum(t *testing.T) {
+	tests := []struct {
+		name       string
+		connack    *paho.Connack
+		configured uint16
+		want       uint16
+	}{
+		{"没有属性", &paho.Connack{}, 10, 0},
+		{"没有 Topic Alias Maximum", &paho.Connack{Properties: &paho.ConnackProperties{}}, 10, 0},
+		{"broker 更小", &paho.Connack{Properties: &paho.ConnackProperties{TopicAliasMaximum: uint16Ptr(3)}}, 10, 3},
+		{"配置更小", &paho.Connack{Properties: &paho.ConnackProperties{TopicAliasMaximum: uint16Ptr(100)}}, 10, 10},
+		{"客户端不使用别名", &paho.Connack{Properties: &paho.ConnackProperties{TopicAliasMaximum: uint16Ptr(100)}}, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := topicAliasMaximum(tt.connack, tt.configured); got != tt.want {
+				t.Errorf("topicAliasMaximum = %d; 期望值 %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// sent 记录发送时的主题和别名
+type sent struct {
+	topic string
+	alias uint16
+}
+
+func publishAlias(t *testing.T, aliases *topicAliases, topic string, qos byte, sendErr error) sent {
+	t.Helper()
+	var s sent
+	_, err := aliases.publish(&paho.Publish{QoS: qos, Topic: topic}, func(p *paho.Publish) (*paho.PublishResponse, error) {
+		s.topic = p.Topic
+		if p.Properties != nil && p.Properties.TopicAlias != nil {
+			s.alias = *p.Properties.TopicAlias
+		}
+		return &paho.PublishResponse{}, sendErr
+	})
+	if !errors.Is(err, sendErr) {
+		t.Fatalf("publish 错误 = %v; 期望值 %v", err, sendErr)
+	}
+	return s
+}
+
+func TestTopicAliases(t *testing.T) {
+	var aliases topicAliases
+	// 连接建立之前不使用别名
+	if got := publishAlias(t, &aliases, "a", 0, nil); got != (sent{"a", 0}) {
+		t.Errorf("连接之前发送 %+v", got)
+	}
+
+	aliases.reset(2)
+	steps := []struct {
+		name  string
+		topic string
+		qos   byte
+		want  sent
+	}{
+		{"分配别名", "a", 0, sent{"a", 1}},
+		{"只发送别名", "a", 0, sent{"", 1}},
+		{"QoS 1 不使用别名", "a", 1, sent{"a", 0}},
+		{"分配第二个别名", "b", 0, sent{"b", 2}},
+		{"超过上限", "c", 0, sent{"c", 0}},
+		{"超过上限后仍然只发送别名", "b", 0, sent{"", 2}},
+	}
+	for _, step := range steps {
+		if got := publishAlias(t, &aliases, step.topic, step.qos, nil); got != step.want {
+			t.Errorf("%s: 发送 %+v; 期望值 %+v", step.name, got, step.want)
+		}
+	}
+
+	// 重新连接后别名重新分配
+	aliases.reset(2)
+	if got := publishAlias(t, &aliases, "b", 0, nil); got != (sent{"b", 1}) {
+		t.Errorf("重置后发送 %+v; 期望值 {b 1}", got)
+	}
+
+	// 发送失败后在重新连接之前不使用别名
+	sendErr := errors.New("connection lost")
+	publishAlias(t, &aliases, "c", 0, sendErr)
+	if got := publishAlias(t, &aliases, "b", 0, nil); got != (sent{"b", 0}) {
+		t.Errorf("发送失败后发送 %+v; 期望值 {b 0}", got)
+	}
+}
+
+func TestPublishOptions(t *testing.T) {
+	mc := &MqttV5Client{qos: 1, retained: true}
+	p := mc.newPublish("devices/1", []byte("on"), []PublishOption{
+		WithUserProperty("trace", "abc"),
+		WithUserProperty("trace", "def"),
+		WithMessageExpiry(90 * time.Second),
+		WithContentType("text/plain"),
+	})
+	if p.Topic != "devices/1" || string(p.Payload) != "on" || p.QoS != 1 || !p.Retain {
+		t.Errorf("newPublish = %+v", p)
+	}
+	if got := p.Properties.User.GetAll("trace"); len(got) != 2 || got[0] != "abc" || got[1] != "def" {
+		t.Errorf("用户属性 trace = %v; 期望值 [abc def]", got)
+	}
+	if p.Properties.MessageExpiry == nil || *p.Properties.MessageExpiry != 90 {
+		t.Errorf("MessageExpiry = %v; 期望值 90", p.Properties.MessageExpiry)
+	}
+	if p.Properties.ContentType != "text/plain" {
+		t.Errorf("ContentType = %q; 期望值 text/plain", p.Properties.ContentType)
+	}
+
+	p = mc.newPublish("devices/1", nil, []PublishOption{WithQos(0), WithRetained(false)})
+	if p.QoS != 0 || p.Retain {
+		t.Errorf("覆盖 qos 和保留标志后 QoS = %d, Retain = %v", p.QoS, p.Retain)
+	}
+
+	// 属性经过编码后对端能够读取
+	received := paho.PublishFromPacketPublish(mc.newPublish("devices/1", nil, []PublishOption{
+		WithUserProperty("k", "v"),
+		WithMessageExpiry(time.Minute),
+	}).Packet())
+	if received.Properties.User.Get("k") != "v" || *received.Properties.MessageExpiry != 60 {
+		t.Errorf("编码后的属性 = %+v", received.Properties)
+	}
+}
+
+func TestSharedTopic(t *testing.T) {
+	if got := SharedTopic("workers", "jobs/+"); got != "$share/workers/jobs/+" {
+		t.Errorf("SharedTopic = %s; 期望值 $share/workers/jobs/+", got)
+	}
+}
+
+func TestSetHandlerReplaces(t *testing.T) {
+	mc := &MqttV5Client{router: paho.NewStandardRouter()}
+	var first, second int
+	mc.setHandler("jobs/+", func(p *paho.Publish) { first++ })
+	mc.setHandler("jobs/+", func(p *paho.Publish) { second++ })
+	mc.router.Route(&packets.Publish{Topic: "jobs/1", Properties: &packets.Properties{}})
+	if first != 0 || second != 1 {
+		t.Errorf("重复订阅后 handler 调用次数 = %d, %d; 期望值 0, 1", first, second)
+	}
+}
+
+func TestReasonCodeError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"PUBACK", reasonCodeError(0x87, &paho.PublishResponseProperties{ReasonString: "not authorized"}), "mqtt reason code 0x87: not authorized"},
+		{"PUBACK 没有属性", reasonCodeError(0x80, nil), "mqtt reason code 0x80"},
+		{"SUBACK", subackError(&paho.Suback{Reasons: []byte{0x8f}}), "mqtt reason code 0x8f"},
+		{"DISCONNECT", disconnectError(&paho.Disconnect{ReasonCode: 0x8e, Properties: &paho.DisconnectProperties{ReasonString: "session taken over"}}), "mqtt reason code 0x8e: session taken over"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rc *ReasonCodeError
+			if !errors.As(tt.err, &rc) || tt.err.Error() != tt.want {
+				t.Errorf("错误 = %v; 期望值 %s", tt.err, tt.want)
+			}
+		})
+	}
+}
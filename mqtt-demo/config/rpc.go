@@ -0,0 +1,230 @@
+package config
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+const defaultRequestTimeout = 10 * time.Second
+
+// ErrRequestTimeout 请求在超时时间内没有收到响应
+var ErrRequestTimeout = errors.New("mqtt request timeout")
+
+// RequestHandler 处理一次请求并返回响应内容, ctx 在超时后会被取消
+type RequestHandler func(ctx context.Context, payload []byte) ([]byte, error)
+
+// RemoteError 对端 handler 返回的错误
+type RemoteError struct {
+	Message string
+}
+
+func (e *RemoteError) Error() string {
+	return "mqtt remote error: " + e.Message
+}
+
+// rpcEnvelope MQTT 3.1.1 没有 response topic/correlation data 属性,
+// 因此把回复主题和关联ID放在消息体中传递
+type rpcEnvelope struct {
+	CorrelationId string `json:"correlation_id"`
+	ReplyTo       string `json:"reply_to,omitempty"`
+	Payload       []byte `json:"payload,omitempty"`
+	Error         string `json:"error,omitempty"`
+}
+
+// rpcState 请求方等待响应的状态
+type rpcState struct {
+	subMu      sync.Mutex // 保护 replyTopic, 订阅期间不能持有 mu, 否则会阻塞响应分发
+	replyTopic string     // 已订阅的回复主题, 为空表示尚未订阅
+	mu         sync.Mutex
+	pending    map[string]chan rpcEnvelope
+}
+
+// Request sends payload to topic and waits for the reply of a HandleRequests
+// handler. If ctx has no deadline the client's RequestTimeout is applied.
+func (mc *MqttClient) Request(ctx context.Context, topic string, payload []byte) ([]byte, error) {
+	if mc == nil || !mc.Client.IsConnected() {
+		return nil, errors.New("mqttClient is nil or disconnected")
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, mc.requestTimeout)
+		defer cancel()
+	}
+	replyTopic, err := mc.ensureReplySubscription()
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := newCorrelationId()
+	if err != nil {
+		return nil, err
+	}
+	ch, done := mc.rpc.register(id)
+	defer done()
+
+	body, err := json.Marshal(rpcEnvelope{CorrelationId: id, ReplyTo: replyTopic, Payload: payload})
+	if err != nil {
+		return nil, err
+	}
+	if err := mc.publish(topic, mc.qos, false, body); err != nil {
+		return nil, err
+	}
+	return awaitReply(ctx, ch)
+}
+
+// HandleRequests subscribes topic and answers every request with the result
+// of handler. Each handler call is bounded by the client's RequestTimeout.
+func (mc *MqttClient) HandleRequests(topic string, handler RequestHandler) error {
+	return mc.Subscribe(topic, func(client mqtt.Client, message mqtt.Message) {
+		var req rpcEnvelope
+		if err := json.Unmarshal(message.Payload(), &req); err != nil || req.ReplyTo == "" {
+			log.Printf("忽略无效的请求消息[%s]: %v", message.Topic(), err)
+			return
+		}
+		// 回复需要等待 publish 完成, 不能阻塞 paho 的消息分发协程
+		go serveRequest(mc.requestTimeout, req, handler, func(reply rpcEnvelope) error {
+			body, err := json.Marshal(reply)
+			if err != nil {
+				return err
+			}
+			return mc.publish(req.ReplyTo, mc.qos, false, body)
+		})
+	})
+}
+
+// ensureReplySubscription 首次请求时订阅本客户端的回复主题
+func (mc *MqttClient) ensureReplySubscription() (string, error) {
+	mc.rpc.subMu.Lock()
+	defer mc.rpc.subMu.Unlock()
+	if mc.rpc.replyTopic != "" {
+		return mc.rpc.replyTopic, nil
+	}
+	replyTopic, err := newReplyTopic(mc.clientId)
+	if err != nil {
+		return "", err
+	}
+	err = mc.Subscribe(replyTopic, func(client mqtt.Client, message mqtt.Message) {
+		var reply rpcEnvelope
+		if err := json.Unmarshal(message.Payload(), &reply); err != nil {
+			log.Printf("忽略无效的响应消息[%s]: %v", message.Topic(), err)
+			return
+		}
+		mc.rpc.deliver(reply)
+	})
+	if err != nil {
+		return "", err
+	}
+	mc.rpc.replyTopic = replyTopic
+	return replyTopic, nil
+}
+
+// register 登记等待 id 的响应, 返回接收响应的通道和取消登记的函数
+func (s *rpcState) register(id string) (<-chan rpcEnvelope, func()) {
+	ch := make(chan rpcEnvelope, 1)
+	s.mu.Lock()
+	if s.pending == nil {
+		s.pending = make(map[string]chan rpcEnvelope)
+	}
+	s.pending[id] = ch
+	s.mu.Unlock()
+	return ch, func() {
+		s.mu.Lock()
+		delete(s.pending, id)
+		s.mu.Unlock()
+	}
+}
+
+// deliver 把响应交给等待它的请求
+func (s *rpcState) deliver(reply rpcEnvelope) {
+	s.mu.Lock()
+	ch, ok := s.pending[reply.CorrelationId]
+	s.mu.Unlock()
+	if ok {
+		// QoS 1 可能重复投递, 只取第一条响应
+		select {
+		case ch <- reply:
+		default:
+		}
+	}
+}
+
+// awaitReply 等待响应, ctx 超时返回 ErrRequestTimeout
+func awaitReply(ctx context.Context, ch <-chan rpcEnvelope) ([]byte, error) {
+	select {
+	case reply := <-ch:
+		if reply.Error != "" {
+			return nil, &RemoteError{Message: reply.Error}
+		}
+		return reply.Payload, nil
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, ErrRequestTimeout
+		}
+		return nil, ctx.Err()
+	}
+}
+
+// serveRequest 在 timeout 内调用 handler, 把结果、错误或超时交给 publish 发送给请求方.
+// MQTT 3.1.1 和 MQTT 5 的区别只在于响应如何编码和发送
+func serveRequest(timeout time.Duration, req rpcEnvelope, handler RequestHandler, publish func(reply rpcEnvelope) error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	done := make(chan rpcEnvelope, 1)
+	go func() {
+		reply := rpcEnvelope{CorrelationId: req.CorrelationId}
+		defer func() {
+			if r := recover(); r != nil {
+				reply.Error = fmt.Sprintf("handler panic: %v", r)
+				done <- reply
+			}
+		}()
+		payload, err := handler(ctx, req.Payload)
+		if err != nil {
+			reply.Error = err.Error()
+		} else {
+			reply.Payload = payload
+		}
+		done <- reply
+	}()
+
+	var reply rpcEnvelope
+	select {
+	case reply = <-done:
+	case <-ctx.Done():
+		reply = rpcEnvelope{CorrelationId: req.CorrelationId, Error: ErrRequestTimeout.Error()}
+	}
+	if err := publish(reply); err != nil {
+		log.Printf("发送响应到[%s]失败: %v", req.ReplyTo, err)
+	}
+}
+
+// newReplyTopic 请求方的回复主题 reply/<clientId>. clientId 为空时使用随机ID,
+// 避免没有设置 ClientId 的客户端共用同一个回复主题而收到彼此的响应
+func newReplyTopic(clientId string) (string, error) {
+	if clientId == "" {
+		id, err := newCorrelationId()
+		if err != nil {
+			return "", err
+		}
+		clientId = id
+	}
+	return "reply/" + clientId, nil
+}
+
+func newCorrelationId() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,283 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eclipse/paho.golang/paho"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// memBus 进程内的消息总线, 按主题精确匹配把消息投递给订阅者
+type memBus struct {
+	mu   sync.Mutex
+	subs map[string][]mqtt.MessageHandler
+}
+
+// memClient 连接到 memBus 的 mqtt.Client
+type memClient struct {
+	bus *memBus
+}
+
+func (c *memClient) IsConnected() bool      { return true }
+func (c *memClient) IsConnectionOpen() bool { return true }
+func (c *memClient) Connect() mqtt.Token    { return doneToken{} }
+func (c *memClient) Disconnect(uint)        {}
+
+func (c *memClient) Publish(topic string, qos byte, retained bool, payload interface{}) mqtt.Token {
+	var body []byte
+	switch p := payload.(type) {
+	case []byte:
+		body = p
+	case string:
+		body = []byte(p)
+	}
+	c.bus.mu.Lock()
+	handlers := append([]mqtt.MessageHandler(nil), c.bus.subs[topic]...)
+	c.bus.mu.Unlock()
+	for _, handler := range handlers {
+		go handler(c, &memMessage{topic: topic, qos: qos, payload: body})
+	}
+	return doneToken{}
+}
+
+func (c *memClient) Subscribe(topic string, qos byte, callback mqtt.MessageHandler) mqtt.Token {
+	c.bus.mu.Lock()
+	defer c.bus.mu.Unlock()
+	c.bus.subs[topic] = append(c.bus.subs[topic], callback)
+	return doneToken{}
+}
+
+func (c *memClient) SubscribeMultiple(filters map[string]byte, callback mqtt.MessageHandler) mqtt.Token {
+	for topic, qos := range filters {
+		c.Subscribe(topic, qos, callback)
+	}
+	return doneToken{}
+}
+
+func (c *memClient) Unsubscribe(topics ...string) mqtt.Token {
+	c.bus.mu.Lock()
+	defer c.bus.mu.Unlock()
+	for _, topic := range topics {
+		delete(c.bus.subs, topic)
+	}
+	return doneToken{}
+}
+
+func (c *memClient) AddRoute(string, mqtt.MessageHandler) {}
+
+func (c *memClient) OptionsReader() mqtt.ClientOptionsReader {
+	return mqtt.ClientOptionsReader{}
+}
+
+type doneToken struct{}
+
+func (doneToken) Wait() bool                     { return true }
+func (doneToken) WaitTimeout(time.Duration) bool { return true }
+func (doneToken) Error() error                   { return nil }
+
+func (doneToken) Done() <-chan struct{} {
+	ch := make(chan struct{})
+	close(ch)
+	return ch
+}
+
+type memMessage struct {
+	topic   string
+	qos     byte
+	payload []byte
+}
+
+func (m *memMessage) Duplicate() bool   { return false }
+func (m *memMessage) Qos() byte         { return m.qos }
+func (m *memMessage) Retained() bool    { return false }
+func (m *memMessage) Topic() string     { return m.topic }
+func (m *memMessage) MessageID() uint16 { return 0 }
+func (m *memMessage) Payload() []byte   { return m.payload }
+func (m *memMessage) Ack()              {}
+
+func newMemClient(bus *memBus, clientId string, requestTimeout time.Duration) *MqttClient {
+	return &MqttClient{
+		qos:            1,
+		clientId:       clientId,
+		requestTimeout: requestTimeout,
+		Client:         &memClient{bus: bus},
+		topics:         make(map[string]mqtt.MessageHandler),
+	}
+}
+
+func TestRequestReply(t *testing.T) {
+	bus := &memBus{subs: make(map[string][]mqtt.MessageHandler)}
+	server := newMemClient(bus, "server", time.Second)
+	client := newMemClient(bus, "client", time.Second)
+
+	if err := server.HandleRequests("svc/echo", func(ctx context.Context, payload []byte) ([]byte, error) {
+		if string(payload) == "fail" {
+			return nil, errors.New("bad request")
+		}
+		if string(payload) == "panic" {
+			panic("boom")
+		}
+		return append([]byte("echo:"), payload...), nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	reply, err := client.Request(context.Background(), "svc/echo", []byte("hi"))
+	if err != nil || string(reply) != "echo:hi" {
+		t.Errorf("Request = %s, %v; 期望值 echo:hi", reply, err)
+	}
+	for _, payload := range []string{"fail", "panic"} {
+		var remote *RemoteError
+		if _, err := client.Request(context.Background(), "svc/echo", []byte(payload)); !errors.As(err, &remote) {
+			t.Errorf("Request(%s) 错误 = %v; 期望 RemoteError", payload, err)
+		}
+	}
+}
+
+func TestRequestTimeout(t *testing.T) {
+	bus := &memBus{subs: make(map[string][]mqtt.MessageHandler)}
+	server := newMemClient(bus, "server", 100*time.Millisecond)
+	client := newMemClient(bus, "client", 100*time.Millisecond)
+
+	// 没有 handler 时使用客户端的 RequestTimeout
+	if _, err := client.Request(context.Background(), "svc/none", nil); !errors.Is(err, ErrRequestTimeout) {
+		t.Errorf("Request 错误 = %v; 期望 ErrRequestTimeout", err)
+	}
+
+	// handler 超时后服务端回复超时错误, 客户端的 ctx 比服务端长
+	if err := server.HandleRequests("svc/slow", func(ctx context.Context, payload []byte) ([]byte, error) {
+		<-ctx.Done()
+		time.Sleep(time.Second)
+		return nil, ctx.Err()
+	}); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	start := time.Now()
+	_, err := client.Request(ctx, "svc/slow", nil)
+	var remote *RemoteError
+	if !errors.As(err, &remote) || remote.Message != ErrRequestTimeout.Error() {
+		t.Errorf("Request 错误 = %v; 期望服务端超时", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Request 耗时 %v; 服务端应该在超时后立即回复", elapsed)
+	}
+
+	// ctx 取消时返回 ctx 的错误
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if _, err := client.Request(ctx, "svc/none", nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("Request 错误 = %v; 期望 context.Canceled", err)
+	}
+}
+
+// memV5Bus 进程内的 MQTT 5 消息总线, 按主题精确匹配投递, 保留消息属性
+type memV5Bus struct {
+	mu   sync.Mutex
+	subs map[string][]paho.MessageHandler
+}
+
+func newMemV5Rpc(bus *memV5Bus, clientId string, requestTimeout time.Duration) *MqttV5Rpc {
+	return newMqttV5Rpc(clientId, 1, requestTimeout,
+		func(ctx context.Context, p *paho.Publish) error {
+			bus.mu.Lock()
+			handlers := append([]paho.MessageHandler(nil), bus.subs[p.Topic]...)
+			bus.mu.Unlock()
+			for _, handler := range handlers {
+				go handler(p)
+			}
+			return nil
+		},
+		func(topic string, onMessage paho.MessageHandler) error {
+			bus.mu.Lock()
+			defer bus.mu.Unlock()
+			bus.subs[topic] = append(bus.subs[topic], onMessage)
+			return nil
+		})
+}
+
+func TestRequestReplyV5(t *testing.T) {
+	bus := &memV5Bus{subs: make(map[string][]paho.MessageHandler)}
+	server := newMemV5Rpc(bus, "server", time.Second)
+	client := newMemV5Rpc(bus, "client", time.Second)
+
+	if err := server.HandleRequests("svc/echo", func(ctx context.Context, payload []byte) ([]byte, error) {
+		if string(payload) == "fail" {
+			return nil, errors.New("bad request")
+		}
+		if string(payload) == "panic" {
+			panic("boom")
+		}
+		return append([]byte("echo:"), payload...), nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	reply, err := client.Request(context.Background(), "svc/echo", []byte("hi"))
+	if err != nil || string(reply) != "echo:hi" {
+		t.Errorf("Request = %s, %v; 期望值 echo:hi", reply, err)
+	}
+	for _, payload := range []string{"fail", "panic"} {
+		var remote *RemoteError
+		if _, err := client.Request(context.Background(), "svc/echo", []byte(payload)); !errors.As(err, &remote) {
+			t.Errorf("Request(%s) 错误 = %v; 期望 RemoteError", payload, err)
+		}
+	}
+}
+
+func TestRequestTimeoutV5(t *testing.T) {
+	bus := &memV5Bus{subs: make(map[string][]paho.MessageHandler)}
+	server := newMemV5Rpc(bus, "server", 100*time.Millisecond)
+	client := newMemV5Rpc(bus, "client", 100*time.Millisecond)
+
+	// 没有 handler 时使用客户端的 RequestTimeout
+	if _, err := client.Request(context.Background(), "svc/none", nil); !errors.Is(err, ErrRequestTimeout) {
+		t.Errorf("Request 错误 = %v; 期望 ErrRequestTimeout", err)
+	}
+
+	// handler 超时后服务端回复超时错误
+	if err := server.HandleRequests("svc/slow", func(ctx context.Context, payload []byte) ([]byte, error) {
+		<-ctx.Done()
+		time.Sleep(time.Second)
+		return nil, ctx.Err()
+	}); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	start := time.Now()
+	_, err := client.Request(ctx, "svc/slow", nil)
+	var remote *RemoteError
+	if !errors.As(err, &remote) || remote.Message != ErrRequestTimeout.Error() {
+		t.Errorf("Request 错误 = %v; 期望服务端超时", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Request 耗时 %v; 服务端应该在超时后立即回复", elapsed)
+	}
+}
+
+func TestEmptyClientIdReplyTopic(t *testing.T) {
+	bus := &memBus{subs: make(map[string][]mqtt.MessageHandler)}
+	v5bus := &memV5Bus{subs: make(map[string][]paho.MessageHandler)}
+	topics := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		topic, err := newMemClient(bus, "", time.Second).ensureReplySubscription()
+		if err != nil {
+			t.Fatal(err)
+		}
+		topics[topic] = true
+		topic, err = newMemV5Rpc(v5bus, "", time.Second).ensureReplySubscription()
+		if err != nil {
+			t.Fatal(err)
+		}
+		topics[topic] = true
+	}
+	if len(topics) != 4 || topics["reply/"] {
+		t.Errorf("没有 ClientId 的回复主题 = %v; 期望互不相同", topics)
+	}
+}
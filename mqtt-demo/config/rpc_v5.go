@@ -0,0 +1,183 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/eclipse/paho.golang/autopaho"
+	"github.com/eclipse/paho.golang/paho"
+)
+
+// rpcErrorProperty 响应中携带 handler 错误信息的用户属性
+const rpcErrorProperty = "error"
+
+// MqttV5Rpc MQTT 5 的请求/响应: 回复主题和关联ID通过 response topic 和
+// correlation data 属性传递, 不需要像 MQTT 3.1.1 那样放在消息体中
+type MqttV5Rpc struct {
+	qos            byte
+	clientId       string
+	requestTimeout time.Duration
+	publish        func(ctx context.Context, p *paho.Publish) error
+	subscribe      func(topic string, onMessage paho.MessageHandler) error
+	rpc            rpcState
+}
+
+// NewMqttV5Rpc sends requests and replies through cm. Messages received by cm
+// must be routed to router, e.g. from paho.ClientConfig.OnPublishReceived.
+// The reply topic is derived from clientId. Subscriptions are not restored
+// when cm reconnects without a session.
+func NewMqttV5Rpc(cm *autopaho.ConnectionManager, router *paho.StandardRouter, clientId string, qos byte, requestTimeout time.Duration) *MqttV5Rpc {
+	if requestTimeout <= 0 {
+		requestTimeout = defaultRequestTimeout
+	}
+	return newMqttV5Rpc(clientId, qos, requestTimeout,
+		func(ctx context.Context, p *paho.Publish) error {
+			// broker 返回失败的原因码时 paho 同样返回错误
+			_, err := cm.Publish(ctx, p)
+			return err
+		},
+		func(topic string, onMessage paho.MessageHandler) error {
+			router.RegisterHandler(topic, onMessage)
+			ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+			defer cancel()
+			if _, err := cm.Subscribe(ctx, &paho.Subscribe{
+				Subscriptions: []paho.SubscribeOptions{{Topic: topic, QoS: qos}},
+			}); err != nil {
+				router.UnregisterHandler(topic)
+				return err
+			}
+			return nil
+		})
+}
+
+// newMqttV5Rpc 通过 publish 和 subscribe 发送和接收消息, MqttV5Client 使用自己的发布和订阅
+func newMqttV5Rpc(clientId string, qos byte, requestTimeout time.Duration,
+	publish func(ctx context.Context, p *paho.Publish) error,
+	subscribe func(topic string, onMessage paho.MessageHandler) error) *MqttV5Rpc {
+	return &MqttV5Rpc{
+		qos:            qos,
+		clientId:       clientId,
+		requestTimeout: requestTimeout,
+		publish:        publish,
+		subscribe:      subscribe,
+	}
+}
+
+// Request sends payload to topic and waits for the reply, see MqttV5Rpc.Request.
+func (mc *MqttV5Client) Request(ctx context.Context, topic string, payload []byte) ([]byte, error) {
+	if mc == nil {
+		return nil, errors.New("mqttClient is nil or disconnected")
+	}
+	return mc.rpc.Request(ctx, topic, payload)
+}
+
+// HandleRequests answers the requests sent to topic, see MqttV5Rpc.HandleRequests.
+func (mc *MqttV5Client) HandleRequests(topic string, handler RequestHandler) error {
+	return mc.rpc.HandleRequests(topic, handler)
+}
+
+// Request sends payload to topic with the MQTT 5 response topic and correlation
+// data properties and waits for the reply. If ctx has no deadline the
+// RequestTimeout is applied.
+func (r *MqttV5Rpc) Request(ctx context.Context, topic string, payload []byte) ([]byte, error) {
+	if r == nil {
+		return nil, errors.New("mqttClient is nil or disconnected")
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.requestTimeout)
+		defer cancel()
+	}
+	replyTopic, err := r.ensureReplySubscription()
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := newCorrelationId()
+	if err != nil {
+		return nil, err
+	}
+	ch, done := r.rpc.register(id)
+	defer done()
+
+	if err := r.publish(ctx, &paho.Publish{
+		QoS:     r.qos,
+		Topic:   topic,
+		Payload: payload,
+		Properties: &paho.PublishProperties{
+			ResponseTopic:   replyTopic,
+			CorrelationData: []byte(id),
+		},
+	}); err != nil {
+		return nil, err
+	}
+	return awaitReply(ctx, ch)
+}
+
+// HandleRequests subscribes topic and publishes the result of handler to the
+// response topic of every request. Each handler call is bounded by the
+// RequestTimeout.
+func (r *MqttV5Rpc) HandleRequests(topic string, handler RequestHandler) error {
+	return r.subscribe(topic, func(p *paho.Publish) {
+		if p.Properties == nil || p.Properties.ResponseTopic == "" {
+			log.Printf("忽略没有 response topic 的请求消息[%s]", p.Topic)
+			return
+		}
+		req := rpcEnvelope{
+			CorrelationId: string(p.Properties.CorrelationData),
+			ReplyTo:       p.Properties.ResponseTopic,
+			Payload:       p.Payload,
+		}
+		// 回复需要等待 publish 完成, 不能阻塞 paho 的消息分发协程
+		go serveRequest(r.requestTimeout, req, handler, r.reply(req.ReplyTo))
+	})
+}
+
+// reply 把响应发送到 replyTo, 关联ID和错误信息放在属性中
+func (r *MqttV5Rpc) reply(replyTo string) func(reply rpcEnvelope) error {
+	return func(reply rpcEnvelope) error {
+		props := &paho.PublishProperties{CorrelationData: []byte(reply.CorrelationId)}
+		if reply.Error != "" {
+			props.User.Add(rpcErrorProperty, reply.Error)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), r.requestTimeout)
+		defer cancel()
+		return r.publish(ctx, &paho.Publish{
+			QoS:        r.qos,
+			Topic:      replyTo,
+			Payload:    reply.Payload,
+			Properties: props,
+		})
+	}
+}
+
+// ensureReplySubscription 首次请求时订阅本客户端的回复主题
+func (r *MqttV5Rpc) ensureReplySubscription() (string, error) {
+	r.rpc.subMu.Lock()
+	defer r.rpc.subMu.Unlock()
+	if r.rpc.replyTopic != "" {
+		return r.rpc.replyTopic, nil
+	}
+	replyTopic, err := newReplyTopic(r.clientId)
+	if err != nil {
+		return "", err
+	}
+	err = r.subscribe(replyTopic, func(p *paho.Publish) {
+		if p.Properties == nil {
+			return
+		}
+		reply := rpcEnvelope{
+			CorrelationId: string(p.Properties.CorrelationData),
+			Payload:       p.Payload,
+			Error:         p.Properties.User.Get(rpcErrorProperty),
+		}
+		r.rpc.deliver(reply)
+	})
+	if err != nil {
+		return "", err
+	}
+	r.rpc.replyTopic = replyTopic
+	return replyTopic, nil
+}
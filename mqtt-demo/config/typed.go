@@ -0,0 +1,110 @@
+package config
+
+import (
+	"log"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"mqtt-demo/codec"
+)
+
+// TypedHandler 收到并解码成功的消息
+type TypedHandler[T any] func(message mqtt.Message, v T)
+
+// DecodeErrorHandler 消息解码失败时调用, 默认只打印日志
+type DecodeErrorHandler func(message mqtt.Message, err error)
+
+type typedOptions struct {
+	codec         codec.Codec
+	compressor    codec.Compressor
+	onDecodeError DecodeErrorHandler
+	subscribe     []SubscribeOption
+}
+
+// TypedOption 配置 PublishTyped/SubscribeTyped 的编解码方式
+type TypedOption func(o *typedOptions)
+
+// WithCodec sets the payload codec, the default is codec.JSON.
+func WithCodec(c codec.Codec) TypedOption {
+	return func(o *typedOptions) {
+		o.codec = c
+	}
+}
+
+// WithCompression compresses published payloads and decompresses received
+// ones. Publisher and subscriber must use the same compressor.
+func WithCompression(c codec.Compressor) TypedOption {
+	return func(o *typedOptions) {
+		o.compressor = c
+	}
+}
+
+// OnDecodeError sets the hook called when a received payload can't be decoded.
+func OnDecodeError(handler DecodeErrorHandler) TypedOption {
+	return func(o *typedOptions) {
+		o.onDecodeError = handler
+	}
+}
+
+// WithSubscribeOptions passes opts to Subscribe, e.g. WithDispatcher to decode
+// and handle messages on a worker pool.
+func WithSubscribeOptions(opts ...SubscribeOption) TypedOption {
+	return func(o *typedOptions) {
+		o.subscribe = append(o.subscribe, opts...)
+	}
+}
+
+func newTypedOptions(opts []TypedOption) typedOptions {
+	o := typedOptions{
+		codec: codec.JSON,
+		onDecodeError: func(message mqtt.Message, err error) {
+			log.Printf("解码主题[%s]的消息失败: %v", message.Topic(), err)
+		},
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// PublishTyped encodes v with the configured codec and publishes it.
+func PublishTyped[T any](mc *MqttClient, topic string, v T, opts ...TypedOption) error {
+	o := newTypedOptions(opts)
+	payload, err := o.codec.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if o.compressor != nil {
+		if payload, err = o.compressor.Compress(payload); err != nil {
+			return err
+		}
+	}
+	return mc.Publish(topic, payload)
+}
+
+// PublishJSON publishes v encoded as JSON.
+func PublishJSON[T any](mc *MqttClient, topic string, v T, opts ...TypedOption) error {
+	return PublishTyped(mc, topic, v, append([]TypedOption{WithCodec(codec.JSON)}, opts...)...)
+}
+
+// SubscribeTyped subscribes topic and decodes every payload into T before
+// calling handler. Payloads that fail to decode go to the OnDecodeError hook,
+// WithSubscribeOptions configures the subscription itself.
+func SubscribeTyped[T any](mc *MqttClient, topic string, handler TypedHandler[T], opts ...TypedOption) error {
+	o := newTypedOptions(opts)
+	return mc.Subscribe(topic, func(client mqtt.Client, message mqtt.Message) {
+		payload := message.Payload()
+		if o.compressor != nil {
+			var err error
+			if payload, err = o.compressor.Decompress(payload); err != nil {
+				o.onDecodeError(message, err)
+				return
+			}
+		}
+		var v T
+		if err := o.codec.Unmarshal(payload, &v); err != nil {
+			o.onDecodeError(message, err)
+			return
+		}
+		handler(message, v)
+	}, o.subscribe...)
+}
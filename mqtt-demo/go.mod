@@ -1,11 +1,17 @@
 module mqtt-demo
 
-go 1.19
+go 1.21
 
-require github.com/eclipse/paho.mqtt.golang v1.4.2
+require (
+	github.com/eclipse/paho.golang v0.22.0
+	github.com/eclipse/paho.mqtt.golang v1.4.2
+	github.com/fxamacker/cbor/v2 v2.7.0
+	github.com/gorilla/websocket v1.5.3
+	google.golang.org/protobuf v1.33.0
+)
 
 require (
-	github.com/gorilla/websocket v1.4.2 // indirect
-	golang.org/x/net v0.0.0-20200425230154-ff2c4b7c35a0 // indirect
+	github.com/x448/float16 v0.8.4 // indirect
+	golang.org/x/net v0.27.0 // indirect
 	golang.org/x/sync v0.0.0-20210220032951-036812b2e83c // indirect
 )
@@ -1,20 +1,37 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	mqtt "github.com/eclipse/paho.mqtt.golang"
 	"log"
+	"mqtt-demo/broker"
 	"mqtt-demo/config"
 	"time"
 )
 
 func main() {
+	local := flag.Bool("local", false, "使用进程内的 broker, 不连接公网")
+	flag.Parse()
+
 	mqttConnectConfig := config.MqttConnectConfig{
 		Broker:   "broker.emqx.io",
 		Port:     1883,
 		ClientId: "go_mqtt_client",
 		User:     "emqx",
 		Password: "public",
+		EventHandler: config.EventHandlerFunc(func(event config.Event) {
+			log.Printf("mqtt event: %s %v", event.Type, event.Err)
+		}),
+	}
+	if *local {
+		b, err := broker.StartLocal()
+		if err != nil {
+			log.Panic(err)
+		}
+		defer b.Close()
+		mqttConnectConfig.Broker = b.Host()
+		mqttConnectConfig.Port = b.Port()
 	}
 
 	mc := config.NewMqttClient(mqttConnectConfig)
@@ -33,5 +50,6 @@ func main() {
 		log.Println([]byte{byte(i)})
 		time.Sleep(5 * time.Second)
 	}
+	log.Printf("mqtt metrics: %+v", mc.Metrics())
 	mc.Client.Disconnect(2500)
 }
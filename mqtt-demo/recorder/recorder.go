@@ -0,0 +1,145 @@
+package recorder
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"mqtt-demo/config"
+)
+
+// Record 录制文件中的一行, payload 在 JSON 中为 base64
+type Record struct {
+	Time     time.Time `json:"time"`
+	Topic    string    `json:"topic"`
+	Payload  []byte    `json:"payload"`
+	Qos      byte      `json:"qos"`
+	Retained bool      `json:"retained"`
+}
+
+// Recorder 把订阅到的消息以 JSONL 格式写入 w
+type Recorder struct {
+	mu    sync.Mutex
+	w     *bufio.Writer
+	count int
+	err   error
+}
+
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{w: bufio.NewWriter(w)}
+}
+
+// Start subscribes every filter on mc and records the matching messages.
+func (r *Recorder) Start(mc *config.MqttClient, filters ...string) error {
+	return mc.Subscribes(filters, func(client mqtt.Client, message mqtt.Message) {
+		r.Write(Record{
+			Time:     time.Now(),
+			Topic:    message.Topic(),
+			Payload:  message.Payload(),
+			Qos:      message.Qos(),
+			Retained: message.Retained(),
+		})
+	})
+}
+
+// Write appends one record, the first write error is kept and returned by Close.
+func (r *Recorder) Write(record Record) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return
+	}
+	line, err := json.Marshal(record)
+	if err == nil {
+		line = append(line, '\n')
+		_, err = r.w.Write(line)
+	}
+	if err == nil {
+		// 每条消息都落盘, 进程被中断时不丢失已录制的内容
+		err = r.w.Flush()
+	}
+	if err != nil {
+		r.err = err
+		return
+	}
+	r.count++
+}
+
+// Count returns the number of recorded messages.
+func (r *Recorder) Count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.count
+}
+
+// Close flushes buffered records and returns the first write error.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return r.err
+	}
+	return r.w.Flush()
+}
+
+// PublishFunc 回放时发布一条消息, config.MqttClient.PublishMessage 满足该签名
+type PublishFunc func(topic string, qos byte, retained bool, payload []byte) error
+
+// Replay publishes the records read from rd keeping the original intervals
+// divided by speed, e.g. speed 2 replays twice as fast. speed <= 0 publishes
+// without waiting. It returns the number of published messages.
+func Replay(ctx context.Context, rd io.Reader, publish PublishFunc, speed float64) (int, error) {
+	return replay(ctx, rd, publish, speed, sleep)
+}
+
+// replay 通过 wait 等待消息之间的间隔, 测试中替换为记录间隔的函数
+func replay(ctx context.Context, rd io.Reader, publish PublishFunc, speed float64, wait func(ctx context.Context, d time.Duration) error) (int, error) {
+	scanner := bufio.NewScanner(rd)
+	// MQTT 消息最大 256MB, 这里限制单行 16MB
+	scanner.Buffer(make([]byte, 64*1024), 16<<20)
+
+	var previous time.Time
+	count := 0
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return count, fmt.Errorf("第 %d 行格式错误: %w", line, err)
+		}
+		if speed > 0 && !previous.IsZero() {
+			if d := time.Duration(float64(record.Time.Sub(previous)) / speed); d > 0 {
+				if err := wait(ctx, d); err != nil {
+					return count, err
+				}
+			}
+		}
+		previous = record.Time
+		if err := ctx.Err(); err != nil {
+			return count, err
+		}
+		if err := publish(record.Topic, record.Qos, record.Retained, record.Payload); err != nil {
+			return count, fmt.Errorf("发布第 %d 行失败: %w", line, err)
+		}
+		count++
+	}
+	return count, scanner.Err()
+}
+
+// sleep 等待 d 或者 ctx 结束
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
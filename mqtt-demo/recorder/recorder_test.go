@@ -0,0 +1,44 @@
+package recorder
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRecordReplay(t *testing.T) {
+	var buf bytes.Buffer
+	rec := NewRecorder(&buf)
+	start := time.Now()
+	rec.Write(Record{Time: start, Topic: "a/b", Payload: []byte{0x00, 0xff}, Qos: 1})
+	rec.Write(Record{Time: start.Add(200 * time.Millisecond), Topic: "a/c", Payload: []byte("on"), Retained: true})
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []Record
+	publish := func(topic string, qos byte, retained bool, payload []byte) error {
+		got = append(got, Record{Topic: topic, Payload: payload, Qos: qos, Retained: retained})
+		return nil
+	}
+	var waits []time.Duration
+	wait := func(ctx context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+	// 2 倍速回放, 两条消息间隔 100ms
+	count, err := replay(context.Background(), bytes.NewReader(buf.Bytes()), publish, 2, wait)
+	if err != nil || count != 2 {
+		t.Fatalf("Replay = %d, %v", count, err)
+	}
+	if got[0].Topic != "a/b" || !bytes.Equal(got[0].Payload, []byte{0x00, 0xff}) || got[0].Qos != 1 {
+		t.Errorf("第一条消息 = %+v", got[0])
+	}
+	if got[1].Topic != "a/c" || !got[1].Retained {
+		t.Errorf("第二条消息 = %+v", got[1])
+	}
+	if len(waits) != 1 || waits[0] != 100*time.Millisecond {
+		t.Errorf("回放间隔 = %v; 期望值 [100ms]", waits)
+	}
+}